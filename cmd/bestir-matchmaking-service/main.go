@@ -3,15 +3,32 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/Max-Gabriel-Susman/bestir-go-kit/bestirlog"
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
 	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/decisionlog"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/metrics"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/tracing"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
 	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/handler"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/webhook"
 	env "github.com/caarlos0/env/v6"
+	"github.com/gocraft/dbr/v2"
 	"github.com/pkg/errors"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
 	"go.uber.org/zap"
 )
@@ -54,7 +71,176 @@ func main() {
 	}
 }
 
-func run(ctx context.Context, _ []string) error {
+// run dispatches to the subcommand named in args, serving when there is none.
+func run(ctx context.Context, args []string) error {
+	cmd, rest := "serve", []string(nil)
+	if len(args) > 1 {
+		cmd, rest = args[1], args[2:]
+	}
+
+	switch cmd {
+	case "serve":
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		return serve(ctx, cfg)
+	case "migrate":
+		return runMigrate(ctx, rest)
+	case "policy":
+		return runPolicy(ctx, rest)
+	}
+	return fmt.Errorf("unknown command %q\n%s", cmd, usage)
+}
+
+const usage = `usage: bestir-permissionmaking-service [command]
+
+commands:
+  serve                        run the service (default)
+  migrate up [--to version]    migrate up to version.txt or --to
+  migrate down --to version    migrate down to --to
+  migrate status               list migrations and whether they're applied
+  migrate create <name>        add an empty migration for every driver
+  policy plan -f path [--prune]
+                               show how the permissions and roles defined in
+                               the YAML at path differ from the database
+  policy apply -f path [--prune] [--auto-approve] [--actor name]
+                               plan, confirm and apply the changes in one
+                               transaction, --prune also deletes what isn't
+                               defined
+  policy test -f path [-t tests]
+                               check the .policytest assertions under tests
+                               (path, or its directory when it's a file, by
+                               default) against the YAML at path
+  policy simulate -f path [--prune] [-decisions log.jsonl]... [-t tests]
+                               replay decision logs and assertion checks
+                               against the database's policy and as apply
+                               would leave it, printing the decisions that
+                               flip`
+
+// cfg and setup shit right hurr, we gotta alter it for my database setup
+type config struct {
+	ServiceName string `env:"SERVICE_NAME" envDefault:"bestir-permissionmaking-service"`
+	Env         string `env:"ENV" envDefault:"local"`
+	Database    struct {
+		// Driver is "mysql", "postgres" or "sqlite", use postgres for
+		// CockroachDB. With sqlite DBName is the path of the database file
+		// and User and Pass aren't needed.
+		Driver string `env:"permission_DB_DRIVER" envDefault:"mysql"`
+		User   string `env:"permission_DB_USER"`
+		Pass   string `env:"permission_DB_PASSWORD"`
+		Host   string `env:"permission_DB_HOST"`
+		// Port defaults to the driver's standard port when empty.
+		Port   string `env:"permission_DB_PORT"`
+		DBName string `env:"permission_DB_Name" envDefault:"permission"`
+		Params string `env:"permission_DB_Param_Overrides" envDefault:"parseTime=true"`
+		TLS    struct {
+			Enable bool `env:"permission_DB_TLS"`
+			// CAFile is a custom CA bundle, the system roots are used when empty.
+			CAFile     string `env:"permission_DB_TLS_CA"`
+			CertFile   string `env:"permission_DB_TLS_CERT"`
+			KeyFile    string `env:"permission_DB_TLS_KEY"`
+			ServerName string `env:"permission_DB_TLS_SERVER_NAME"`
+			SkipVerify bool   `env:"permission_DB_TLS_SKIP_VERIFY"`
+		}
+		MaxOpenConns    int           `env:"permission_DB_MAX_OPEN_CONNS" envDefault:"25"`
+		MaxIdleConns    int           `env:"permission_DB_MAX_IDLE_CONNS" envDefault:"10"`
+		ConnMaxLifetime time.Duration `env:"permission_DB_CONN_MAX_LIFETIME" envDefault:"5m"`
+		ConnMaxIdleTime time.Duration `env:"permission_DB_CONN_MAX_IDLE_TIME" envDefault:"1m"`
+		// Replicas are read replicas of the primary as "host" or "host:port",
+		// they share its credentials and settings. MySQL only.
+		Replicas []string `env:"permission_DB_REPLICAS" envSeparator:","`
+		// ReplicaMaxLag takes a replica out of rotation once it falls this far
+		// behind, it's checked every ReplicaLagInterval.
+		ReplicaMaxLag      time.Duration `env:"permission_DB_REPLICA_MAX_LAG" envDefault:"5s"`
+		ReplicaLagInterval time.Duration `env:"permission_DB_REPLICA_LAG_INTERVAL" envDefault:"5s"`
+	}
+	Datadog struct {
+		Disable    bool   `env:"DD_DISABLE"`
+		AgentHost  string `env:"DD_AGENT_HOST" envDefault:"localhost"`
+		TracePort  int    `env:"DD_TRACE_AGENT_PORT" envDefault:"8126"`
+		StatsdPort int    `env:"DD_DOGSTATSD_PORT" envDefault:"8125"`
+		// DBStatsInterval is how often the connection pool stats are sent to statsd.
+		DBStatsInterval time.Duration `env:"DD_DB_STATS_INTERVAL" envDefault:"10s"`
+	}
+	Tracing struct {
+		// Provider selects the tracer, "datadog" or "otel". The OTLP
+		// exporter reads the standard OTEL_EXPORTER_OTLP_* variables.
+		Provider string `env:"TRACING_PROVIDER" envDefault:"datadog"`
+	}
+	Migration struct {
+		Enable bool `env:"ENABLE_MIGRATE"`
+	}
+	Retention struct {
+		// Period is how long deleted roles and permissions can be restored
+		// before they're purged, zero keeps them forever.
+		Period   time.Duration `env:"RETENTION_PERIOD" envDefault:"720h"`
+		Interval time.Duration `env:"RETENTION_INTERVAL" envDefault:"1h"`
+	}
+	Audit struct {
+		// TrustActorHeader audits X-Actor as is. Only set it when a gateway
+		// in front of the service strips X-Actor from client requests and
+		// sets it from the authenticated caller, otherwise anyone can claim
+		// to be anyone and actors are recorded as claimed:<actor>.
+		TrustActorHeader bool `env:"AUDIT_TRUST_ACTOR_HEADER"`
+	}
+	Outbox struct {
+		// Sink is where permission and role events are published: "stdout",
+		// "file" (appended to File as JSON lines) or "http" (POSTed to URL).
+		// Empty only hands them to webhooks, or leaves them in the outbox
+		// table when those are off too.
+		Sink     string        `env:"OUTBOX_SINK"`
+		File     string        `env:"OUTBOX_FILE" envDefault:"events.jsonl"`
+		URL      string        `env:"OUTBOX_URL"`
+		Timeout  time.Duration `env:"OUTBOX_TIMEOUT" envDefault:"5s"`
+		Interval time.Duration `env:"OUTBOX_INTERVAL" envDefault:"1s"`
+		// Retention is how long published events stay in the outbox for
+		// /watch to replay, zero keeps them forever. They're purged every
+		// RETENTION_INTERVAL.
+		Retention time.Duration `env:"OUTBOX_RETENTION" envDefault:"168h"`
+	}
+	DecisionLog struct {
+		// Path is the JSONL file decisions made on /check are appended to,
+		// rotated once it reaches MaxSize bytes. Empty logs none.
+		Path       string  `env:"DECISION_LOG_PATH"`
+		SampleRate float64 `env:"DECISION_LOG_SAMPLE_RATE" envDefault:"1"`
+		// Redact names the context attributes whose values are never logged.
+		Redact     []string `env:"DECISION_LOG_REDACT" envSeparator:","`
+		MaxSize    int64    `env:"DECISION_LOG_MAX_SIZE" envDefault:"104857600"`
+		MaxBackups int      `env:"DECISION_LOG_MAX_BACKUPS" envDefault:"5"`
+	}
+	Webhook struct {
+		// Enable queues outbox events for webhook subscriptions and delivers
+		// them, checking for due deliveries every Interval.
+		Enable      bool          `env:"WEBHOOK_ENABLE" envDefault:"true"`
+		Interval    time.Duration `env:"WEBHOOK_INTERVAL" envDefault:"1s"`
+		Timeout     time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"5s"`
+		MaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"10"`
+		// a failed delivery waits BackoffBase, doubling every attempt up to
+		// BackoffMax
+		BackoffBase time.Duration `env:"WEBHOOK_BACKOFF_BASE" envDefault:"10s"`
+		BackoffMax  time.Duration `env:"WEBHOOK_BACKOFF_MAX" envDefault:"1h"`
+	}
+	Shutdown struct {
+		// DrainPeriod is how long /readyz reports not-ready before the
+		// server stops accepting connections, it should cover at least one
+		// readiness probe interval of the orchestrator.
+		DrainPeriod time.Duration `env:"SHUTDOWN_DRAIN_PERIOD" envDefault:"5s"`
+	}
+}
+
+func loadConfig() (config, error) {
+	var cfg config
+	if err := env.Parse(&cfg); err != nil {
+		return config{}, errors.Wrap(err, "parsing configuration")
+	}
+	if cfg.Database.Driver != database.DriverSQLite && (cfg.Database.User == "" || cfg.Database.Pass == "") {
+		return config{}, errors.New("parsing configuration: permission_DB_USER and permission_DB_PASSWORD are required")
+	}
+	return cfg, nil
+}
+
+func serve(ctx context.Context, cfg config) error {
 	// aws shit, currently unsupported, but soon
 	//wsCfg, err := aws.NewConfig(ctx)
 	//f err != nil {
@@ -69,28 +255,6 @@ func run(ctx context.Context, _ []string) error {
 
 	// open api shit for documentation
 
-	// cfg and setup shit right hurr, we gotta alter it for my database setup
-	var cfg struct {
-		ServiceName string `env:"SERVICE_NAME" envDefault:"bestir-permissionmaking-service"`
-		Env         string `env:"ENV" envDefault:"local"`
-		Database    struct {
-			User   string `env:"permission_DB_USER,required"`
-			Pass   string `env:"permission_DB_PASSWORD,required"`
-			Host   string `env:"permission_DB_HOST"`
-			Port   string `env:"permission_DB_PORT" envDefault:"3306"`
-			DBName string `env:"permission_DB_Name" envDefault:"permission"`
-			Params string `env:"permission_DB_Param_Overrides" envDefault:"parseTime=true"`
-		}
-		Datadog struct {
-			Disable bool `env:"DD_DISABLE"`
-		}
-		Migration struct {
-			Enable bool `env:"ENABLE_MIGRATE"`
-		}
-	}
-	if err := env.Parse(&cfg); err != nil {
-		return errors.Wrap(err, "parsing configuration")
-	}
 	// cfg.Datadog.Disable = true
 
 	// Create base logger
@@ -111,41 +275,60 @@ func run(ctx context.Context, _ []string) error {
 		zap.String("version.git_sha", GitSHA),
 		zap.String("env", cfg.Env),
 	)
-	// zl := bestirlog.WrapZap(z)
+	zl := bestirlog.WrapZap(z)
 
 	// Intitialize tracing
-
-	/*
-		if !cfg.Datadog.Disable {
-			// Configure tracing
+	var tracingMW web.Middleware
+	switch {
+	case cfg.Tracing.Provider == database.TracerOTel:
+		tp, err := tracing.NewOTelProvider(ctx, tracing.OTelConfig{
+			ServiceName: cfg.ServiceName,
+			Version:     GitSHA,
+			Env:         cfg.Env,
+		})
+		if err != nil {
+			return errors.Wrap(err, "starting otel tracer")
 		}
-	*/
+		defer func() {
+			// ctx is already canceled by the time we get here
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tp.Shutdown(ctx); err != nil {
+				zl.Error(ctx, "flushing otel spans", zap.Error(err))
+			}
+		}()
+		tracingMW = tracing.OTel()
+	case !cfg.Datadog.Disable:
+		tracer.Start(
+			tracer.WithService(cfg.ServiceName),
+			tracer.WithEnv(cfg.Env),
+			tracer.WithServiceVersion(GitSHA),
+			tracer.WithAgentAddr(ddAgentAddress(cfg.Datadog.AgentHost, cfg.Datadog.TracePort)),
+		)
+		defer tracer.Stop()
+		tracingMW = tracing.Datadog(cfg.ServiceName)
+	}
 
-	// Migrate - issa broken Luigi
-	// if err := goose.EnsureMigrations(ctx, zl, goose.Config{
-	// 	User:     cfg.Database.User,
-	// 	Password: cfg.Database.Pass,
-	// 	Port:     cfg.Database.Port,
-	// 	Host:     cfg.Database.Host,
-	// 	DryRun:   !cfg.Migration.Enable,
-	// 	Name:     cfg.Database.DBName,
-	// }); err != nil {
-	// 	return err
-	// }
+	// Migrate, or with ENABLE_MIGRATE unset just report how far behind the
+	// database is. Replicas starting together queue up on an advisory lock so
+	// only the first one actually migrates.
+	mcfg, err := migrateConfig(cfg)
+	if err != nil {
+		return err
+	}
+	mcfg.DryRun = !cfg.Migration.Enable
+	if err := migrate.EnsureMigrations(ctx, zl, mcfg); err != nil {
+		return errors.Wrap(err, "migrating database")
+	}
 
 	// dsn: usr:identity@tcp(127.0.0.1:3306)/identity
-	db, err := database.Open(database.Config{
-		User:     cfg.Database.User,
-		Password: cfg.Database.Pass,
-		Host:     cfg.Database.Host,
-		Name:     cfg.Database.DBName,
-		Params:   cfg.Database.Params,
-	}, cfg.ServiceName)
+	dbCfg := dbConfig(cfg)
+	db, err := database.Open(dbCfg, cfg.ServiceName)
 	if err != nil {
 		return errors.Wrap(err, "connecting to db")
 	}
 	defer func() {
-		// zl.Info(ctx, "stopping database")
+		zl.Info(ctx, "stopping database")
 		db.Close()
 	}()
 
@@ -181,33 +364,160 @@ func run(ctx context.Context, _ []string) error {
 	//*/
 
 	// If DD is enabled, configure db to send stats info
-	//if !cfg.Datadog.Disable {
-	// statsdAddress := ddAgentAddress(8125)
-	// statsd, err := statsd.New(statsdAddress, statsd.WithMaxBytesPerPayload(4096))
-	// if err != nil {
-	// 	return errors.Wrap(err, "could not start statsd client")
-	// }
-	// // Start Stats Reporting for db
-	// go func() {
-	// 	zl.Info(ctx, "Starting reporting DB metrics", zap.String("statsd.address", statsdAddress))
-	// 	defer zl.Info(ctx, "Stopped reporting DB metrics")
-	// 	sr := database.NewStatsReporter(db, statsd, zl)
-
-	// 	sr.ReportDBStats(ctx, []string{
-	// 		fmt.Sprintf("service:%s", cfg.ServiceName),
-	// 		fmt.Sprintf("version:%s", GitSHA),
-	// 		fmt.Sprintf("env:%s", cfg.Env),
-	// 		"collabs.squad:red",
-	// 	}, 1)
-	// }()
-	//}
+	if !cfg.Datadog.Disable {
+		statsdAddress := ddAgentAddress(cfg.Datadog.AgentHost, cfg.Datadog.StatsdPort)
+		statsd, err := statsd.New(statsdAddress, statsd.WithMaxBytesPerPayload(4096))
+		if err != nil {
+			return errors.Wrap(err, "could not start statsd client")
+		}
+		defer statsd.Close()
+		// Start Stats Reporting for db
+		go func() {
+			zl.Info(ctx, "Starting reporting DB metrics", zap.String("statsd.address", statsdAddress))
+			defer zl.Info(ctx, "Stopped reporting DB metrics")
+			sr := database.NewStatsReporter(db, statsd, zl)
+
+			sr.ReportDBStats(ctx, []string{
+				fmt.Sprintf("service:%s", cfg.ServiceName),
+				fmt.Sprintf("version:%s", GitSHA),
+				fmt.Sprintf("env:%s", cfg.Env),
+				"collabs.squad:red",
+			}, 1, cfg.Datadog.DBStatsInterval)
+		}()
+	}
+
+	// Prometheus metrics are always on, they're only exposed on /metrics and
+	// cost nothing when nobody scrapes them
+	m := metrics.New()
+	m.RegisterDB(db, cfg.Database.DBName)
 
 	// CLIENTS N SHIT
 
-	// event bridge shit
+	// db stays around for health checks and pool metrics either way, the
+	// postgres stores get their own pgx pool
+	var (
+		permissions permission.Store
+		roles       role.Store
+		audits      audit.Store
+		events      outbox.Store
+		webhooks    webhook.Store
+		snapshots   snapshot.Store
+	)
+	if len(cfg.Database.Replicas) > 0 && cfg.Database.Driver != database.DriverMySQL {
+		return errors.New("read replicas are only supported with the mysql driver")
+	}
+	switch cfg.Database.Driver {
+	case database.DriverPostgres:
+		pool, err := database.OpenPgx(ctx, dbCfg)
+		if err != nil {
+			return errors.Wrap(err, "connecting to db with pgx")
+		}
+		defer pool.Close()
+		permissions, roles = permission.NewCockroachDBStore(pool), role.NewCockroachDBStore(pool)
+		audits, events = audit.NewCockroachDBStore(pool), outbox.NewCockroachDBStore(pool)
+		webhooks, snapshots = webhook.NewCockroachDBStore(pool), snapshot.NewCockroachDBStore(pool)
+	case database.DriverSQLite:
+		permissions, roles = permission.NewSQLiteStore(db), role.NewSQLiteStore(db)
+		audits, events = audit.NewSQLiteStore(db), outbox.NewSQLiteStore(db)
+		webhooks, snapshots = webhook.NewSQLiteStore(db), snapshot.NewSQLiteStore(db)
+	default:
+		dbrConn := database.NewDBR(db)
+		permissions, roles = permission.NewMySQLStore(dbrConn), role.NewMySQLStore(dbrConn)
+		audits, events = audit.NewMySQLStore(dbrConn), outbox.NewMySQLStore(dbrConn)
+		webhooks, snapshots = webhook.NewMySQLStore(dbrConn), snapshot.NewMySQLStore(dbrConn)
+		if len(cfg.Database.Replicas) == 0 {
+			break
+		}
+
+		var replicaConns []*dbr.Connection
+		for _, addr := range cfg.Database.Replicas {
+			rdb, err := database.Open(database.ReplicaConfig(dbCfg, addr), cfg.ServiceName)
+			if err != nil {
+				return errors.Wrapf(err, "connecting to replica %s", addr)
+			}
+			defer rdb.Close()
+			replicaConns = append(replicaConns, database.NewDBR(rdb))
+		}
+		replicas := database.NewReplicas(dbrConn, replicaConns, cfg.Database.ReplicaMaxLag)
+		replicas.HasRevision = outbox.HasRevision
+		zl.Info(ctx, "read replicas configured",
+			zap.Int("replicas", len(replicaConns)),
+			zap.Int("usable", replicas.CheckLag(ctx)),
+		)
+		go replicas.MonitorLag(ctx, cfg.Database.ReplicaLagInterval)
+		permissions = permission.NewReplicatedMySQLStore(dbrConn, replicas)
+		roles = role.NewReplicatedMySQLStore(dbrConn, replicas)
+		snapshots = snapshot.NewReplicatedMySQLStore(dbrConn, replicas)
+	}
+
+	if cfg.Retention.Period > 0 {
+		go purgeDeleted(ctx, zl, permission.NewAPI(permissions), role.NewAPI(roles), cfg.Retention.Period, cfg.Retention.Interval)
+	}
+
+	if cfg.Outbox.Retention > 0 {
+		go purgePublished(ctx, zl, events, cfg.Outbox.Retention, cfg.Retention.Interval)
+	}
+
+	// event bridge, downstream caches invalidate on these
+	var publishers outbox.Publishers
+	if cfg.Outbox.Sink != "" {
+		publisher, closePublisher, err := newPublisher(cfg)
+		if err != nil {
+			return err
+		}
+		defer closePublisher()
+		publishers = append(publishers, publisher)
+	}
+	if cfg.Webhook.Enable {
+		dispatcher := webhook.NewDispatcher(webhooks)
+		dispatcher.Client.Timeout = cfg.Webhook.Timeout
+		dispatcher.MaxAttempts = cfg.Webhook.MaxAttempts
+		dispatcher.BackoffBase, dispatcher.BackoffMax = cfg.Webhook.BackoffBase, cfg.Webhook.BackoffMax
+		publishers = append(publishers, dispatcher)
+		go dispatcher.Run(ctx, cfg.Webhook.Interval, func(err error) {
+			zl.Error(ctx, "delivering webhooks", zap.Error(err))
+		})
+	}
+	// the relay runs without publishers too, /watch needs the events
+	// sequenced
+	relay := outbox.NewRelay(events, nil)
+	if len(publishers) > 0 {
+		relay.Publisher = publishers
+	}
+	go relay.Run(ctx, cfg.Outbox.Interval, func(err error) {
+		zl.Error(ctx, "publishing outbox events", zap.Error(err))
+	})
+
+	var decisions *decisionlog.Logger
+	if cfg.DecisionLog.Path != "" {
+		f, err := decisionlog.OpenRotatingFile(cfg.DecisionLog.Path, cfg.DecisionLog.MaxSize, cfg.DecisionLog.MaxBackups)
+		if err != nil {
+			return errors.Wrap(err, "opening decision log")
+		}
+		defer f.Close()
+		decisions = decisionlog.New(f, decisionlog.Config{
+			SampleRate: cfg.DecisionLog.SampleRate,
+			Redact:     cfg.DecisionLog.Redact,
+		})
+	}
 
-	// we gott reconfigure the service to use pgx now
-	h := handler.API(handler.Deps{DB: db})
+	h := handler.API(handler.Deps{
+		DB:               db,
+		Permissions:      permissions,
+		Roles:            roles,
+		Audit:            audits,
+		TrustActorHeader: cfg.Audit.TrustActorHeader,
+		Events:           events,
+		Webhooks:         webhooks,
+		Snapshots:        snapshots,
+		MigrationVersion: migrate.DesiredVersion,
+		DecisionLog:      decisions,
+		OnDecisionLogError: func(err error) {
+			zl.Error(ctx, "writing decision log", zap.Error(err))
+		},
+		Metrics: m,
+		Tracing: tracingMW,
+	})
 
 	// Start API Service
 	api := http.Server{
@@ -235,6 +545,15 @@ func run(ctx context.Context, _ []string) error {
 	case <-ctx.Done():
 		// log something
 
+		// fail readiness and give the orchestrator time to notice before we
+		// stop taking new connections
+		h.Drain()
+		select {
+		case <-time.After(cfg.Shutdown.DrainPeriod):
+		case err := <-serverErrors:
+			return errors.Wrap(err, "server error")
+		}
+
 		// request a deadline for completion
 		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 		defer cancel()
@@ -247,3 +566,34 @@ func run(ctx context.Context, _ []string) error {
 
 	return nil
 }
+
+// dbConfig is the database config for cfg.
+func dbConfig(cfg config) database.Config {
+	return database.Config{
+		User:     cfg.Database.User,
+		Password: cfg.Database.Pass,
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		Name:     cfg.Database.DBName,
+		Params:   cfg.Database.Params,
+		Driver:   cfg.Database.Driver,
+		Tracer:   cfg.Tracing.Provider,
+		TLS: database.TLS{
+			Enable:     cfg.Database.TLS.Enable,
+			CAFile:     cfg.Database.TLS.CAFile,
+			CertFile:   cfg.Database.TLS.CertFile,
+			KeyFile:    cfg.Database.TLS.KeyFile,
+			ServerName: cfg.Database.TLS.ServerName,
+			SkipVerify: cfg.Database.TLS.SkipVerify,
+		},
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+	}
+}
+
+// ddAgentAddress is where the DataDog agent listens on the given port.
+func ddAgentAddress(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
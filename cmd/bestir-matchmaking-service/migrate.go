@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+// runMigrate runs the migrate subcommands, so the schema can be managed with
+// the same image that's deployed rather than a separately installed goose.
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate needs a subcommand\n%s", usage)
+	}
+	cmd, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("migrate "+cmd, flag.ContinueOnError)
+	to := fs.Int64("to", 0, "version to migrate to")
+	dryRun := fs.Bool("dry-run", false, "report the current and target versions without migrating")
+	dir := fs.String("dir", "db", "db directory of the checkout, for create")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// create only touches files, it doesn't need a database
+	if cmd == "create" {
+		if fs.NArg() != 1 {
+			return fmt.Errorf("migrate create needs a name\n%s", usage)
+		}
+		version, err := migrate.Create(*dir, fs.Arg(0), time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created migration %d, version.txt bumped\n", version)
+		return nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	mcfg, err := migrateConfig(cfg)
+	if err != nil {
+		return err
+	}
+	mcfg.DryRun = *dryRun
+	src := migrate.SourceFor(mcfg.Driver)
+
+	switch cmd {
+	case "up":
+		target := migrate.DesiredVersion
+		if isSet(fs, "to") {
+			target = *to
+		}
+		return errors.Wrap(migrate.MigrateUp(ctx, mcfg, src, target), "migrating up")
+	case "down":
+		// down always needs an explicit target, rolling back is too
+		// destructive to default to anything
+		if !isSet(fs, "to") {
+			return fmt.Errorf("migrate down needs --to\n%s", usage)
+		}
+		return errors.Wrap(migrate.MigrateDown(ctx, mcfg, src, *to), "migrating down")
+	case "status":
+		current, statuses, err := migrate.Status(ctx, mcfg, src)
+		if err != nil {
+			return errors.Wrap(err, "getting migration status")
+		}
+		return printStatus(os.Stdout, current, statuses)
+	}
+	return fmt.Errorf("unknown migrate command %q\n%s", cmd, usage)
+}
+
+// migrateConfig is the migration config for the database in cfg. The
+// migrations open their own connection, so the TLS config is registered with
+// the driver here rather than waiting for database.Open.
+func migrateConfig(cfg config) (migrate.Config, error) {
+	dbCfg := dbConfig(cfg)
+	if err := database.RegisterTLS(dbCfg); err != nil {
+		return migrate.Config{}, errors.Wrap(err, "configuring db tls")
+	}
+	tlsParams := database.TLSParams(dbCfg)
+	params := make(map[string]string, len(tlsParams))
+	for k := range tlsParams {
+		params[k] = tlsParams.Get(k)
+	}
+	return migrate.Config{
+		Driver:           cfg.Database.Driver,
+		User:             cfg.Database.User,
+		Password:         cfg.Database.Pass,
+		Port:             cfg.Database.Port,
+		Host:             cfg.Database.Host,
+		Name:             cfg.Database.DBName,
+		AdditionalParams: params,
+	}, nil
+}
+
+func printStatus(w io.Writer, current int64, statuses []migrate.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "current version %d, build expects %d\n\n", current, migrate.DesiredVersion)
+	fmt.Fprintln(tw, "VERSION\tAPPLIED\tSOURCE")
+	for _, s := range statuses {
+		fmt.Fprintf(tw, "%d\t%t\t%s\n", s.Version, s.Applied, s.Source)
+	}
+	return tw.Flush()
+}
+
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
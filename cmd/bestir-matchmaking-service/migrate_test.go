@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+)
+
+func TestRunMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permission.db")
+	t.Setenv("permission_DB_DRIVER", migrate.DriverSQLite)
+	t.Setenv("permission_DB_Name", path)
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	version := func() int64 {
+		t.Helper()
+		v, err := migrate.CurrentVersion(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	const older = 20261019090400
+	if err := runMigrate(ctx, []string{"up"}); err != nil {
+		t.Fatal(err)
+	}
+	diff(t, migrate.DesiredVersion, version())
+
+	t.Run("UpToOlder", func(t *testing.T) {
+		if err := runMigrate(ctx, []string{"up", "--to", strconv.Itoa(older)}); err == nil {
+			t.Error("migrate up rolled back")
+		}
+		diff(t, migrate.DesiredVersion, version())
+	})
+
+	t.Run("Down", func(t *testing.T) {
+		if err := runMigrate(ctx, []string{"down", "--to", strconv.Itoa(older)}); err != nil {
+			t.Fatal(err)
+		}
+		diff(t, int64(older), version())
+	})
+
+	t.Run("DownToNewer", func(t *testing.T) {
+		if err := runMigrate(ctx, []string{"down", "--to", strconv.FormatInt(migrate.DesiredVersion, 10)}); err == nil {
+			t.Error("migrate down migrated up")
+		}
+		diff(t, int64(older), version())
+	})
+
+	t.Run("Up", func(t *testing.T) {
+		if err := runMigrate(ctx, []string{"up"}); err != nil {
+			t.Fatal(err)
+		}
+		diff(t, migrate.DesiredVersion, version())
+	})
+}
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+// newPublisher builds the publisher cfg.Outbox.Sink names, close releases
+// whatever it opened.
+func newPublisher(cfg config) (publisher outbox.Publisher, close func(), err error) {
+	switch cfg.Outbox.Sink {
+	case "stdout":
+		return outbox.NewWriterPublisher(os.Stdout), func() {}, nil
+	case "file":
+		f, err := os.OpenFile(cfg.Outbox.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "opening outbox file")
+		}
+		return outbox.NewWriterPublisher(f), func() { f.Close() }, nil
+	case "http":
+		if cfg.Outbox.URL == "" {
+			return nil, nil, errors.New("OUTBOX_URL is required with the http sink")
+		}
+		return outbox.NewHTTPPublisher(cfg.Outbox.URL, cfg.Outbox.Timeout), func() {}, nil
+	}
+	return nil, nil, errors.Errorf("unknown outbox sink %q", cfg.Outbox.Sink)
+}
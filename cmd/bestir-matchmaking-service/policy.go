@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/policytest"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/simulate"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+)
+
+// runPolicy runs the policy subcommands, which sync the permissions and
+// roles defined in YAML files, usually a directory of a git repo, to the
+// database: plan shows what would change, apply changes it in one
+// transaction. test checks assertions against the definitions without
+// touching the database, simulate shows which recorded decisions applying
+// them would flip.
+func runPolicy(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("policy needs a subcommand\n%s", usage)
+	}
+	cmd, args := args[0], args[1:]
+	if cmd != "plan" && cmd != "apply" && cmd != "test" && cmd != "simulate" {
+		return fmt.Errorf("unknown policy command %q\n%s", cmd, usage)
+	}
+
+	fs := flag.NewFlagSet("policy "+cmd, flag.ContinueOnError)
+	path := fs.String("f", "", "YAML file or directory of YAML files with the policy definitions")
+	prune := fs.Bool("prune", false, "delete permissions and roles that aren't defined")
+	autoApprove := fs.Bool("auto-approve", false, "apply without asking for confirmation")
+	actor := fs.String("actor", "policy-apply", "who the changes are attributed to in the audit log")
+	tests := fs.String("t", "", "assertion file or directory of them for test, next to -f when empty")
+	var decisionLogs stringsFlag
+	fs.Var(&decisionLogs, "decisions", "decision log to replay for simulate, can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return fmt.Errorf("policy %s needs -f\n%s", cmd, usage)
+	}
+
+	snap, err := snapshot.Load(*path)
+	if err != nil {
+		return errors.Wrap(err, "loading policy")
+	}
+	if cmd == "test" {
+		if *tests == "" {
+			*tests = assertionsNextTo(*path)
+		}
+		return runPolicyTest(ctx, os.Stdout, snap, *tests)
+	}
+	mode := snapshot.ModeMerge
+	if *prune {
+		mode = snapshot.ModeReplace
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, closeStore, err := openSnapshotStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	api := snapshot.NewAPI(store)
+
+	if cmd == "simulate" {
+		return runPolicySimulate(ctx, os.Stdout, api, snap, mode, decisionLogs, *tests)
+	}
+
+	changes, summary, err := api.Plan(ctx, snap, mode)
+	if err != nil {
+		return errors.Wrap(err, "planning")
+	}
+	printPlan(os.Stdout, changes, summary)
+	if cmd == "plan" || len(changes) == 0 {
+		return nil
+	}
+
+	if !*autoApprove && !confirm(os.Stdin, os.Stdout) {
+		fmt.Println("Apply cancelled.")
+		return nil
+	}
+	// the plan is made again inside the transaction, a change made since
+	// the one shown aborts the apply rather than apply something unseen
+	ctx = audit.WithRequest(ctx, *actor, uuid.NewString())
+	summary, err = api.Apply(ctx, snap, mode, changes)
+	if errors.Is(err, snapshot.ErrStalePlan) {
+		return fmt.Errorf("nothing applied, %v: run policy apply again to review the new plan", snapshot.ErrStalePlan)
+	}
+	if err != nil {
+		return errors.Wrap(err, "applying")
+	}
+	added, changed, deleted := summaryCounts(summary)
+	fmt.Printf("Apply complete! %d added, %d changed, %d deleted.\n", added, changed, deleted)
+	return nil
+}
+
+// openSnapshotStore connects to the database in cfg, the returned func
+// closes the connection.
+func openSnapshotStore(ctx context.Context, cfg config) (snapshot.Store, func(), error) {
+	dbCfg := dbConfig(cfg)
+	if cfg.Database.Driver == database.DriverPostgres {
+		pool, err := database.OpenPgx(ctx, dbCfg)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "connecting to db with pgx")
+		}
+		return snapshot.NewCockroachDBStore(pool), pool.Close, nil
+	}
+
+	db, err := database.Open(dbCfg, cfg.ServiceName)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "connecting to db")
+	}
+	closeDB := func() { db.Close() }
+	if cfg.Database.Driver == database.DriverSQLite {
+		return snapshot.NewSQLiteStore(db), closeDB, nil
+	}
+	return snapshot.NewMySQLStore(database.NewDBR(db)), closeDB, nil
+}
+
+// printPlan writes changes the way terraform plans read: + creates, -
+// deletes and ~ changes a role's grants.
+func printPlan(w io.Writer, changes []snapshot.Change, summary snapshot.Summary) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No changes. The policy matches the definitions.")
+		return
+	}
+	for _, c := range changes {
+		switch c.Action {
+		case snapshot.ActionCreate:
+			fmt.Fprintf(w, "  + %s %q\n", c.Type, c.Name)
+		case snapshot.ActionRestore:
+			fmt.Fprintf(w, "  + %s %q (restored)\n", c.Type, c.Name)
+		case snapshot.ActionDelete:
+			fmt.Fprintf(w, "  - %s %q\n", c.Type, c.Name)
+		case snapshot.ActionRegrant:
+			fmt.Fprintf(w, "  ~ %s %q permissions\n", c.Type, c.Name)
+			for _, name := range c.Granted {
+				fmt.Fprintf(w, "      + %q\n", name)
+			}
+			for _, name := range c.Revoked {
+				fmt.Fprintf(w, "      - %q\n", name)
+			}
+		}
+	}
+	added, changed, deleted := summaryCounts(summary)
+	fmt.Fprintf(w, "\nPlan: %d to add, %d to change, %d to delete.\n", added, changed, deleted)
+}
+
+// summaryCounts totals s the way terraform does, restores count as adds.
+func summaryCounts(s snapshot.Summary) (added, changed, deleted int) {
+	added = s.PermissionsCreated + s.PermissionsRestored + s.RolesCreated + s.RolesRestored
+	deleted = s.PermissionsDeleted + s.RolesDeleted
+	return added, s.RolesRegranted, deleted
+}
+
+// confirm asks on out whether to apply, only "yes" read from in does.
+func confirm(in io.Reader, out io.Writer) bool {
+	fmt.Fprint(out, "\nApply these changes? Only 'yes' will be accepted: ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	return strings.TrimSpace(answer) == "yes"
+}
+
+// assertionsNextTo is where the assertions for the definitions at path
+// are by default: path itself when it's a directory, the directory it's in
+// when it's a file.
+func assertionsNextTo(path string) string {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// runPolicyTest checks the assertions at path against snap, printing every
+// result and the trace of the failures.
+func runPolicyTest(ctx context.Context, w io.Writer, snap snapshot.Snapshot, path string) error {
+	assertions, err := policytest.Load(path)
+	if err != nil {
+		return errors.Wrap(err, "loading assertions")
+	}
+	engine, err := policytest.NewEngine(ctx, snap)
+	if err != nil {
+		return errors.Wrapf(err, "loading policy: %v", bestirerror.Details(err))
+	}
+	if len(assertions) == 0 {
+		return fmt.Errorf("no %s files in %s", policytest.Ext, path)
+	}
+	results, err := policytest.Run(ctx, engine, assertions)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(w, "PASS %s\n", r.Assertion)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL %s\n    got %s:\n", r.Assertion, verdict(r.Decision))
+		for _, step := range r.Decision.Trace {
+			fmt.Fprintf(w, "      %s\n", step)
+		}
+	}
+	fmt.Fprintf(w, "\n%d assertions, %d failed\n", len(results), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d assertions failed", failed, len(results))
+	}
+	return nil
+}
+
+// runPolicySimulate replays the decision logs and the checks of the
+// assertions at tests, when given, against the policy in the database and
+// as applying snap in mode would leave it. Nothing is applied.
+func runPolicySimulate(ctx context.Context, w io.Writer, api *snapshot.API, snap snapshot.Snapshot, mode string, decisionLogs []string, tests string) error {
+	var (
+		queries []decision.Query
+		skipped int
+	)
+	for _, path := range decisionLogs {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		q, s, err := simulate.ReadDecisionLog(f)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "reading %s", path)
+		}
+		queries, skipped = append(queries, q...), skipped+s
+	}
+	if tests != "" {
+		assertions, err := policytest.Load(tests)
+		if err != nil {
+			return errors.Wrap(err, "loading assertions")
+		}
+		for _, a := range assertions {
+			queries = append(queries, a.Query)
+		}
+	}
+	if len(queries) == 0 && skipped == 0 {
+		return fmt.Errorf("policy simulate needs -decisions or -t\n%s", usage)
+	}
+
+	current, err := api.Export(ctx)
+	if err != nil {
+		return errors.Wrap(err, "exporting current policy")
+	}
+	proposed, err := simulate.Proposed(current, snap, mode)
+	if err != nil {
+		return errors.Wrapf(err, "loading proposed policy: %v", bestirerror.Details(err))
+	}
+	report, err := simulate.Run(ctx, current, proposed, queries)
+	if err != nil {
+		return err
+	}
+	report.Skipped = skipped
+
+	for _, f := range report.Flips {
+		fmt.Fprintf(w, "~ role:%s can %s: %s -> %s (%d checks)\n", f.Query.Role, f.Query.Permission,
+			verdict(f.Before), verdict(f.After), f.Count)
+		for _, step := range f.After.Trace {
+			fmt.Fprintf(w, "      %s\n", step)
+		}
+	}
+	fmt.Fprintf(w, "\n%d checks replayed, %d flipped, %d skipped (not role checks)\n", report.Checked, len(report.Flips), report.Skipped)
+	return nil
+}
+
+func verdict(d decision.Decision) string {
+	if d.Allowed {
+		return "allow"
+	}
+	return "deny"
+}
+
+// stringsFlag collects every value of a repeated flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
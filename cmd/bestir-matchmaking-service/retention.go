@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Max-Gabriel-Susman/bestir-go-kit/bestirlog"
+	"go.uber.org/zap"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+// purgeDeleted purges roles and permissions deleted more than retention ago
+// every interval until ctx is done. Roles go first so their grants are gone
+// before the permissions they point at.
+func purgeDeleted(ctx context.Context, zl *bestirlog.ZapLogger, permissions *permission.API, roles *role.API, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		purgedRoles, err := roles.PurgeRoles(ctx, retention)
+		if err != nil {
+			zl.Error(ctx, "purging deleted roles", zap.Error(err))
+			continue
+		}
+		purgedPermissions, err := permissions.Purgepermissions(ctx, retention)
+		if err != nil {
+			zl.Error(ctx, "purging deleted permissions", zap.Error(err))
+			continue
+		}
+		if purgedRoles > 0 || purgedPermissions > 0 {
+			zl.Info(ctx, "purged deleted roles and permissions",
+				zap.Int64("roles", purgedRoles),
+				zap.Int64("permissions", purgedPermissions),
+			)
+		}
+	}
+}
+
+// purgePublished purges outbox events published more than retention ago
+// every interval until ctx is done. Pending events are kept however old
+// they are.
+func purgePublished(ctx context.Context, zl *bestirlog.ZapLogger, events outbox.Store, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		purged, err := events.PurgePublished(ctx, time.Now().UTC().Add(-retention))
+		if err != nil {
+			zl.Error(ctx, "purging published outbox events", zap.Error(err))
+			continue
+		}
+		if purged > 0 {
+			zl.Info(ctx, "purged published outbox events", zap.Int64("events", purged))
+		}
+	}
+}
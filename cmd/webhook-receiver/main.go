@@ -0,0 +1,47 @@
+// Command webhook-receiver is a local endpoint for trying out webhook
+// subscriptions. It verifies each delivery's signature, prints the event
+// and answers with -status, so failures and retries can be provoked too.
+//
+//	go run ./cmd/webhook-receiver -secret whsec_... -addr :8081
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	secret := flag.String("secret", os.Getenv("WEBHOOK_SECRET"), "the subscription's secret, WEBHOOK_SECRET by default")
+	status := flag.Int("status", http.StatusNoContent, "status to answer valid deliveries with")
+	flag.Parse()
+	if *secret == "" {
+		log.Fatal("-secret is required")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := webhook.Verify(*secret, r.Header.Get(webhook.SignatureHeader), body, time.Now(), webhook.DefaultTolerance); err != nil {
+			log.Printf("rejected delivery %s: %v", r.Header.Get(webhook.DeliveryIDHeader), err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		fmt.Printf("%s %s %s\n", r.Header.Get(webhook.DeliveryIDHeader), r.Header.Get(webhook.EventTypeHeader), body)
+		w.WriteHeader(*status)
+	})
+
+	log.Printf("receiving webhooks on %s", *addr)
+	server := http.Server{Addr: *addr, ReadHeaderTimeout: 2 * time.Second}
+	log.Fatal(server.ListenAndServe())
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// versionFormat is the goose timestamp format our migration versions use.
+const versionFormat = "20060102150405"
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+const migrationTemplate = `-- +goose Up
+
+-- +goose Down
+`
+
+// Create adds an empty migration called name to every source under root
+// (the db directory of a checkout) and bumps version.txt to it, so the next
+// build migrates up to it. The sources have to stay in step, so the same
+// version is written for each driver. It returns the new version.
+func Create(root, name string, now time.Time) (int64, error) {
+	if !migrationName.MatchString(name) {
+		return 0, fmt.Errorf("migration name %q must be lower case letters, digits and underscores", name)
+	}
+	v := now.UTC().Format(versionFormat)
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if version <= DesiredVersion {
+		return 0, fmt.Errorf("new version %d isn't after the current version %d", version, DesiredVersion)
+	}
+
+	for _, s := range []MigrationSource{src, postgresSrc, sqliteSrc} {
+		path := filepath.Join(root, filepath.FromSlash(s.Dir), v+"_"+name+".sql")
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return 0, fmt.Errorf("unable to create migration: %w", err)
+		}
+		_, err = f.WriteString(migrationTemplate)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return 0, fmt.Errorf("unable to write migration %s: %w", path, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "version.txt"), []byte(v+"\n"), 0o644); err != nil {
+		return 0, fmt.Errorf("unable to bump version.txt: %w", err)
+	}
+	return version, nil
+}
@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"embed"
 	"fmt"
 	"log"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
@@ -14,9 +16,15 @@ import (
 
 	"go.uber.org/zap"
 
+	_ "github.com/go-sql-driver/mysql" // registers the "mysql" driver
+	_ "github.com/jackc/pgx/v4/stdlib" // registers the "pgx" driver goose uses for postgres
 	"github.com/pressly/goose/v3"
+	gomysqllock "github.com/sanketplus/go-mysql-lock"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
 
 	"github.com/Max-Gabriel-Susman/bestir-go-kit/bestirlog"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
 )
 
 var (
@@ -24,11 +32,25 @@ var (
 	version string
 	//go:embed migrations/*.sql
 	embedMigrations embed.FS
+	//go:embed postgres/migrations/*.sql
+	embedPostgresMigrations embed.FS
+	//go:embed sqlite/migrations/*.sql
+	embedSQLiteMigrations embed.FS
 
 	src = MigrationSource{
 		Migrations: embedMigrations,
 		Dir:        "migrations", // embedded directory name
 	}
+	// postgresSrc and sqliteSrc mirror src for the other drivers, every
+	// migration in src must have a counterpart in each with the same version.
+	postgresSrc = MigrationSource{
+		Migrations: embedPostgresMigrations,
+		Dir:        "postgres/migrations",
+	}
+	sqliteSrc = MigrationSource{
+		Migrations: embedSQLiteMigrations,
+		Dir:        "sqlite/migrations",
+	}
 
 	// Parse version from version.txt as an int64
 	DesiredVersion = func() int64 {
@@ -48,7 +70,18 @@ func EnsureMigrations(ctx context.Context, lgr *bestirlog.ZapLogger, cfg Config)
 	// Override Gooses default logger with a copy of our existing logger
 	cfg.LoggerOverride = WrapLogger(migrationLogger)
 
-	return Migrate(ctx, cfg, src, DesiredVersion)
+	return Migrate(ctx, cfg, SourceFor(cfg.Driver), DesiredVersion)
+}
+
+// SourceFor returns the embedded migrations for the given driver.
+func SourceFor(driver string) MigrationSource {
+	switch driver {
+	case DriverPostgres:
+		return postgresSrc
+	case DriverSQLite:
+		return sqliteSrc
+	}
+	return src
 }
 
 // MigrationSource wraps the embedded migrations filesystem and the directory name (its a convenience thing)
@@ -57,8 +90,25 @@ type MigrationSource struct {
 	Dir        string // Name of the embedded migrations directory
 }
 
+// Drivers the migrations are written for, the database package's.
+const (
+	DriverMySQL    = database.DriverMySQL
+	DriverPostgres = database.DriverPostgres
+	DriverSQLite   = database.DriverSQLite
+)
+
+const (
+	// lockName is the MySQL named lock held while migrating, so that when
+	// several replicas start at once only one of them migrates and the rest
+	// wait for it and then find nothing to do.
+	lockName = "goose-migration"
+	// lockTimeout is how many seconds to wait for lockName.
+	lockTimeout = 60
+)
+
 // Config holds the information needed to run the migrations
 type Config struct {
+	Driver           string // DriverMySQL when empty
 	User             string
 	Password         string
 	Host             string
@@ -70,30 +120,118 @@ type Config struct {
 	DryRun         bool
 }
 
-// Migrate connects to the database described in the Config,
-// and migrates it up or down based on if the current database version is different from the passed
-// in desiredVersion
+// Migrate connects to the database described in the Config and migrates it
+// up to desiredVersion. It never migrates down: a database ahead of
+// desiredVersion was migrated by a newer build, and an older instance still
+// running in a rolling deploy, or a rollback, must not drop the tables that
+// build added along with their data. That's logged and the database is left
+// alone, rolling back is MigrateDown's job.
 func Migrate(ctx context.Context, cfg Config, src MigrationSource, desiredVersion int64) error {
 	fmt.Println("ensure migrate")
-	dsn := func() string {
-		q := make(url.Values)
-		for k, v := range cfg.AdditionalParams {
-			q.Add(k, v)
+	return withLock(ctx, cfg, src, desiredVersion, func(db *sql.DB, l goose.Logger, dbVersion int64) error {
+		if dbVersion > desiredVersion {
+			l.Print("Current DB Version: ", dbVersion, " is ahead of Desired DB Version: ", desiredVersion, ", not migrating down")
+			return nil
 		}
-		// Note: for MySQL parseTime flag must be enabled.
-		q.Set("parseTime", "true")
-		// Note: for MySQL multiStatements must be enabled.
-		//	This is required when writing multiple queries separated by ';' characters in a single sql file.
-		q.Set("multiStatements", "true")
 
-		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s",
-			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name, q.Encode())
-	}()
-	fmt.Println(fmt.Sprintf("dsn is: %s", dsn))
+		fmt.Println("ensure migrations reached final return stmt")
+		// migrate up to desired version
+		// if dbVersion == desiredVersion, goose will log "no migration needed"
+		return goose.UpTo(db, src.Dir, desiredVersion)
+	})
+}
+
+// MigrateUp connects to the database described in the Config and migrates
+// it up to version. Unlike Migrate a version below the database's is an
+// error, rolling back is MigrateDown's job.
+func MigrateUp(ctx context.Context, cfg Config, src MigrationSource, version int64) error {
+	return withLock(ctx, cfg, src, version, func(db *sql.DB, l goose.Logger, dbVersion int64) error {
+		if version < dbVersion {
+			return fmt.Errorf("database is at version %d, migrating up can't take it to %d", dbVersion, version)
+		}
+		return goose.UpTo(db, src.Dir, version)
+	})
+}
+
+// MigrateDown connects to the database described in the Config and rolls it
+// back to version, running the down migrations of everything above it. A
+// version above the database's is an error.
+func MigrateDown(ctx context.Context, cfg Config, src MigrationSource, version int64) error {
+	return withLock(ctx, cfg, src, version, func(db *sql.DB, l goose.Logger, dbVersion int64) error {
+		if version > dbVersion {
+			return fmt.Errorf("database is at version %d, migrating down can't take it to %d", dbVersion, version)
+		}
+		return goose.DownTo(db, src.Dir, version)
+	})
+}
 
-	db, err := goose.OpenDBWithDriver("mysql", dsn) // This is just runs sql.Open and tells goose which driver to use
+// withLock opens the database described by cfg and calls migrate with its
+// current version, holding the migration lock. A dry run only reports the
+// current version and target instead.
+func withLock(ctx context.Context, cfg Config, src MigrationSource, target int64, migrate func(db *sql.DB, l goose.Logger, dbVersion int64) error) error {
+	db, l, err := open(cfg, src)
 	if err != nil {
-		return fmt.Errorf("unable to open db for migrating: %w", err)
+		return err
+	}
+	defer db.Close()
+
+	// you now have l to use to log any messages from here on (it will show up inline with goose log messages)
+
+	if cfg.DryRun {
+		// CurrentVersion doesn't create the version table, a dry run leaves
+		// the database exactly as it found it. A database that has never been
+		// migrated has no version table yet, that's reported rather than
+		// failing startup, readiness will keep the instance out of rotation.
+		dbVersion, err := CurrentVersion(ctx, db)
+		if err != nil {
+			l.Print("DRY RUN MODE: Unable to get current db version: ", err, "  Desired DB Version: ", target)
+			return nil
+		}
+		l.Print("DRY RUN MODE: Current DB Version: ", dbVersion, "  Desired DB Version: ", target)
+		return nil
+	}
+
+	// advisory lock to prevent multiple instances from attempting to migrate at the same time.
+	// Only MySQL has named locks, CockroachDB has no advisory locks and a
+	// SQLite file only ever has the one instance in front of it.
+	if cfg.Driver == "" || cfg.Driver == DriverMySQL {
+		locker := gomysqllock.NewMysqlLocker(db)
+		// ObtainTimeoutContext tries to acquire lock and gives up when the given context is cancelled or lockTimeout has passed
+		lock, err := locker.ObtainTimeoutContext(ctx, lockName, lockTimeout)
+		if err != nil {
+			return fmt.Errorf("unable to obtain migration lock: %w", err)
+		}
+		defer func() {
+			// be sure to release the lock when we are done
+			if err := lock.Release(); err != nil {
+				l.Print("Error Releasing Lock: ", err)
+			}
+		}()
+	}
+
+	// grab current migration version of the database, another instance may
+	// have migrated while we waited on the lock so this has to come after it
+	dbVersion, err := goose.EnsureDBVersion(db)
+	if err != nil {
+		return fmt.Errorf("Unable to get current db version: %w", err)
+	}
+	return migrate(db, l, dbVersion)
+}
+
+// open connects to the database described by cfg, points goose at src and
+// returns the logger the migration should use.
+func open(cfg Config, src MigrationSource) (*sql.DB, goose.Logger, error) {
+	driver, dsn := dsnFor(cfg)
+	redacted := cfg
+	if redacted.Password != "" {
+		redacted.Password = "xxxxx"
+	}
+	_, logDSN := dsnFor(redacted)
+	fmt.Println(fmt.Sprintf("dsn is: %s", logDSN))
+
+	db, err := goose.OpenDBWithDriver(driver, dsn) // This is just runs sql.Open and tells goose which driver to use
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open db for migrating: %w", err)
 	}
 
 	// Tell Goose which filesystem to use
@@ -107,45 +245,55 @@ func Migrate(ctx context.Context, cfg Config, src MigrationSource, desiredVersio
 	} else {
 		l = log.Default()
 	}
+	return db, l, nil
+}
 
-	// you now have l to use to log any messages from here on (it will show up inline with goose log messages)
-
-	// advisory lock to prevent multiple instances from attempting to migrate at the same time
-	// locker := gomysqllock.NewMysqlLocker(db)
-	// fmt.Println("prior to lock assignment")
-	// // ObtainTimeoutContext tries to acquire lock and gives up when the given context is cancelled or 60 seconds has passed
-	// lock, err := locker.ObtainTimeoutContext(ctx, "goose-migration", 60)
-	// if err != nil {
-	// 	return err
-	// }
-	// defer func() {
-	// 	// be sure to release the lock when we are done
-	// 	if err := lock.Release(); err != nil {
-	// 		l.Fatal("Error Releasing Lock:", err)
-	// 	}
-	// }()
-	// fmt.Println("post lock assignment")
-
-	// grab current migration version of the database
-	dbVersion, err := goose.EnsureDBVersion(db)
-	if err != nil {
-		return fmt.Errorf("Unable to get current db version: %w", err)
-	}
+// dsnFor picks the database/sql driver for cfg and builds its DSN.
+func dsnFor(cfg Config) (driver, dsn string) {
+	driver, dsn = "mysql", func() string {
+		q := make(url.Values)
+		for k, v := range cfg.AdditionalParams {
+			q.Add(k, v)
+		}
+		// Note: for MySQL parseTime flag must be enabled.
+		q.Set("parseTime", "true")
+		// Note: for MySQL multiStatements must be enabled.
+		//	This is required when writing multiple queries separated by ';' characters in a single sql file.
+		q.Set("multiStatements", "true")
 
-	if cfg.DryRun {
-		l.Print("DRY RUN MODE: Current DB Version: ", dbVersion, "  Desired DB Version: ", desiredVersion)
-		return nil
+		port := cfg.Port
+		if port == "" {
+			port = "3306"
+		}
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s?%s",
+			cfg.User, cfg.Password, net.JoinHostPort(cfg.Host, port), cfg.Name, q.Encode())
+	}()
+	switch cfg.Driver {
+	case DriverPostgres:
+		driver, dsn = "pgx", postgresDSN(cfg)
+	case DriverSQLite:
+		// Name is the path of the database file
+		driver, dsn = "sqlite", "file:"+cfg.Name+"?_pragma=foreign_keys(1)"
 	}
+	return driver, dsn
+}
 
-	if dbVersion > desiredVersion {
-		// migrate down to desired version
-		return goose.DownTo(db, src.Dir, desiredVersion)
+// postgresDSN builds the connection URL for Postgres or CockroachDB the
+// way the service connects, minus the MySQL-only params.
+func postgresDSN(cfg Config) string {
+	q := make(url.Values)
+	for k, v := range cfg.AdditionalParams {
+		q.Add(k, v)
 	}
-
-	fmt.Println("ensure migrations reached final return stmt")
-	// migrate up to desired version
-	// if dbVersion == desiredVersion, goose will log "no migration needed"
-	return goose.UpTo(db, src.Dir, desiredVersion)
+	return database.PostgresDSN(database.Config{
+		Driver:   database.DriverPostgres,
+		User:     cfg.User,
+		Password: cfg.Password,
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Name:     cfg.Name,
+		Params:   q.Encode(),
+	})
 }
 
 // GooseLogger is intended to wrap the services regular logger so that the migrations show up correctly in datadog
@@ -187,7 +335,43 @@ func (t TestingLogger) Printf(format string, v ...interface{}) { t.Logf(format,
 func TestEnsureMigrations(t *testing.T, cfg Config) {
 	t.Helper()
 	cfg.LoggerOverride = TestingLogger{T: t}
-	if err := Migrate(context.Background(), cfg, src, DesiredVersion); err != nil {
+	if err := Migrate(context.Background(), cfg, SourceFor(cfg.Driver), DesiredVersion); err != nil {
 		t.Fatal(err)
 	}
 }
+
+// MigrationStatus is one migration in a source and whether the database has
+// it applied.
+type MigrationStatus struct {
+	Version int64
+	Source  string
+	Applied bool
+}
+
+// Status lists every migration in src against the version of the database
+// described in cfg. goose only ever migrates in order, so everything at or
+// below the current version is applied.
+func Status(ctx context.Context, cfg Config, src MigrationSource) (current int64, statuses []MigrationStatus, err error) {
+	db, _, err := open(cfg, src)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer db.Close()
+
+	current, err = CurrentVersion(ctx, db)
+	if err != nil {
+		return 0, nil, err
+	}
+	migrations, err := goose.CollectMigrations(src.Dir, 0, goose.MaxVersion)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to collect migrations: %w", err)
+	}
+	for _, m := range migrations {
+		statuses = append(statuses, MigrationStatus{
+			Version: m.Version,
+			Source:  m.Source,
+			Applied: m.Version <= current,
+		})
+	}
+	return current, statuses, nil
+}
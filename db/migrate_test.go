@@ -90,7 +90,7 @@ func TestMigrate(t *testing.T) {
 		})
 		t.Run("Down", func(t *testing.T) {
 			// Migrate down to 0
-			testMigrate(t, testCFG, 0)
+			testMigrateDown(t, testCFG, 0)
 		})
 	})
 
@@ -136,14 +136,14 @@ func TestMigrate(t *testing.T) {
 		wantVersion := 0
 		t.Run("DRY RUN", func(t *testing.T) {
 			testCFG.DryRun = true
-			testMigrate(t, testCFG, wantVersion)
+			testMigrateDown(t, testCFG, wantVersion)
 			testCFG.DryRun = false
 
 			// Ensure Current db version wasn't changed
 			diff(t, expectedCurrentVersion, getDBVersion(t))
 		})
 		t.Run("WET RUN", func(t *testing.T) {
-			testMigrate(t, testCFG, wantVersion)
+			testMigrateDown(t, testCFG, wantVersion)
 
 			// Ensure Current db version was changed
 			diff(t, wantVersion, getDBVersion(t))
@@ -169,7 +169,7 @@ func TestMigrate(t *testing.T) {
 		t.Parallel()
 		for i := 0; i < 5; i++ {
 			t.Run(strconv.Itoa(i), func(t *testing.T) {
-				testMigrate(t, testCFG, wantVersion)
+				testMigrateDown(t, testCFG, wantVersion)
 				// Ensure Current db version was changed
 				// (at the point in time testMigrate returns,
 				//	doesnt mean this instance of testMigrate actually did any migrations)
@@ -204,3 +204,11 @@ func testMigrate(t *testing.T, testCFG migrate.Config, version int) {
 		t.Error(err)
 	}
 }
+
+func testMigrateDown(t *testing.T, testCFG migrate.Config, version int) {
+	t.Helper()
+	testCFG.LoggerOverride = migrate.TestingLogger{T: t}
+	if err := migrate.MigrateDown(context.Background(), testCFG, src, int64(version)); err != nil {
+		t.Error(err)
+	}
+}
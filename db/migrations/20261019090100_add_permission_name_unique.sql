@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE permission ADD UNIQUE KEY uniq_permission_name (name);
+
+-- +goose Down
+ALTER TABLE permission DROP INDEX uniq_permission_name;
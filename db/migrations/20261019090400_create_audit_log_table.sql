@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS audit_log (
+    id CHAR(36) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(64) NOT NULL,
+    target_id CHAR(36) NOT NULL,
+    before_state JSON NULL,
+    after_state JSON NULL,
+    request_id VARCHAR(255) NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_audit_log_created_at (created_at),
+    KEY idx_audit_log_actor (actor, created_at),
+    KEY idx_audit_log_target (target_type, target_id, created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_log;
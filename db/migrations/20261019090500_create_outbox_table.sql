@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS outbox (
+    seq BIGINT NOT NULL AUTO_INCREMENT,
+    id CHAR(36) NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    aggregate_type VARCHAR(64) NOT NULL,
+    aggregate_id CHAR(36) NOT NULL,
+    payload JSON NOT NULL,
+    request_id VARCHAR(255) NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    published_at DATETIME(6) NULL,
+    PRIMARY KEY (seq),
+    UNIQUE KEY uq_outbox_id (id),
+    KEY idx_outbox_pending (published_at, seq)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox;
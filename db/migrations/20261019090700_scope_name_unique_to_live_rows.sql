@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE permission
+    ADD COLUMN live_name VARCHAR(255) AS (IF(deleted_at IS NULL, name, NULL)) STORED,
+    DROP INDEX uniq_permission_name,
+    ADD UNIQUE KEY uniq_permission_name (live_name);
+ALTER TABLE role
+    ADD COLUMN live_name VARCHAR(255) AS (IF(deleted_at IS NULL, name, NULL)) STORED,
+    DROP INDEX uniq_role_name,
+    ADD UNIQUE KEY uniq_role_name (live_name);
+
+-- +goose Down
+ALTER TABLE role
+    DROP INDEX uniq_role_name,
+    DROP COLUMN live_name,
+    ADD UNIQUE KEY uniq_role_name (name);
+ALTER TABLE permission
+    DROP INDEX uniq_permission_name,
+    DROP COLUMN live_name,
+    ADD UNIQUE KEY uniq_permission_name (name);
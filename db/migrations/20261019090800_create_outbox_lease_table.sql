@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS outbox_lease (
+    name VARCHAR(64) NOT NULL,
+    holder CHAR(36) NULL,
+    lease_until DATETIME(6) NOT NULL,
+    PRIMARY KEY (name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+INSERT INTO outbox_lease (name, holder, lease_until) VALUES ('relay', NULL, '1970-01-01 00:00:01');
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox_lease;
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE outbox
+    ADD COLUMN position BIGINT NULL,
+    ADD UNIQUE KEY uq_outbox_position (position),
+    DROP INDEX idx_outbox_pending,
+    ADD KEY idx_outbox_pending (published_at, position);
+ALTER TABLE outbox_lease ADD COLUMN last_position BIGINT NOT NULL DEFAULT 0;
+-- events written so far keep their seq, so watch cursors stay valid
+UPDATE outbox SET position = seq;
+UPDATE outbox_lease SET last_position = (SELECT COALESCE(MAX(seq), 0) FROM outbox) WHERE name = 'relay';
+
+-- +goose Down
+ALTER TABLE outbox_lease DROP COLUMN last_position;
+ALTER TABLE outbox
+    DROP INDEX idx_outbox_pending,
+    ADD KEY idx_outbox_pending (published_at, seq),
+    DROP INDEX uq_outbox_position,
+    DROP COLUMN position;
@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_permission_name ON permission (name);
+
+-- +goose Down
+DROP INDEX IF EXISTS uniq_permission_name CASCADE;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE permission ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+ALTER TABLE role ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+
+-- +goose Down
+ALTER TABLE role DROP COLUMN deleted_at;
+ALTER TABLE permission DROP COLUMN deleted_at;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS outbox (
+    seq BIGSERIAL NOT NULL,
+    id UUID NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    aggregate_type VARCHAR(64) NOT NULL,
+    aggregate_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    request_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    published_at TIMESTAMPTZ NULL,
+    PRIMARY KEY (seq),
+    UNIQUE (id)
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (published_at, seq);
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,11 @@
+-- +goose Up
+DROP INDEX IF EXISTS uniq_permission_name CASCADE;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_permission_name ON permission (name) WHERE deleted_at IS NULL;
+ALTER TABLE role DROP CONSTRAINT IF EXISTS uniq_role_name;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_role_name ON role (name) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS uniq_role_name CASCADE;
+ALTER TABLE role ADD CONSTRAINT uniq_role_name UNIQUE (name);
+DROP INDEX IF EXISTS uniq_permission_name CASCADE;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_permission_name ON permission (name);
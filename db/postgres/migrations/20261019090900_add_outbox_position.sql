@@ -0,0 +1,19 @@
+-- +goose NO TRANSACTION
+-- CockroachDB can't write a column in the transaction that adds it
+
+-- +goose Up
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS position INT8 NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_outbox_position ON outbox (position);
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (published_at, position);
+ALTER TABLE outbox_lease ADD COLUMN IF NOT EXISTS last_position INT8 NOT NULL DEFAULT 0;
+-- events written so far keep their seq, so watch cursors stay valid
+UPDATE outbox SET position = seq WHERE position IS NULL;
+UPDATE outbox_lease SET last_position = (SELECT COALESCE(MAX(seq), 0) FROM outbox) WHERE name = 'relay';
+
+-- +goose Down
+ALTER TABLE outbox_lease DROP COLUMN IF EXISTS last_position;
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (published_at, seq);
+DROP INDEX IF EXISTS uq_outbox_position CASCADE;
+ALTER TABLE outbox DROP COLUMN IF EXISTS position;
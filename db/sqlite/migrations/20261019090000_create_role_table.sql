@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS role (
+    id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uniq_role_name UNIQUE (name)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS role;
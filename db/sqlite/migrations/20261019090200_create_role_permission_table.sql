@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS role_permission (
+    role_id TEXT NOT NULL REFERENCES role (id) ON DELETE CASCADE,
+    permission_id TEXT NOT NULL REFERENCES permission (id),
+    PRIMARY KEY (role_id, permission_id)
+);
+CREATE INDEX IF NOT EXISTS idx_role_permission_permission ON role_permission (permission_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS role_permission;
@@ -0,0 +1,26 @@
+-- +goose Up
+DROP INDEX IF EXISTS uniq_permission_name;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_permission_name ON permission (name) WHERE deleted_at IS NULL;
+
+-- uniq_role_name is a table constraint, which SQLite can only drop by
+-- rebuilding the table. Dropping role cascades to role_permission, so the
+-- grants are copied aside and put back once the new table is in place.
+CREATE TEMP TABLE role_permission_backup AS SELECT role_id, permission_id FROM role_permission;
+CREATE TABLE role_new (
+    id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    deleted_at TIMESTAMP NULL,
+    PRIMARY KEY (id)
+);
+INSERT INTO role_new (id, name, deleted_at) SELECT id, name, deleted_at FROM role;
+DROP TABLE role;
+ALTER TABLE role_new RENAME TO role;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_role_name ON role (name) WHERE deleted_at IS NULL;
+INSERT INTO role_permission (role_id, permission_id) SELECT role_id, permission_id FROM role_permission_backup;
+DROP TABLE role_permission_backup;
+
+-- +goose Down
+DROP INDEX IF EXISTS uniq_role_name;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_role_name ON role (name);
+DROP INDEX IF EXISTS uniq_permission_name;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_permission_name ON permission (name);
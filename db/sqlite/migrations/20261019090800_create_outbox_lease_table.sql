@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS outbox_lease (
+    name TEXT NOT NULL,
+    holder TEXT NULL,
+    lease_until TIMESTAMP NOT NULL,
+    PRIMARY KEY (name)
+);
+INSERT INTO outbox_lease (name, holder, lease_until) VALUES ('relay', NULL, '1970-01-01 00:00:01');
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox_lease;
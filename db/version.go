@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// CurrentVersion reports the migration version the database is at. Unlike
+// goose.EnsureDBVersion it never creates the version table, so it is safe to
+// call from read-only paths such as readiness checks.
+func CurrentVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	const q = `SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC`
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return 0, fmt.Errorf("unable to query db version: %w", err)
+	}
+	defer rows.Close()
+
+	// The most recent record for each version says whether it is applied or
+	// rolled back, the first applied version we see is the current one.
+	rolledBack := map[int64]bool{}
+	for rows.Next() {
+		var (
+			version int64
+			applied bool
+		)
+		if err := rows.Scan(&version, &applied); err != nil {
+			return 0, fmt.Errorf("unable to scan db version: %w", err)
+		}
+		if rolledBack[version] {
+			continue
+		}
+		if applied {
+			return version, nil
+		}
+		rolledBack[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("unable to read db version: %w", err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,86 @@
+// Package audit records who changed what in the permission and role stores.
+// Stores write a Record in the same transaction as the change it describes,
+// so the log never disagrees with the data.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	// ActionReplacePermissions replaces a role's grants, before and after
+	// are the permission ids.
+	ActionReplacePermissions = "replace_permissions"
+	// ActionRevokePermission is a grant revoked by a cascading permission
+	// delete, before is the permission.
+	ActionRevokePermission = "revoke_permission"
+)
+
+const (
+	TargetPermission = "permission"
+	TargetRole       = "role"
+)
+
+// Store is the audit log's read side, writes go through Write and WritePgx
+// inside the transactions of the stores being audited.
+type Store interface {
+	ListRecords(ctx context.Context, filter Filter) ([]Record, error)
+}
+
+type API struct {
+	Store Store
+}
+
+func NewAPI(store Store) *API {
+	return &API{
+		Store: store,
+	}
+}
+
+// ListRecords returns the records matching filter, newest first.
+func (api *API) ListRecords(ctx context.Context, filter Filter) ([]Record, error) {
+	if filter.Limit <= 0 || filter.Limit > MaxLimit {
+		filter.Limit = DefaultLimit
+	}
+	return api.Store.ListRecords(ctx, filter)
+}
+
+// newRecord describes action on the target made by the request in ctx.
+// before and after are marshaled to JSON, nil is stored as NULL.
+func newRecord(ctx context.Context, action, targetType string, targetID uuid.UUID, before, after interface{}) (Record, error) {
+	rec := Record{
+		ID:         uuid.New(),
+		Actor:      Actor(ctx),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		RequestID:  RequestID(ctx),
+		CreatedAt:  time.Now().UTC(),
+	}
+	var err error
+	if rec.Before, err = marshal(before); err != nil {
+		return Record{}, err
+	}
+	if rec.After, err = marshal(after); err != nil {
+		return Record{}, err
+	}
+	return rec, nil
+}
+
+func marshal(v interface{}) (database.JSON, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	return database.JSON(b), err
+}
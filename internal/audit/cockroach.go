@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+var _ Store = (*CockroachDBStorage)(nil)
+
+func NewCockroachDBStore(pool *pgxpool.Pool) *CockroachDBStorage {
+	return &CockroachDBStorage{pool: pool}
+}
+
+// CockroachDBStorage reads the audit log from CockroachDB, or any other
+// Postgres compatible database, through pgx.
+type CockroachDBStorage struct {
+	pool *pgxpool.Pool
+}
+
+// WritePgx is Write for the pgx stores.
+func WritePgx(ctx context.Context, tx pgx.Tx, action, targetType string, targetID uuid.UUID, before, after interface{}) error {
+	rec, err := newRecord(ctx, action, targetType, targetID, before, after)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO audit_log
+		(id, actor, action, target_type, target_id, before_state, after_state, request_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		rec.ID, rec.Actor, rec.Action, rec.TargetType, rec.TargetID, rec.Before, rec.After, rec.RequestID, rec.CreatedAt)
+	return err
+}
+
+func (s *CockroachDBStorage) ListRecords(ctx context.Context, filter Filter) ([]Record, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.TargetType != "" {
+		add("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != uuid.Nil {
+		add("target_id = $%d", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		add("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("created_at < $%d", filter.Until)
+	}
+	query := `SELECT id, actor, action, target_type, target_id, before_state, after_state, request_id, created_at FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return []Record{}, database.ClassifyError(err)
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var rec Record
+		if err := rows.Scan(&rec.ID, &rec.Actor, &rec.Action, &rec.TargetType, &rec.TargetID,
+			&rec.Before, &rec.After, &rec.RequestID, &rec.CreatedAt); err != nil {
+			return records, database.ClassifyError(err)
+		}
+		records = append(records, rec)
+	}
+
+	return records, database.ClassifyError(rows.Err())
+}
@@ -0,0 +1,35 @@
+package audit
+
+import "context"
+
+type requestKey struct{}
+
+type request struct {
+	actor     string
+	requestID string
+}
+
+// WithRequest attributes the changes made with ctx to actor, as part of the
+// request with requestID.
+func WithRequest(ctx context.Context, actor, requestID string) context.Context {
+	return context.WithValue(ctx, requestKey{}, request{actor: actor, requestID: requestID})
+}
+
+// UnknownActor is recorded for changes made without WithRequest, like the
+// retention job's.
+const UnknownActor = "unknown"
+
+// Actor is who changes made with ctx are attributed to.
+func Actor(ctx context.Context) string {
+	req, _ := ctx.Value(requestKey{}).(request)
+	if req.actor == "" {
+		return UnknownActor
+	}
+	return req.actor
+}
+
+// RequestID is the id of the request ctx belongs to, empty outside one.
+func RequestID(ctx context.Context) string {
+	req, _ := ctx.Value(requestKey{}).(request)
+	return req.requestID
+}
@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+var _ Store = (*MySQLStorage)(nil)
+
+func NewMySQLStore(conn *dbr.Connection) *MySQLStorage {
+	return &MySQLStorage{conn: conn, sess: conn.NewSession(nil)}
+}
+
+type MySQLStorage struct {
+	conn *dbr.Connection
+	sess *dbr.Session
+}
+
+var (
+	auditTable = database.NewTable("audit_log", Record{})
+)
+
+// Write records action on the target in tx, the transaction making the
+// change.
+func Write(ctx context.Context, tx dbr.SessionRunner, action, targetType string, targetID uuid.UUID, before, after interface{}) error {
+	rec, err := newRecord(ctx, action, targetType, targetID, before, after)
+	if err != nil {
+		return err
+	}
+	_, err = tx.InsertInto(auditTable.Name).
+		Columns(auditTable.Columns...).
+		Record(rec).
+		ExecContext(ctx)
+	return err
+}
+
+func (s *MySQLStorage) ListRecords(ctx context.Context, filter Filter) ([]Record, error) {
+	query := s.sess.Select(auditTable.Columns...).
+		From(auditTable.Name).
+		OrderDesc("created_at").
+		OrderDesc("id").
+		Limit(uint64(filter.Limit))
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != uuid.Nil {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until.UTC())
+	}
+
+	records := []Record{}
+
+	if _, err := query.LoadContext(ctx, &records); err != nil {
+		return records, database.ClassifyError(err)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+// Record is one audited change.
+type Record struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	Actor      string    `db:"actor" json:"actor"`
+	Action     string    `db:"action" json:"action"`
+	TargetType string    `db:"target_type" json:"target_type"`
+	TargetID   uuid.UUID `db:"target_id" json:"target_id"`
+	// Before and After are the target as JSON around the change, null when
+	// it didn't exist yet or doesn't apply.
+	Before    database.JSON `db:"before_state" json:"before"`
+	After     database.JSON `db:"after_state" json:"after"`
+	RequestID string        `db:"request_id" json:"request_id"`
+	CreatedAt time.Time     `db:"created_at" json:"created_at"`
+}
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Filter narrows ListRecords, zero fields match everything.
+type Filter struct {
+	Actor      string
+	TargetType string
+	TargetID   uuid.UUID
+	// Since and Until bound CreatedAt, Since inclusive and Until exclusive.
+	Since time.Time
+	Until time.Time
+	// Limit caps the records returned, DefaultLimit when zero.
+	Limit int
+}
@@ -0,0 +1,149 @@
+// Package decision answers whether a role is granted a permission, with a
+// trace of how the answer was reached. Roles and their grants are all the
+// service models so far, there are no subjects bound to roles or resources
+// permissions are scoped to, so those can't be asked about yet.
+package decision
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/decisionlog"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/metrics"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/tracing"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+// SubjectPrefix marks decision log subjects that are roles, the only ones
+// the engine can decide for until roles are bound to users.
+const SubjectPrefix = "role:"
+
+type Engine struct {
+	Permissions permission.Store
+	Roles       role.Store
+
+	// Metrics, when set, counts the decisions.
+	Metrics *metrics.Metrics
+	// Log, when set, gets a record of every decision it samples.
+	Log *decisionlog.Logger
+	// Revision, when set, names the policy revision logged decisions were
+	// made against.
+	Revision func(ctx context.Context) (string, error)
+	// OnLogError gets the errors writing to Log, a decision that can't be
+	// logged is still made.
+	OnLogError func(error)
+}
+
+func NewEngine(permissions permission.Store, roles role.Store) *Engine {
+	return &Engine{
+		Permissions: permissions,
+		Roles:       roles,
+	}
+}
+
+// Query asks whether Role, by name, is granted Permission, by name.
+type Query struct {
+	Role       string `json:"role" validate:"required"`
+	Permission string `json:"permission" validate:"required"`
+}
+
+type Decision struct {
+	Allowed bool `json:"allowed"`
+	// Trace explains the decision step by step.
+	Trace []string `json:"trace"`
+}
+
+// Check decides q against the live roles, permissions and grants. A
+// missing role or permission is a deny, not an error. It's traced as a
+// decision.check span with every step of the trace as an event.
+func (e *Engine) Check(ctx context.Context, q Query) (Decision, error) {
+	start := time.Now()
+	ctx, span := tracing.StartSpan(ctx, "decision.check")
+	span.SetTag("decision.role", q.Role)
+	span.SetTag("decision.permission", q.Permission)
+	d, rule, err := e.check(ctx, q)
+	for _, step := range d.Trace {
+		span.Event(step)
+	}
+	if err == nil {
+		span.SetTag("decision.allowed", strconv.FormatBool(d.Allowed))
+	}
+	span.Finish(err)
+	if err != nil {
+		return d, err
+	}
+	if e.Metrics != nil {
+		e.Metrics.Decision(d.Allowed)
+	}
+	if e.Log != nil {
+		e.log(ctx, q, d, rule, time.Since(start))
+	}
+	return d, nil
+}
+
+// log writes d to e.Log.
+func (e *Engine) log(ctx context.Context, q Query, d Decision, rule string, latency time.Duration) {
+	rec := decisionlog.Record{
+		Subject:     SubjectPrefix + q.Role,
+		Action:      q.Permission,
+		Allowed:     d.Allowed,
+		MatchedRule: rule,
+		Latency:     latency,
+		RequestID:   audit.RequestID(ctx),
+	}
+	var err error
+	if e.Revision != nil {
+		rec.PolicyRevision, err = e.Revision(ctx)
+	}
+	if err == nil {
+		err = e.Log.Log(ctx, rec)
+	}
+	if err != nil && e.OnLogError != nil {
+		e.OnLogError(err)
+	}
+}
+
+// check is Check, along with the rule that allowed q, empty on a deny. It
+// looks up the role, the permission and the grant between them, never
+// whole tables.
+func (e *Engine) check(ctx context.Context, q Query) (Decision, string, error) {
+	var d Decision
+
+	r, err := e.Roles.GetRoleByName(ctx, q.Role)
+	if bestirerror.StatusCode(err) == http.StatusNotFound {
+		d.Trace = append(d.Trace, fmt.Sprintf("role %q doesn't exist", q.Role), "deny")
+		return d, "", nil
+	}
+	if err != nil {
+		return d, "", err
+	}
+	d.Trace = append(d.Trace, fmt.Sprintf("role %q exists", r.Name))
+
+	p, err := e.Permissions.GetpermissionByName(ctx, q.Permission)
+	if bestirerror.StatusCode(err) == http.StatusNotFound {
+		d.Trace = append(d.Trace, fmt.Sprintf("permission %q doesn't exist", q.Permission), "deny")
+		return d, "", nil
+	}
+	if err != nil {
+		return d, "", err
+	}
+	d.Trace = append(d.Trace, fmt.Sprintf("permission %q exists", p.Name))
+
+	granted, err := e.Roles.HasRolePermission(ctx, r.ID, p.ID)
+	if err != nil {
+		return d, "", err
+	}
+	if !granted {
+		d.Trace = append(d.Trace, fmt.Sprintf("permission %q isn't granted to role %q", p.Name, r.Name), "deny")
+		return d, "", nil
+	}
+	d.Allowed = true
+	d.Trace = append(d.Trace, fmt.Sprintf("permission %q is granted by role %q", p.Name, r.Name), "allow")
+	return d, fmt.Sprintf("%s%s grants %s", SubjectPrefix, r.Name, p.Name), nil
+}
@@ -0,0 +1,66 @@
+package decision_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+func TestCheckSpan(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	ctx := context.Background()
+	permissions := permission.NewMemoryStore()
+	roles := role.NewMemoryStore(permissions)
+	deploy := permission.Permission{ID: uuid.New(), Name: "games.build.deploy"}
+	if err := permissions.Createpermission(ctx, deploy); err != nil {
+		t.Fatal(err)
+	}
+	builder := role.Role{ID: uuid.New(), Name: "builder"}
+	if err := roles.CreateRole(ctx, builder); err != nil {
+		t.Fatal(err)
+	}
+	if err := roles.ReplaceRolePermissions(ctx, builder.ID, []uuid.UUID{deploy.ID}); err != nil {
+		t.Fatal(err)
+	}
+	engine := decision.NewEngine(permissions, roles)
+	d, err := engine.Check(ctx, decision.Query{Role: "builder", Permission: "games.build.deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	diff(t, "decision.check", span.Name())
+	var events []string
+	for _, event := range span.Events() {
+		events = append(events, event.Name)
+	}
+	diff(t, d.Trace, events)
+	diff(t, []attribute.KeyValue{
+		attribute.String("decision.role", "builder"),
+		attribute.String("decision.permission", "games.build.deploy"),
+		attribute.String("decision.allowed", "true"),
+	}, span.Attributes())
+}
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if d := cmp.Diff(want, got, cmp.AllowUnexported(attribute.Value{})); d != "" {
+		t.Errorf("(-want +got):\n%s", d)
+	}
+}
@@ -4,15 +4,37 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
+	"time"
 
+	"github.com/XSAM/otelsql"
 	"github.com/go-sql-driver/mysql"
 	"github.com/gocraft/dbr/v2"
 	"github.com/gocraft/dbr/v2/dialect"
+	"github.com/jackc/pgx/v4/stdlib"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 	sqltrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql"
-	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
-	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"modernc.org/sqlite"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/tracing"
+)
+
+// Drivers that Open and the migrations know how to talk to. CockroachDB
+// speaks the Postgres wire protocol so it uses DriverPostgres.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Tracers that Open can instrument the driver for.
+const (
+	TracerDatadog = "datadog"
+	TracerOTel    = "otel"
 )
 
 // Config is the required properties to use the database.
@@ -20,36 +42,122 @@ type Config struct {
 	User     string
 	Password string
 	Host     string
+	Port     string
 	Name     string
 	Params   string
+
+	// Driver is DriverMySQL, DriverPostgres or DriverSQLite, DriverMySQL
+	// when empty. SQLite takes the database file path from Name.
+	Driver string
+
+	// Tracer selects how queries are traced, TracerDatadog when empty.
+	Tracer string
+
+	TLS TLS
+
+	// Pool settings, zero leaves the database/sql (or pgxpool) default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
 // Open knows how to open a database connection based on the configuration.
 func Open(cfg Config, serviceName string) (*sql.DB, error) {
-	sqltrace.Register("mysql", &mysql.MySQLDriver{},
-		sqltrace.WithServiceName(serviceName),
-		sqltrace.WithAnalytics(true),
+	if _, err := url.ParseQuery(cfg.Params); err != nil {
+		return nil, fmt.Errorf("parsing db params: %w", err)
+	}
+	if err := RegisterTLS(cfg); err != nil {
+		return nil, fmt.Errorf("configuring db tls: %w", err)
+	}
+
+	var (
+		driverName               = "mysql"
+		drv        driver.Driver = &mysql.MySQLDriver{}
+		dsn                      = DSN(cfg)
+		system                   = semconv.DBSystemMySQL
+	)
+	switch cfg.Driver {
+	case DriverPostgres:
+		driverName, drv, dsn, system = "pgx", stdlib.GetDefaultDriver(), PostgresDSN(cfg), semconv.DBSystemPostgreSQL
+	case DriverSQLite:
+		driverName, drv, dsn, system = "sqlite", &sqlite.Driver{}, SQLiteDSN(cfg), semconv.DBSystemSqlite
+	}
+	log.Println(fmt.Sprintf("dsn is: %s", Redacted(cfg)))
+
+	var (
+		db  *sql.DB
+		err error
 	)
-	dsn := DSN(cfg)
-	log.Println(fmt.Sprintf("dsn is: %s", dsn))
-	return sqltrace.Open("mysql", dsn)
+	if cfg.Tracer == TracerOTel {
+		db, err = otelsql.Open(driverName, dsn,
+			otelsql.WithAttributes(system, semconv.DBNameKey.String(cfg.Name)),
+		)
+	} else {
+		sqltrace.Register(driverName, drv,
+			sqltrace.WithServiceName(serviceName),
+			sqltrace.WithAnalytics(true),
+		)
+		db, err = sqltrace.Open(driverName, dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	return db, nil
 }
 
 func NewDBR(db *sql.DB) *dbr.Connection {
 	return &dbr.Connection{DB: db, EventReceiver: &dbr.NullEventReceiver{}, Dialect: dialect.MySQL}
 }
 
+// DSN builds the MySQL DSN, Port defaults to 3306.
 func DSN(cfg Config) string {
-	// return fmt.Sprintf("%s:%s@tcp(%s:3306)/%s?%s", cfg.User, cfg.Password, cfg.Host, cfg.Name, cfg.Params)
-	return fmt.Sprintf("%s:%s@tcp(%s:3306)/%s", cfg.User, cfg.Password, cfg.Host, cfg.Name)
+	port := cfg.Port
+	if port == "" {
+		port = "3306"
+	}
+	q, _ := url.ParseQuery(cfg.Params)
+	for k, v := range TLSParams(cfg) {
+		q[k] = v
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s", cfg.User, cfg.Password, net.JoinHostPort(cfg.Host, port), cfg.Name)
+	if len(q) > 0 {
+		dsn += "?" + q.Encode()
+	}
+	return dsn
+}
+
+// Redacted is the DSN for cfg with the password masked, for logging.
+func Redacted(cfg Config) string {
+	if cfg.Password != "" {
+		cfg.Password = "xxxxx"
+	}
+	switch cfg.Driver {
+	case DriverPostgres:
+		return PostgresDSN(cfg)
+	case DriverSQLite:
+		return SQLiteDSN(cfg)
+	}
+	return DSN(cfg)
 }
 
 // StatusCheck returns nil if it can successfully talk to the database. It
 // returns a non-nil error otherwise.
 func StatusCheck(ctx context.Context, db *sql.DB) error {
-	if span, ok := tracer.SpanFromContext(ctx); ok {
-		span.SetTag(ext.ManualDrop, true)
-	}
+	tracing.Drop(ctx)
 
 	// Run a simple query to determine connectivity. The db has a "Ping" method
 	// but it can false-positive when it was previously able to talk to the
@@ -0,0 +1,101 @@
+package database_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  database.Config
+		want string
+	}{
+		{
+			name: "DefaultPort",
+			cfg:  database.Config{User: "svc", Password: "secret", Host: "db", Name: "permission"},
+			want: "svc:secret@tcp(db:3306)/permission",
+		},
+		{
+			name: "PortAndParams",
+			cfg:  database.Config{User: "svc", Password: "secret", Host: "db", Port: "3307", Name: "permission", Params: "parseTime=true"},
+			want: "svc:secret@tcp(db:3307)/permission?parseTime=true",
+		},
+		{
+			name: "TLS",
+			cfg:  database.Config{User: "svc", Password: "secret", Host: "db", Name: "permission", TLS: database.TLS{Enable: true}},
+			want: "svc:secret@tcp(db:3306)/permission?tls=permission",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, database.DSN(tt.cfg)); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  database.Config
+		want string
+	}{
+		{
+			name: "MySQL",
+			cfg:  database.Config{User: "svc", Password: "secret", Host: "db", Name: "permission"},
+			want: "svc:xxxxx@tcp(db:3306)/permission",
+		},
+		{
+			name: "Postgres",
+			cfg:  database.Config{Driver: database.DriverPostgres, User: "svc", Password: "secret", Host: "db", Name: "permission"},
+			want: "postgres://svc:xxxxx@db:5432/permission",
+		},
+		{
+			name: "NoPassword",
+			cfg:  database.Config{User: "svc", Host: "db", Name: "permission"},
+			want: "svc:@tcp(db:3306)/permission",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, database.Redacted(tt.cfg)); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	t.Run("MissingCA", func(t *testing.T) {
+		_, err := database.TLS{Enable: true, CAFile: filepath.Join(t.TempDir(), "nope.pem")}.Config()
+		if err == nil {
+			t.Fatal("expected an error for a missing ca file")
+		}
+	})
+	t.Run("EmptyCA", func(t *testing.T) {
+		ca := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(ca, []byte("not a cert"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := database.TLS{Enable: true, CAFile: ca}.Config()
+		if err == nil {
+			t.Fatal("expected an error for a ca file with no certificates")
+		}
+	})
+	t.Run("ServerName", func(t *testing.T) {
+		tc, err := database.TLS{Enable: true, ServerName: "db.internal"}.Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("db.internal", tc.ServerName); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+	})
+}
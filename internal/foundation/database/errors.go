@@ -8,6 +8,10 @@ import (
 	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
 	"github.com/go-sql-driver/mysql"
 	"github.com/gocraft/dbr/v2"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 func ErrNotFound(err error) error {
@@ -22,6 +26,13 @@ func ErrForeignKeyConstraint(err error) error {
 	return bestirerror.WithCodeAndMessage(err, http.StatusBadRequest, "foreign key constraint fails")
 }
 
+// ErrHasDependents is a delete refused because other rows still reference
+// the row, dependents describes them for the client.
+func ErrHasDependents(err error, dependents []string) error {
+	err = bestirerror.WithCodeAndMessage(err, http.StatusConflict, err.Error())
+	return bestirerror.WithDetails(err, dependents)
+}
+
 func ClassifyError(err error) error {
 	if err == nil {
 		return nil
@@ -32,6 +43,9 @@ func ClassifyError(err error) error {
 	if errors.Is(err, dbr.ErrNotFound) {
 		return ErrNotFound(err)
 	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound(err)
+	}
 	if mse := new(mysql.MySQLError); errors.As(err, &mse) {
 		if mse.Number == uint16(1062) {
 			// Error 1062: Duplicate entry 'ch_123' for key 'stripe_charge_id'
@@ -41,6 +55,30 @@ func ClassifyError(err error) error {
 			// Error 1452: Cannot add or update a child row: a foreign key constraint fails
 			return ErrForeignKeyConstraint(err)
 		}
+		if mse.Number == uint16(1451) {
+			// Error 1451: Cannot delete or update a parent row: a foreign key constraint fails
+			return ErrHasDependents(err, nil)
+		}
+	}
+	if sle := new(sqlite.Error); errors.As(err, &sle) {
+		switch sle.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			// UNIQUE constraint failed: permission.name (2067)
+			return ErrDuplicateUnique(err)
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			// FOREIGN KEY constraint failed (787)
+			return ErrForeignKeyConstraint(err)
+		}
+	}
+	if pge := new(pgconn.PgError); errors.As(err, &pge) {
+		switch pge.Code {
+		case "23505":
+			// unique_violation: duplicate key value violates unique constraint "uniq_permission_name"
+			return ErrDuplicateUnique(err)
+		case "23503":
+			// foreign_key_violation: insert or update on table violates foreign key constraint
+			return ErrForeignKeyConstraint(err)
+		}
 	}
 
 	return err
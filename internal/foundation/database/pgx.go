@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// pgSerializationFailure is the SQLSTATE Postgres and CockroachDB abort
+	// a transaction with when it has to be retried by the client.
+	pgSerializationFailure = "40001"
+
+	maxSerializationRetries = 5
+	serializationBackoff    = 10 * time.Millisecond
+)
+
+// mysqlOnlyParams are the MySQL driver params permission_DB_Param_Overrides
+// commonly carries, Postgres would reject them as unknown runtime params.
+var mysqlOnlyParams = []string{"parseTime", "multiStatements"}
+
+// PostgresDSN builds a connection URL for Postgres or CockroachDB.
+func PostgresDSN(cfg Config) string {
+	port := cfg.Port
+	if port == "" {
+		port = "5432"
+	}
+	q, _ := url.ParseQuery(cfg.Params)
+	for _, k := range mysqlOnlyParams {
+		q.Del(k)
+	}
+	for k, v := range TLSParams(cfg) {
+		q[k] = v
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, port),
+		Path:     "/" + cfg.Name,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// OpenPgx opens a pgx connection pool to Postgres or CockroachDB.
+func OpenPgx(ctx context.Context, cfg Config) (*pgxpool.Pool, error) {
+	pcfg, err := pgxpool.ParseConfig(PostgresDSN(cfg))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		pcfg.MaxConns = int32(cfg.MaxOpenConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		pcfg.MaxConnLifetime = cfg.ConnMaxLifetime
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		pcfg.MaxConnIdleTime = cfg.ConnMaxIdleTime
+	}
+	return pgxpool.ConnectConfig(ctx, pcfg)
+}
+
+// A PgxTxFn is called with the transaction WithPgxTransaction opened.
+type PgxTxFn func(pgx.Tx) error
+
+// WithPgxTransaction runs fn in a transaction, committing it if fn returns
+// nil and rolling it back otherwise. CockroachDB runs every transaction as
+// SERIALIZABLE and expects clients to retry the ones it aborts with a
+// serialization failure, so those are retried from the top with backoff.
+// fn must therefore be safe to run more than once.
+func WithPgxTransaction(ctx context.Context, pool *pgxpool.Pool, fn PgxTxFn) error {
+	backoff := serializationBackoff
+	for attempt := 1; ; attempt++ {
+		err := pool.BeginFunc(ctx, func(tx pgx.Tx) error { return fn(tx) })
+		if !isSerializationFailure(err) || attempt == maxSerializationRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgSerializationFailure
+}
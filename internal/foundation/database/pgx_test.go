@@ -0,0 +1,41 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  database.Config
+		want string
+	}{
+		{
+			name: "DefaultPort",
+			cfg:  database.Config{User: "svc", Password: "p@ss", Host: "db", Name: "permission"},
+			want: "postgres://svc:p%40ss@db:5432/permission",
+		},
+		{
+			name: "DropsMySQLParams",
+			cfg:  database.Config{User: "root", Host: "crdb", Port: "26257", Name: "permission", Params: "parseTime=true&sslmode=verify-full"},
+			want: "postgres://root:@crdb:26257/permission?sslmode=verify-full",
+		},
+		{
+			name: "TLS",
+			cfg: database.Config{Driver: database.DriverPostgres, User: "svc", Host: "db", Name: "permission",
+				TLS: database.TLS{Enable: true, CAFile: "/etc/db/ca.pem", CertFile: "/etc/db/client.pem", KeyFile: "/etc/db/client.key"}},
+			want: "postgres://svc:@db:5432/permission?sslcert=%2Fetc%2Fdb%2Fclient.pem&sslkey=%2Fetc%2Fdb%2Fclient.key&sslmode=verify-full&sslrootcert=%2Fetc%2Fdb%2Fca.pem",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, database.PostgresDSN(tt.cfg)); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+)
+
+type strongKey struct{}
+
+// WithStrongConsistency marks ctx so reads made with it go to the primary,
+// for callers that must see their own writes.
+func WithStrongConsistency(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strongKey{}, true)
+}
+
+// IsStrongConsistency reports whether ctx asks for reads from the primary.
+func IsStrongConsistency(ctx context.Context) bool {
+	strong, _ := ctx.Value(strongKey{}).(bool)
+	return strong
+}
+
+type minRevisionKey struct{}
+
+// WithMinRevision marks ctx so reads made with it only use a replica that
+// has applied revision, for callers holding a consistency token from a
+// write they must not read from before. Zero asks for nothing.
+func WithMinRevision(ctx context.Context, revision int64) context.Context {
+	return context.WithValue(ctx, minRevisionKey{}, revision)
+}
+
+// MinRevision is the revision ctx asks reads to be at least as fresh as,
+// zero when it doesn't.
+func MinRevision(ctx context.Context) int64 {
+	rev, _ := ctx.Value(minRevisionKey{}).(int64)
+	return rev
+}
+
+// A RevisionFunc reports whether the replica db has applied revision.
+type RevisionFunc func(ctx context.Context, db *sql.DB, revision int64) (bool, error)
+
+// ReplicaConfig is cfg pointed at the replica at addr, "host" or
+// "host:port". Credentials, params, TLS and pool settings are the primary's.
+func ReplicaConfig(cfg Config, addr string) Config {
+	cfg.Host, cfg.Port = addr, ""
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		cfg.Host, cfg.Port = host, port
+	}
+	return cfg
+}
+
+// A LagFunc reports how far behind its primary the replica db is.
+type LagFunc func(ctx context.Context, db *sql.DB) (time.Duration, error)
+
+var errReplicationStopped = errors.New("replication is not running")
+
+// MySQLReplicaLag reads Seconds_Behind_Source from SHOW REPLICA STATUS,
+// falling back to SHOW SLAVE STATUS for servers older than 8.0.22.
+func MySQLReplicaLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		if rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS"); err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errReplicationStopped
+	}
+	vals := make([]sql.NullString, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return 0, err
+	}
+	for i, c := range cols {
+		if c != "Seconds_Behind_Source" && c != "Seconds_Behind_Master" {
+			continue
+		}
+		// NULL while the SQL thread isn't running
+		if !vals[i].Valid {
+			return 0, errReplicationStopped
+		}
+		secs, err := strconv.Atoi(vals[i].String)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+	return 0, errors.New("replica status has no seconds behind column")
+}
+
+// Replicas routes reads to read replicas of the primary. Writes and
+// transactions keep using the primary's session directly, only stores that
+// ask for Reader sessions are affected.
+//
+// With a MaxLag every replica starts out of rotation and is only used once
+// CheckLag has seen it within MaxLag, a replica that falls behind, stops
+// replicating or can't be reached drops out until it catches up. Reads go to
+// the primary whenever no replica is usable.
+type Replicas struct {
+	// MaxLag is how far behind a replica may fall and still serve reads,
+	// zero trusts every replica without checking.
+	MaxLag time.Duration
+	// Lag measures a replica's lag, MySQLReplicaLag by default.
+	Lag LagFunc
+	// HasRevision checks replicas for reads with a MinRevision, those go to
+	// the primary when it's nil.
+	HasRevision RevisionFunc
+
+	primary  *dbr.Session
+	replicas []*replica
+	next     uint32
+}
+
+type replica struct {
+	db     *sql.DB
+	sess   *dbr.Session
+	usable int32 // atomic
+}
+
+// NewReplicas builds the routing for primary and its replicas.
+func NewReplicas(primary *dbr.Connection, replicas []*dbr.Connection, maxLag time.Duration) *Replicas {
+	r := &Replicas{
+		MaxLag:  maxLag,
+		Lag:     MySQLReplicaLag,
+		primary: primary.NewSession(nil),
+	}
+	for _, conn := range replicas {
+		rep := &replica{db: conn.DB, sess: conn.NewSession(nil)}
+		if maxLag == 0 {
+			rep.usable = 1
+		}
+		r.replicas = append(r.replicas, rep)
+	}
+	return r
+}
+
+// Reader returns the session a read made with ctx should use, the next
+// usable replica in turn, or the primary when ctx asks for strong
+// consistency or no replica is usable. With a MinRevision only replicas
+// that have applied it are usable.
+func (r *Replicas) Reader(ctx context.Context) *dbr.Session {
+	if IsStrongConsistency(ctx) {
+		return r.primary
+	}
+	minRevision := MinRevision(ctx)
+	if minRevision > 0 && r.HasRevision == nil {
+		return r.primary
+	}
+	n := len(r.replicas)
+	start := int(atomic.AddUint32(&r.next, 1))
+	for i := 0; i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+		if atomic.LoadInt32(&rep.usable) != 1 {
+			continue
+		}
+		if minRevision > 0 {
+			if ok, err := r.HasRevision(ctx, rep.db, minRevision); err != nil || !ok {
+				continue
+			}
+		}
+		return rep.sess
+	}
+	return r.primary
+}
+
+// CheckLag measures every replica once and takes the ones more than MaxLag
+// behind out of rotation. It returns how many replicas are usable.
+func (r *Replicas) CheckLag(ctx context.Context) int {
+	usable := 0
+	for _, rep := range r.replicas {
+		ok := int32(1)
+		if r.MaxLag > 0 {
+			lag, err := r.Lag(ctx, rep.db)
+			if err != nil || lag > r.MaxLag {
+				ok = 0
+			}
+		}
+		atomic.StoreInt32(&rep.usable, ok)
+		usable += int(ok)
+	}
+	return usable
+}
+
+// MonitorLag runs CheckLag every interval until ctx is done.
+func (r *Replicas) MonitorLag(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.CheckLag(ctx)
+		}
+	}
+}
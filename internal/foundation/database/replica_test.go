@@ -0,0 +1,112 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+// openNamed opens a SQLite database that answers "SELECT name FROM whoami"
+// with name, so tests can tell which database served a read.
+func openNamed(t *testing.T, name string) *dbr.Connection {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), name+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE whoami (name TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO whoami (name) VALUES (?)`, name); err != nil {
+		t.Fatal(err)
+	}
+	return database.NewSQLiteDBR(db)
+}
+
+func whoami(ctx context.Context, t *testing.T, r *database.Replicas) string {
+	t.Helper()
+	var name string
+	if err := r.Reader(ctx).Select("name").From("whoami").LoadOneContext(ctx, &name); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestReplicas(t *testing.T) {
+	ctx := context.Background()
+	primary, a, b := openNamed(t, "primary"), openNamed(t, "a"), openNamed(t, "b")
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		r := database.NewReplicas(primary, []*dbr.Connection{a, b}, 0)
+		seen := map[string]bool{}
+		for i := 0; i < 4; i++ {
+			seen[whoami(ctx, t, r)] = true
+		}
+		diff(t, map[string]bool{"a": true, "b": true}, seen)
+	})
+
+	t.Run("StrongConsistency", func(t *testing.T) {
+		r := database.NewReplicas(primary, []*dbr.Connection{a, b}, 0)
+		diff(t, "primary", whoami(database.WithStrongConsistency(ctx), t, r))
+	})
+
+	t.Run("MinRevision", func(t *testing.T) {
+		applied := map[*sql.DB]int64{a.DB: 5, b.DB: 9}
+		r := database.NewReplicas(primary, []*dbr.Connection{a, b}, 0)
+
+		// replicas can't prove they're fresh without HasRevision
+		diff(t, "primary", whoami(database.WithMinRevision(ctx, 1), t, r))
+
+		r.HasRevision = func(ctx context.Context, db *sql.DB, revision int64) (bool, error) {
+			return applied[db] >= revision, nil
+		}
+		for i := 0; i < 4; i++ {
+			diff(t, "b", whoami(database.WithMinRevision(ctx, 7), t, r))
+		}
+		diff(t, "primary", whoami(database.WithMinRevision(ctx, 10), t, r))
+	})
+
+	t.Run("Lag", func(t *testing.T) {
+		lag := map[*sql.DB]time.Duration{a.DB: time.Second, b.DB: time.Minute}
+		r := database.NewReplicas(primary, []*dbr.Connection{a, b}, 5*time.Second)
+		r.Lag = func(ctx context.Context, db *sql.DB) (time.Duration, error) {
+			if d, ok := lag[db]; ok {
+				return d, nil
+			}
+			return 0, errors.New("unreachable")
+		}
+
+		// unchecked replicas aren't trusted
+		diff(t, "primary", whoami(ctx, t, r))
+
+		diff(t, 1, r.CheckLag(ctx))
+		for i := 0; i < 4; i++ {
+			diff(t, "a", whoami(ctx, t, r))
+		}
+
+		delete(lag, a.DB)
+		diff(t, 0, r.CheckLag(ctx))
+		diff(t, "primary", whoami(ctx, t, r))
+	})
+}
+
+func TestReplicaConfig(t *testing.T) {
+	cfg := database.Config{User: "svc", Host: "primary", Port: "3307"}
+
+	got := database.ReplicaConfig(cfg, "replica-1")
+	diff(t, "replica-1", got.Host)
+	diff(t, "", got.Port)
+	diff(t, "svc", got.User)
+
+	got = database.ReplicaConfig(cfg, "replica-2:3308")
+	diff(t, "replica-2", got.Host)
+	diff(t, "3308", got.Port)
+}
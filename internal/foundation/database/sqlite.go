@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+	"net/url"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/gocraft/dbr/v2/dialect"
+)
+
+// sqlitePragmas are applied to every connection. SQLite leaves foreign keys
+// off by default, WAL lets readers run alongside the single writer and the
+// busy timeout makes writers queue up instead of failing with SQLITE_BUSY.
+var sqlitePragmas = []string{
+	"foreign_keys(1)",
+	"journal_mode(WAL)",
+	"busy_timeout(5000)",
+}
+
+// SQLiteDSN builds the modernc.org/sqlite DSN for the database file at
+// cfg.Name.
+func SQLiteDSN(cfg Config) string {
+	q := url.Values{"_pragma": sqlitePragmas}
+	return "file:" + cfg.Name + "?" + q.Encode()
+}
+
+// NewSQLiteDBR is NewDBR for a SQLite database. The queries our stores
+// build with dbr are portable, only the dialect differs.
+func NewSQLiteDBR(db *sql.DB) *dbr.Connection {
+	return &dbr.Connection{DB: db, EventReceiver: &dbr.NullEventReceiver{}, Dialect: dialect.SQLite3}
+}
+
+// RowLock is what ends a SELECT to lock the rows it reads until the
+// transaction is over. SQLite has no FOR UPDATE, it serializes writers
+// anyway.
+func RowLock(conn *dbr.Connection) string {
+	if conn.Dialect == dialect.SQLite3 {
+		return ""
+	}
+	return "FOR UPDATE"
+}
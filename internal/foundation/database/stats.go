@@ -3,7 +3,6 @@ package database
 import (
 	"context"
 	"database/sql"
-	"strconv"
 	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
@@ -21,31 +20,33 @@ func NewStatsReporter(db *sql.DB, ddc *statsd.Client, lg *bestirlog.ZapLogger) *
 	return &StatsReporter{db: db, ddc: ddc, lg: lg}
 }
 
+// EmitStats sends a snapshot of the sql.DBStats of the pool to statsd. The
+// pool numbers are point in time values so they're sent as gauges.
 func (sr *StatsReporter) EmitStats(_ context.Context, tags []string, rate float64) error {
 	s := sr.db.Stats()
 
 	// Maximum number of open connections to the database.
-	if err := sr.ddc.Set("db.max_open_connections", strconv.Itoa(s.MaxOpenConnections), tags, rate); err != nil {
+	if err := sr.ddc.Gauge("db.max_open_connections", float64(s.MaxOpenConnections), tags, rate); err != nil {
 		return err
 	}
 
 	// Pool Status
 	// The number of established connections both in use and idle.
-	if err := sr.ddc.Set("db.pool.open_connections", strconv.Itoa(s.OpenConnections), tags, rate); err != nil {
+	if err := sr.ddc.Gauge("db.pool.open_connections", float64(s.OpenConnections), tags, rate); err != nil {
 		return err
 	}
 	// The number of connections currently in use.
-	if err := sr.ddc.Set("db.pool.in_use", strconv.Itoa(s.InUse), tags, rate); err != nil {
+	if err := sr.ddc.Gauge("db.pool.in_use", float64(s.InUse), tags, rate); err != nil {
 		return err
 	}
 	// The number of idle connections.
-	if err := sr.ddc.Set("db.pool.idle", strconv.Itoa(s.Idle), tags, rate); err != nil {
+	if err := sr.ddc.Gauge("db.pool.idle", float64(s.Idle), tags, rate); err != nil {
 		return err
 	}
 
 	// Counters
 	// The total number of connections waited for.
-	if err := sr.ddc.Set("db.counters.wait_count", strconv.FormatInt(s.WaitCount, 10), tags, rate); err != nil {
+	if err := sr.ddc.Gauge("db.counters.wait_count", float64(s.WaitCount), tags, rate); err != nil {
 		return err
 	}
 	// The total time blocked waiting for a new connection.
@@ -53,22 +54,24 @@ func (sr *StatsReporter) EmitStats(_ context.Context, tags []string, rate float6
 		return err
 	}
 	// The total number of connections closed due to SetMaxIdleConns.
-	if err := sr.ddc.Set("db.counters.max_idle_closed", strconv.FormatInt(s.MaxIdleClosed, 10), tags, rate); err != nil {
+	if err := sr.ddc.Gauge("db.counters.max_idle_closed", float64(s.MaxIdleClosed), tags, rate); err != nil {
 		return err
 	}
 	// The total number of connections closed due to SetConnMaxIdleTime.
-	if err := sr.ddc.Set("db.counters.max_idle_time_closed", strconv.FormatInt(s.MaxIdleTimeClosed, 10), tags, rate); err != nil {
+	if err := sr.ddc.Gauge("db.counters.max_idle_time_closed", float64(s.MaxIdleTimeClosed), tags, rate); err != nil {
 		return err
 	}
 	// The total number of connections closed due to SetConnMaxLifetime.
-	if err := sr.ddc.Set("db.counters.max_lifetime_closed", strconv.FormatInt(s.MaxLifetimeClosed, 10), tags, rate); err != nil {
+	if err := sr.ddc.Gauge("db.counters.max_lifetime_closed", float64(s.MaxLifetimeClosed), tags, rate); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (sr *StatsReporter) ReportDBStats(ctx context.Context, tags []string, rate float64) {
-	ticker := time.NewTicker(time.Millisecond)
+// ReportDBStats emits the pool stats every interval until ctx is done.
+func (sr *StatsReporter) ReportDBStats(ctx context.Context, tags []string, rate float64, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
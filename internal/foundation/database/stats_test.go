@@ -0,0 +1,89 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/Max-Gabriel-Susman/bestir-go-kit/bestirlog"
+	_ "github.com/go-sql-driver/mysql"
+	"go.uber.org/zap"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+// fakeStatsd listens on a random local UDP port and collects every metric
+// line it receives.
+func fakeStatsd(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	lines := make(chan string, 1024)
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, l := range strings.Split(string(buf[:n]), "\n") {
+				if l != "" {
+					lines <- l
+				}
+			}
+		}
+	}()
+	return conn.LocalAddr().String(), lines
+}
+
+func TestReportDBStats(t *testing.T) {
+	addr, lines := fakeStatsd(t)
+
+	ddc, err := statsd.New(addr, statsd.WithBufferFlushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ddc.Close()
+
+	// sql.Open doesn't connect, which is all we need to read pool stats
+	db, err := sql.Open("mysql", "user:pass@tcp(127.0.0.1:3306)/permission")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	sr := database.NewStatsReporter(db, ddc, bestirlog.WrapZap(zap.NewNop()))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sr.ReportDBStats(ctx, []string{"env:test"}, 1, 20*time.Millisecond)
+	}()
+
+	want := "db.max_open_connections:7|g|#env:test"
+	timeout := time.After(5 * time.Second)
+	for found := false; !found; {
+		select {
+		case l := <-lines:
+			found = l == want
+		case <-timeout:
+			t.Fatalf("never received %q", want)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ReportDBStats did not stop when ctx was canceled")
+	}
+}
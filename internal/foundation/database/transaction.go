@@ -1,7 +1,25 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 	"github.com/gocraft/dbr/v2"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	// MySQL error numbers that abort a transaction which can simply be run
+	// again: 1213 deadlock found when trying to get lock and 1205 lock wait
+	// timeout exceeded.
+	mysqlDeadlock        = 1213
+	mysqlLockWaitTimeout = 1205
+
+	maxDeadlockRetries = 5
+	deadlockBackoff    = 10 * time.Millisecond
 )
 
 // A Txfn is a function that will be called with an initialized `Transaction` object
@@ -9,30 +27,63 @@ import (
 type TxFn func(dbr.SessionRunner) error
 
 // WithTransaction creates a new transaction and handles rollback/commit based on the
-// error object returned by the `TxFn`
+// error object returned by the `TxFn`. A panic in fn rolls back and re-panics.
 //
+// Deadlocks and lock wait timeouts roll the transaction back and run it again
+// with a doubling backoff, up to maxDeadlockRetries times, so fn must be safe
+// to call more than once. SQLITE_BUSY is retried the same way, SQLiteStorage
+// shares this code.
+func WithTransaction(ctx context.Context, sess *dbr.Session, fn TxFn) error {
+	backoff := deadlockBackoff
+	for attempt := 1; ; attempt++ {
+		err := runTransaction(ctx, sess, fn)
+		if !isRetryable(err) || attempt == maxDeadlockRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
 //nolint:errcheck
-func WithTransaction(sess *dbr.Session, fn TxFn) (err error) {
-	tx, err := sess.Begin()
+func runTransaction(ctx context.Context, sess *dbr.Session, fn TxFn) (err error) {
+	tx, err := sess.BeginTx(ctx, nil)
 	if err != nil {
-		return
+		return err
 	}
 
 	defer func() {
-		switch p := recover(); p {
-		case p != nil:
+		if p := recover(); p != nil {
 			// a panic occurred, rollback and repanic
 			tx.Rollback()
 			panic(p)
-		case err != nil:
+		}
+		if err != nil {
 			// something went wrong, rollback
 			tx.Rollback()
-		default:
-			// all good, commit
-			err = tx.Commit()
+			return
 		}
+		// all good, commit
+		err = tx.Commit()
 	}()
 
-	err = fn(tx)
-	return err
+	return fn(tx)
+}
+
+// isRetryable reports whether err aborted a transaction that can be retried.
+func isRetryable(err error) bool {
+	if mse := new(mysql.MySQLError); errors.As(err, &mse) {
+		return mse.Number == mysqlDeadlock || mse.Number == mysqlLockWaitTimeout
+	}
+	if sle := new(sqlite.Error); errors.As(err, &sle) {
+		// the low byte is the primary code, extended codes like
+		// SQLITE_BUSY_SNAPSHOT are busy too
+		return sle.Code()&0xff == sqlite3.SQLITE_BUSY
+	}
+	return false
 }
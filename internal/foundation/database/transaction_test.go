@@ -0,0 +1,126 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gocraft/dbr/v2"
+	"github.com/google/go-cmp/cmp"
+	_ "modernc.org/sqlite"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+func TestWithTransaction(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "tx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE thing (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+	sess := database.NewSQLiteDBR(db).NewSession(nil)
+
+	count := func(t *testing.T) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM thing`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	insert := func(tx dbr.SessionRunner, id int) error {
+		_, err := tx.InsertInto("thing").Pair("id", id).ExecContext(ctx)
+		return err
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		err := database.WithTransaction(ctx, sess, func(tx dbr.SessionRunner) error {
+			return insert(tx, 1)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, 1, count(t))
+	})
+
+	t.Run("RollbackOnError", func(t *testing.T) {
+		boom := errors.New("boom")
+		err := database.WithTransaction(ctx, sess, func(tx dbr.SessionRunner) error {
+			if err := insert(tx, 2); err != nil {
+				return err
+			}
+			return boom
+		})
+		diff(t, true, errors.Is(err, boom))
+		diff(t, 1, count(t))
+	})
+
+	t.Run("RollbackOnPanic", func(t *testing.T) {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected the panic to be re-raised")
+				}
+			}()
+			_ = database.WithTransaction(ctx, sess, func(tx dbr.SessionRunner) error {
+				if err := insert(tx, 3); err != nil {
+					return err
+				}
+				panic("boom")
+			})
+		}()
+		diff(t, 1, count(t))
+	})
+
+	t.Run("RetryDeadlock", func(t *testing.T) {
+		attempts := 0
+		err := database.WithTransaction(ctx, sess, func(tx dbr.SessionRunner) error {
+			attempts++
+			if err := insert(tx, 4); err != nil {
+				return err
+			}
+			if attempts < 3 {
+				return &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, 3, attempts)
+		diff(t, 2, count(t))
+	})
+
+	t.Run("GiveUpRetrying", func(t *testing.T) {
+		attempts := 0
+		err := database.WithTransaction(ctx, sess, func(tx dbr.SessionRunner) error {
+			attempts++
+			return &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+		})
+		diff(t, true, err != nil)
+		diff(t, 5, attempts)
+	})
+
+	t.Run("NoRetryOtherErrors", func(t *testing.T) {
+		attempts := 0
+		_ = database.WithTransaction(ctx, sess, func(tx dbr.SessionRunner) error {
+			attempts++
+			return &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+		})
+		diff(t, 1, attempts)
+	})
+}
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
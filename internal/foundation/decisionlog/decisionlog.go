@@ -0,0 +1,112 @@
+// Package decisionlog appends authorization decisions to a local JSONL file,
+// one Record per line, for security teams to ship into their SIEM.
+package decisionlog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Redacted replaces the value of every redacted context attribute.
+const Redacted = "[REDACTED]"
+
+// Record is one decision.
+type Record struct {
+	Time     time.Time `json:"time"`
+	Subject  string    `json:"subject"`
+	Action   string    `json:"action"`
+	Resource string    `json:"resource"`
+	// Allowed is the decision's result.
+	Allowed bool `json:"allowed"`
+	// MatchedRule is the rule that decided, empty when none matched.
+	MatchedRule    string        `json:"matched_rule,omitempty"`
+	Latency        time.Duration `json:"-"`
+	PolicyRevision string        `json:"policy_revision,omitempty"`
+	// Context holds the attributes the decision was made with.
+	Context   map[string]interface{} `json:"context,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// MarshalJSON writes Latency in milliseconds, which SIEMs can aggregate
+// without knowing Go durations.
+func (r Record) MarshalJSON() ([]byte, error) {
+	type record Record
+	return json.Marshal(struct {
+		record
+		LatencyMS float64 `json:"latency_ms"`
+	}{record(r), float64(r.Latency) / float64(time.Millisecond)})
+}
+
+type Config struct {
+	// SampleRate is the fraction of decisions logged, 0 logs none and 1
+	// logs every one.
+	SampleRate float64
+	// Redact names the context attributes whose values never reach the log.
+	Redact []string
+}
+
+// Logger writes sampled, redacted Records to w. It's safe for concurrent
+// use.
+type Logger struct {
+	cfg    Config
+	redact map[string]bool
+
+	mu   sync.Mutex
+	w    io.Writer
+	rand *rand.Rand
+}
+
+// New creates a Logger that writes to w, usually a RotatingFile.
+func New(w io.Writer, cfg Config) *Logger {
+	redact := make(map[string]bool, len(cfg.Redact))
+	for _, k := range cfg.Redact {
+		redact[k] = true
+	}
+	return &Logger{
+		cfg:    cfg,
+		redact: redact,
+		w:      w,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Log appends rec to the log if it's sampled. Time defaults to now.
+func (l *Logger) Log(ctx context.Context, rec Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.SampleRate <= 0 || (l.cfg.SampleRate < 1 && l.rand.Float64() >= l.cfg.SampleRate) {
+		return nil
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+	rec.Context = l.redacted(rec.Context)
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = l.w.Write(append(b, '\n'))
+	return err
+}
+
+// redacted copies attrs with the configured attributes' values replaced,
+// the caller's map is left alone.
+func (l *Logger) redacted(attrs map[string]interface{}) map[string]interface{} {
+	if len(attrs) == 0 || len(l.redact) == 0 {
+		return attrs
+	}
+	out := make(map[string]interface{}, len(attrs))
+	for k, v := range attrs {
+		if l.redact[k] {
+			v = Redacted
+		}
+		out[k] = v
+	}
+	return out
+}
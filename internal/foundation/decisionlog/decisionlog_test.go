@@ -0,0 +1,153 @@
+package decisionlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/decisionlog"
+)
+
+func TestLogger(t *testing.T) {
+	ctx := context.Background()
+	rec := decisionlog.Record{
+		Time:           time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		Subject:        "user:alice",
+		Action:         "games.build.deploy",
+		Resource:       "game:123",
+		Allowed:        true,
+		MatchedRule:    "developer",
+		Latency:        1500 * time.Microsecond,
+		PolicyRevision: "42",
+		Context:        map[string]interface{}{"ip": "10.0.0.1", "token": "secret"},
+	}
+
+	t.Run("Redact", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := decisionlog.New(&buf, decisionlog.Config{SampleRate: 1, Redact: []string{"token"}})
+		if err := l.Log(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+		diff(t, `{"time":"2026-10-19T09:00:00Z","subject":"user:alice","action":"games.build.deploy",`+
+			`"resource":"game:123","allowed":true,"matched_rule":"developer","policy_revision":"42",`+
+			`"context":{"ip":"10.0.0.1","token":"[REDACTED]"},"latency_ms":1.5}`+"\n", buf.String())
+		diff(t, "secret", rec.Context["token"])
+	})
+
+	t.Run("Sample", func(t *testing.T) {
+		for _, tc := range []struct {
+			rate float64
+			want int
+		}{{0, 0}, {1, 100}} {
+			var buf bytes.Buffer
+			l := decisionlog.New(&buf, decisionlog.Config{SampleRate: tc.rate})
+			for i := 0; i < 100; i++ {
+				if err := l.Log(ctx, rec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			diff(t, tc.want, strings.Count(buf.String(), "\n"))
+		}
+
+		var buf bytes.Buffer
+		l := decisionlog.New(&buf, decisionlog.Config{SampleRate: 0.5})
+		for i := 0; i < 1000; i++ {
+			l.Log(ctx, rec) //nolint:errcheck
+		}
+		if n := strings.Count(buf.String(), "\n"); n < 350 || n > 650 {
+			t.Errorf("logged %d of 1000 decisions at a 0.5 sample rate", n)
+		}
+	})
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	rf, err := decisionlog.OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read := func(path string) string {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err.Error()
+		}
+		return string(b)
+	}
+	// "five" still fits next to "four", every other write rotated
+	diff(t, "four\nfive\n", read(path))
+	diff(t, "three\n", read(path+".1"))
+	diff(t, "one\ntwo\n", read(path+".2"))
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more backups than MaxBackups: %v", err)
+	}
+
+	var rec map[string]interface{}
+	l := decisionlog.New(rf, decisionlog.Config{SampleRate: 1})
+	if err := l.Log(context.Background(), decisionlog.Record{Subject: "user:alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(read(path)), &rec); err != nil {
+		t.Fatal(err)
+	}
+	diff(t, "user:alice", rec["subject"])
+}
+
+func TestRotatingFileRotateFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	rf, err := decisionlog.OpenRotatingFile(path, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	read := func() string {
+		t.Helper()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	// a directory in the way of the backup makes the rename fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("one\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("two\n")); err == nil {
+		t.Error("rotation didn't fail")
+	}
+	diff(t, "one\ntwo\n", read())
+
+	// once it's out of the way the next write rotates
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("three\n")); err != nil {
+		t.Fatal(err)
+	}
+	diff(t, "three\n", read())
+}
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,109 @@
+package decisionlog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an append-only file that's rotated once it would grow
+// past MaxSize bytes. The rotated files are kept as path.1 (the newest) up
+// to path.MaxBackups, older ones are removed.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens path for appending, creating it if needed.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, size, err := openAppend(rf.Path)
+	if err != nil {
+		return err
+	}
+	rf.f, rf.size = f, size
+	return nil
+}
+
+func openAppend(path string) (*os.File, int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+// Write appends p, rotating first when p would take the file past MaxSize.
+// A single write is never split across files. When the rotation fails p
+// still goes to the current file and the rotation's error is returned.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	var rotateErr error
+	if rf.MaxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.MaxSize {
+		rotateErr = rf.rotate()
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// rotate shifts the backups up by one and starts a new file, rf.mu must be
+// held. The current file stays open until the new one is, so a failed
+// rotation leaves rf writing where it was and the next write tries again.
+func (rf *RotatingFile) rotate() error {
+	if rf.MaxBackups < 1 {
+		if err := os.Remove(rf.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		for i := rf.MaxBackups - 1; i >= 1; i-- {
+			err := os.Rename(backup(rf.Path, i), backup(rf.Path, i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(rf.Path, backup(rf.Path, 1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	f, size, err := openAppend(rf.Path)
+	if err != nil {
+		return err
+	}
+	old := rf.f
+	rf.f, rf.size = f, size
+	return old.Close()
+}
+
+func backup(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// Close closes the current file.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Close()
+}
@@ -0,0 +1,101 @@
+// Package metrics exposes service metrics in the Prometheus format.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+const namespace = "permission"
+
+// Decision results.
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Metrics holds the service's collectors and the registry they're exposed
+// through. The zero value is not usable, use New.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requests  *prometheus.HistogramVec
+	decisions *prometheus.CounterVec
+}
+
+// New creates and registers the service's collectors along with the
+// standard process and Go runtime collectors.
+func New() *Metrics {
+	m := Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by method, route and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decisions_total",
+			Help:      "Authorization decisions by result.",
+		}, []string{"result"}),
+	}
+	m.Registry.MustRegister(
+		m.requests,
+		m.decisions,
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(),
+	)
+	return &m
+}
+
+// RegisterDB exports the sql.DBStats of db, the same pool numbers that
+// database.StatsReporter sends to statsd.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Decision records the outcome of an authorization decision.
+func (m *Metrics) Decision(allowed bool) {
+	result := Deny
+	if allowed {
+		result = Allow
+	}
+	m.decisions.WithLabelValues(result).Inc()
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// Middleware observes the latency and status of every request, labelled by
+// the route pattern rather than the raw path to keep cardinality bounded.
+func (m *Metrics) Middleware() web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			err := handler(ctx, w, r)
+
+			v := web.GetValues(ctx)
+			status := v.StatusCode
+			if err != nil {
+				status = bestirerror.StatusCode(err)
+			}
+			m.requests.WithLabelValues(r.Method, v.Route, strconv.Itoa(status)).
+				Observe(time.Since(v.Now).Seconds())
+
+			return err
+		}
+	}
+}
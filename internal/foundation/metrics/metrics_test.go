@@ -0,0 +1,48 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/metrics"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := metrics.New()
+	app := web.NewApp(m.Middleware())
+	app.Handle(http.MethodGet, "/permission/{id}", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if r.URL.Query().Get("fail") != "" {
+			return bestirerror.WithStatusCode(errors.New("missing"), http.StatusNotFound)
+		}
+		return web.Respond(ctx, w, map[string]string{"id": "1"}, http.StatusOK)
+	})
+
+	for _, target := range []string{"/permission/1", "/permission/2", "/permission/3?fail=1"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	m.Decision(true)
+	m.Decision(false)
+	m.Decision(false)
+
+	// only compare the counts, bucket boundaries aren't interesting here
+	if n := testutil.CollectAndCount(m.Registry, "permission_http_request_duration_seconds"); n != 2 {
+		t.Errorf("expected a series per route and status, got %d", n)
+	}
+	want := `
+# HELP permission_decisions_total Authorization decisions by result.
+# TYPE permission_decisions_total counter
+permission_decisions_total{result="allow"} 1
+permission_decisions_total{result="deny"} 2
+`
+	if err := testutil.GatherAndCompare(m.Registry, strings.NewReader(want), "permission_decisions_total"); err != nil {
+		t.Error(err)
+	}
+}
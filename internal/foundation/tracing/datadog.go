@@ -0,0 +1,56 @@
+// Package tracing provides the middleware that opens a server span around
+// every request handled by a web.App.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+// Datadog starts a DataDog span per request, continuing any trace propagated
+// in the request headers. The span is named after the route pattern so all
+// requests for /permission/{id} group together. Handlers get the span in
+// their ctx, so sqltrace query spans end up as its children.
+func Datadog(serviceName string) web.Middleware {
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v := web.GetValues(ctx)
+
+			opts := []ddtrace.StartSpanOption{
+				tracer.ServiceName(serviceName),
+				tracer.SpanType(ext.SpanTypeWeb),
+				tracer.ResourceName(r.Method + " " + v.Route),
+				tracer.Tag(ext.HTTPMethod, r.Method),
+				tracer.Tag(ext.HTTPRoute, v.Route),
+				tracer.Tag(ext.HTTPURL, r.URL.Path),
+			}
+			if sctx, err := tracer.Extract(tracer.HTTPHeadersCarrier(r.Header)); err == nil {
+				opts = append(opts, tracer.ChildOf(sctx))
+			}
+			span, ctx := tracer.StartSpanFromContext(ctx, "http.request", opts...)
+
+			err := handler(ctx, w, r)
+
+			status := v.StatusCode
+			if err != nil {
+				status = bestirerror.StatusCode(err)
+			}
+			span.SetTag(ext.HTTPCode, strconv.Itoa(status))
+			if status >= http.StatusInternalServerError {
+				span.Finish(tracer.WithError(err))
+				return err
+			}
+			span.Finish()
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/tracing"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+func TestDatadog(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	app := web.NewApp(tracing.Datadog("test-service"))
+	app.Handle(http.MethodGet, "/permission/{id}", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if _, ok := tracer.SpanFromContext(ctx); !ok {
+			t.Error("expected handler ctx to carry the request span")
+		}
+		return web.Respond(ctx, w, map[string]string{"id": "1"}, http.StatusOK)
+	})
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/permission/1", nil))
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	got := map[string]interface{}{
+		ext.ResourceName: spans[0].Tag(ext.ResourceName),
+		ext.HTTPRoute:    spans[0].Tag(ext.HTTPRoute),
+		ext.HTTPCode:     spans[0].Tag(ext.HTTPCode),
+		ext.ServiceName:  spans[0].Tag(ext.ServiceName),
+	}
+	want := map[string]interface{}{
+		ext.ResourceName: "GET /permission/{id}",
+		ext.HTTPRoute:    "/permission/{id}",
+		ext.HTTPCode:     "200",
+		ext.ServiceName:  "test-service",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
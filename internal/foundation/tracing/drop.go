@@ -0,0 +1,22 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Drop keeps the trace ctx belongs to from being sent, whichever tracer is
+// in use. It's meant for high frequency, low value requests such as health
+// checks.
+func Drop(ctx context.Context) {
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		span.SetTag(ext.ManualDrop, true)
+	}
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		span.SetAttributes(dropAttr.Bool(true))
+		droppedTraces.Store(span.SpanContext().TraceID(), struct{}{})
+	}
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+const instrumentationName = "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/tracing"
+
+// OTelConfig describes the service to the OTLP collector. The exporter
+// itself is configured through the standard OTEL_EXPORTER_OTLP_* variables.
+type OTelConfig struct {
+	ServiceName string
+	Version     string
+	Env         string
+}
+
+// NewOTelProvider creates a tracer provider that batches spans to an OTLP
+// collector over HTTP and installs it, along with the W3C trace context
+// propagator, as the global provider. Callers must Shutdown the provider
+// to flush the last batch.
+func NewOTelProvider(ctx context.Context, cfg OTelConfig) (*sdktrace.TracerProvider, error) {
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(cfg.ServiceName),
+		semconv.ServiceVersionKey.String(cfg.Version),
+		semconv.DeploymentEnvironmentKey.String(cfg.Env),
+	)
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(newDropProcessor(sdktrace.NewBatchSpanProcessor(exp))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return tp, nil
+}
+
+// OTel starts an OpenTelemetry server span per request, continuing any
+// trace propagated through the W3C traceparent header. Like Datadog the
+// span is named after the route pattern and handed to the handler in ctx.
+func OTel() web.Middleware {
+	tr := otel.Tracer(instrumentationName)
+	return func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v := web.GetValues(ctx)
+
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+			ctx, span := tr.Start(ctx, r.Method+" "+v.Route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPMethodKey.String(r.Method),
+					semconv.HTTPRouteKey.String(v.Route),
+					semconv.HTTPTargetKey.String(r.URL.Path),
+				),
+			)
+			defer span.End()
+
+			err := handler(ctx, w, r)
+
+			status := v.StatusCode
+			if err != nil {
+				status = bestirerror.StatusCode(err)
+			}
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+			if status >= http.StatusInternalServerError {
+				if err != nil {
+					span.RecordError(err)
+				}
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			return err
+		}
+	}
+}
+
+// dropAttr marks a span whose trace was dropped with Drop.
+var dropAttr = attribute.Key("manual.drop")
+
+// droppedTraces holds the ids of traces Drop was called on, until their
+// local root span ends.
+var droppedTraces sync.Map
+
+// dropProcessor keeps every span of a dropped trace from reaching the
+// exporter. OpenTelemetry samples when a span starts, which is before a
+// handler knows it's serving a health check, so this is our equivalent of
+// DataDog's ManualDrop.
+type dropProcessor struct {
+	sdktrace.SpanProcessor
+}
+
+func newDropProcessor(next sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return dropProcessor{SpanProcessor: next}
+}
+
+func (p dropProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	traceID := s.SpanContext().TraceID()
+	if _, ok := droppedTraces.Load(traceID); !ok {
+		p.SpanProcessor.OnEnd(s)
+		return
+	}
+	// once the local root ends nothing else of the trace is coming
+	if parent := s.Parent(); !parent.IsValid() || parent.IsRemote() {
+		droppedTraces.Delete(traceID)
+	}
+}
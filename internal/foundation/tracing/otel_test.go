@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+func TestOTel(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newDropProcessor(sr)))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	app := web.NewApp(OTel())
+	app.Handle(http.MethodGet, "/permission/{id}", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		// a child span, like the ones otelsql starts for queries
+		_, span := otel.Tracer("test").Start(ctx, "query")
+		span.End()
+		return web.Respond(ctx, w, map[string]string{"id": "1"}, http.StatusOK)
+	})
+	app.Handle(http.MethodGet, "/healthz", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		Drop(ctx)
+		_, span := otel.Tracer("test").Start(ctx, "query")
+		span.End()
+		return web.Respond(ctx, w, map[string]string{"status": "ok"}, http.StatusOK)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest(http.MethodGet, "/permission/1", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	app.ServeHTTP(httptest.NewRecorder(), r)
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected the query and request spans only, got %d spans", len(spans))
+	}
+	server := spans[1]
+	if got := server.Name(); got != "GET /permission/{id}" {
+		t.Errorf("unexpected span name %q", got)
+	}
+	if got := server.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("expected trace %s to be continued, got %s", traceID, got)
+	}
+	if !server.Parent().IsRemote() {
+		t.Error("expected the request span's parent to be the remote caller")
+	}
+}
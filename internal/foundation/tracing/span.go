@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Span is a child span started with whichever tracer is in use.
+type Span struct {
+	otel trace.Span
+	dd   ddtrace.Span
+	// events counts the DataDog span's events, which it only has as tags
+	events int
+}
+
+// StartSpan starts a span named operation as a child of the span in ctx.
+// Without a tracer configured it records nothing.
+func StartSpan(ctx context.Context, operation string) (context.Context, *Span) {
+	var s Span
+	if _, ok := tracer.SpanFromContext(ctx); ok {
+		s.dd, ctx = tracer.StartSpanFromContext(ctx, operation)
+	}
+	ctx, s.otel = otel.Tracer(instrumentationName).Start(ctx, operation)
+	return ctx, &s
+}
+
+// SetTag sets a string attribute on the span.
+func (s *Span) SetTag(key, value string) {
+	s.otel.SetAttributes(attribute.String(key, value))
+	if s.dd != nil {
+		s.dd.SetTag(key, value)
+	}
+}
+
+// Event records that name happened. DataDog spans have no events, they get
+// event.<n> tags instead.
+func (s *Span) Event(name string) {
+	s.otel.AddEvent(name)
+	if s.dd != nil {
+		s.dd.SetTag("event."+strconv.Itoa(s.events), name)
+		s.events++
+	}
+}
+
+// Finish ends the span, marking it failed when err isn't nil.
+func (s *Span) Finish(err error) {
+	if err != nil {
+		s.otel.RecordError(err)
+		s.otel.SetStatus(codes.Error, err.Error())
+	}
+	s.otel.End()
+	if s.dd != nil {
+		s.dd.Finish(tracer.WithError(err))
+	}
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types Respond knows how to produce.
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeMsgPack = "application/msgpack"
+)
+
+// Content codings Respond knows how to produce.
+const (
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+	EncodingZstd     = "zstd"
+)
+
+// mediaTypeAliases maps media types clients commonly send onto the
+// canonical type we respond with.
+var mediaTypeAliases = map[string]string{
+	"application/x-msgpack":   MediaTypeMsgPack,
+	"application/vnd.msgpack": MediaTypeMsgPack,
+}
+
+// acceptRange is a single entry of an Accept or Accept-Encoding header.
+type acceptRange struct {
+	value string
+	q     float64
+}
+
+// parseAccept splits an Accept style header into its ranges, sorted by
+// descending quality. Entries with a malformed q value are treated as q=1
+// to be forgiving of sloppy clients.
+func parseAccept(header string) []acceptRange {
+	if header == "" {
+		return nil
+	}
+	parts := strings.Split(header, ",")
+	ranges := make([]acceptRange, 0, len(parts))
+	for _, part := range parts {
+		fields := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(fields[0]))
+		if value == "" {
+			continue
+		}
+		if alias, ok := mediaTypeAliases[value]; ok {
+			value = alias
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			k, v, ok := cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(k) != "q" {
+				continue
+			}
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		ranges = append(ranges, acceptRange{value: value, q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	return ranges
+}
+
+// quality returns the q value the ranges assign to candidate, preferring
+// the most specific matching range. The second return is false when no
+// range matches at all.
+func quality(ranges []acceptRange, candidate string) (float64, bool) {
+	typ, _, _ := cut(candidate, "/")
+	best, specificity, found := 0.0, -1, false
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.value == candidate:
+			s = 2
+		case r.value == typ+"/*":
+			s = 1
+		case r.value == "*/*" || r.value == "*":
+			s = 0
+		}
+		if s > specificity {
+			best, specificity, found = r.q, s, true
+		}
+	}
+	return best, found
+}
+
+// negotiate picks the candidate with the highest quality in header. Ties go
+// to whichever candidate comes first. If the header is empty or nothing
+// acceptable is offered the first candidate is returned, we would rather
+// answer with our default than fail the request with a 406.
+func negotiate(header string, candidates ...string) string {
+	ranges := parseAccept(header)
+	if len(ranges) == 0 {
+		return candidates[0]
+	}
+	chosen, chosenQ := candidates[0], 0.0
+	for _, c := range candidates {
+		if q, ok := quality(ranges, c); ok && q > chosenQ {
+			chosen, chosenQ = c, q
+		}
+	}
+	return chosen
+}
+
+// negotiateEncoding picks a content coding from an Accept-Encoding header.
+// Identity is always acceptable unless the client explicitly refuses it,
+// and we never refuse a client that refuses everything we offer.
+func negotiateEncoding(header string) string {
+	ranges := parseAccept(header)
+	chosen, chosenQ := EncodingIdentity, 0.0
+	for _, c := range []string{EncodingZstd, EncodingGzip} {
+		if q, ok := quality(ranges, c); ok && q > chosenQ {
+			chosen, chosenQ = c, q
+		}
+	}
+	return chosen
+}
+
+// cut is strings.Cut, which isn't available on the go version in go.mod.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
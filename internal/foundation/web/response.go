@@ -1,30 +1,130 @@
 package web
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+)
+
+// compressMinBytes is the smallest body worth compressing, below this the
+// framing overhead eats most of the savings.
+const compressMinBytes = 1024
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
 )
 
-// Converts a Go value to JSON and sends it to the client
+// Respond encodes a Go value using the media type negotiated from the
+// request's Accept header and sends it to the client. JSON is the default
+// and MessagePack is offered for every value. Large bodies are compressed
+// when the client accepts it.
 func Respond(ctx context.Context, w http.ResponseWriter, resp interface{}, statusCode int) error {
+	v := GetValues(ctx)
+	if v == nil {
+		v = &Values{}
+	}
+
 	if statusCode == http.StatusNoContent || resp == nil {
+		v.StatusCode = statusCode
 		w.WriteHeader(statusCode)
 		return nil
 	}
 
-	jsonData, err := json.Marshal(resp)
+	mediaType := negotiate(v.Accept, MediaTypeJSON, MediaTypeMsgPack)
+
+	data, err := encode(mediaType, resp)
 	if err != nil {
 		return err
 	}
 
-	w.Header().Set("Content-Type", "permission/json")
+	encoding := EncodingIdentity
+	if len(data) >= compressMinBytes {
+		encoding = negotiateEncoding(v.AcceptEncoding)
+	}
+	if data, err = compress(encoding, data); err != nil {
+		return err
+	}
 
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", "Accept-Encoding")
+	if encoding != EncodingIdentity {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+
+	// only once nothing can fail before the header goes out, App.Handle
+	// still responds with the error otherwise
+	v.StatusCode = statusCode
 	w.WriteHeader(statusCode)
 
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(data); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// ErrorResponse is the form used for API responses from failures in the API.
+type ErrorResponse struct {
+	Error   string   `json:"error"`
+	Details []string `json:"details,omitempty"`
+}
+
+// RespondError sends err to the client with the status code, user message
+// and details attached to it through bestirerror.
+func RespondError(ctx context.Context, w http.ResponseWriter, err error) error {
+	return Respond(ctx, w, ErrorResponse{
+		Error:   bestirerror.UserMessage(err),
+		Details: bestirerror.Details(err),
+	}, bestirerror.StatusCode(err))
+}
+
+func encode(mediaType string, resp interface{}) ([]byte, error) {
+	switch mediaType {
+	case MediaTypeMsgPack:
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		// reuse the json tags so every response type doesn't need a second set
+		enc.SetCustomStructTag("json")
+		if err := enc.Encode(resp); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return json.Marshal(resp)
+	}
+}
+
+func compress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		zstdOnce.Do(func() {
+			// a nil writer is only used through EncodeAll, which is safe for concurrent use
+			zstdEncoder, _ = zstd.NewWriter(nil)
+		})
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return data, nil
+	}
+}
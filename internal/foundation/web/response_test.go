@@ -0,0 +1,139 @@
+package web_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+type item struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type itemList struct {
+	Items []item `json:"items"`
+}
+
+func TestRespondNegotiation(t *testing.T) {
+	small := itemList{Items: []item{{ID: "1", Name: "games.build.deploy"}}}
+	large := itemList{}
+	for i := 0; i < 100; i++ {
+		large.Items = append(large.Items, item{ID: "id", Name: "games.build.deploy"})
+	}
+
+	tests := []struct {
+		name         string
+		accept       string
+		encoding     string
+		body         itemList
+		wantType     string
+		wantEncoding string
+	}{
+		{name: "Default", body: small, wantType: web.MediaTypeJSON},
+		{name: "Wildcard", accept: "*/*", body: small, wantType: web.MediaTypeJSON},
+		{name: "MsgPack", accept: "application/msgpack", body: small, wantType: web.MediaTypeMsgPack},
+		{name: "MsgPackAlias", accept: "application/x-msgpack", body: small, wantType: web.MediaTypeMsgPack},
+		{name: "Quality", accept: "application/json;q=0.5, application/msgpack", body: small, wantType: web.MediaTypeMsgPack},
+		{name: "ProtobufUnsupported", accept: "application/x-protobuf", body: small, wantType: web.MediaTypeJSON},
+		{name: "Unsupported", accept: "text/html", body: small, wantType: web.MediaTypeJSON},
+		{name: "SmallNotCompressed", encoding: "gzip", body: small, wantType: web.MediaTypeJSON},
+		{name: "Gzip", encoding: "gzip", body: large, wantType: web.MediaTypeJSON, wantEncoding: web.EncodingGzip},
+		{name: "Zstd", encoding: "gzip, zstd", body: large, wantType: web.MediaTypeJSON, wantEncoding: web.EncodingZstd},
+		{name: "ZstdRefused", encoding: "zstd;q=0, gzip", body: large, wantType: web.MediaTypeJSON, wantEncoding: web.EncodingGzip},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			app := web.NewApp()
+			app.Handle(http.MethodGet, "/items", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return web.Respond(ctx, w, tt.body, http.StatusOK)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/items", nil)
+			r.Header.Set("Accept", tt.accept)
+			r.Header.Set("Accept-Encoding", tt.encoding)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			diff(t, tt.wantType, w.Header().Get("Content-Type"))
+			diff(t, tt.wantEncoding, w.Header().Get("Content-Encoding"))
+
+			got := decode(t, w.Body.Bytes(), tt.wantType, tt.wantEncoding)
+			diff(t, tt.body, got)
+		})
+	}
+}
+
+func TestRespondEncodeError(t *testing.T) {
+	app := web.NewApp()
+	app.Handle(http.MethodGet, "/items", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		// channels can't be encoded
+		return web.Respond(ctx, w, make(chan int), http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	diff(t, http.StatusInternalServerError, w.Code)
+	var got web.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Error == "" {
+		t.Error("error response has no message")
+	}
+}
+
+func decode(t *testing.T, data []byte, mediaType, encoding string) itemList {
+	t.Helper()
+	var r io.Reader = bytes.NewReader(data)
+	switch encoding {
+	case web.EncodingGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case web.EncodingZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var out itemList
+	switch mediaType {
+	case web.MediaTypeMsgPack:
+		dec := msgpack.NewDecoder(r)
+		dec.SetCustomStructTag("json")
+		if err := dec.Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		if err := json.NewDecoder(r).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
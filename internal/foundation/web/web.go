@@ -3,45 +3,104 @@ package web
 import (
 	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) error
 
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// key is how request values are stored/retrieved.
+const key ctxKey = 1
+
+// Values represent state for each request.
+type Values struct {
+	Now            time.Time
+	Route          string
+	StatusCode     int
+	Accept         string
+	AcceptEncoding string
+}
+
+// GetValues returns the request values stored in ctx by App.Handle, or nil
+// when ctx did not come from a handled request.
+func GetValues(ctx context.Context) *Values {
+	v, _ := ctx.Value(key).(*Values)
+	return v
+}
+
 // we can enrich this functionality later let's just get this bitch up and running
 type App struct {
 	*chi.Mux
 	// shutdown chan os.Signal
-	// mw []Middleware
+	mw       []Middleware
+	draining int32
+	drain    sync.Once
+	drained  chan struct{}
 }
 
-func NewApp() *App {
+// NewApp creates an App that wraps every handler in the given middleware.
+func NewApp(mw ...Middleware) *App {
 	r := chi.NewRouter()
 	return &App{
-		r,
+		Mux:     r,
+		mw:      mw,
+		drained: make(chan struct{}),
 	}
 }
 
-func (a App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	a.Mux.ServeHTTP(w, r)
 }
 
+// Drain marks the app as shutting down. Requests are still served, but
+// Draining reports true so readiness checks can steer new traffic away
+// before the server stops accepting connections.
+func (a *App) Drain() {
+	atomic.StoreInt32(&a.draining, 1)
+	a.drain.Do(func() { close(a.drained) })
+}
+
+// Drained is closed by Drain. Long-lived responses like event streams
+// select on it to end early, http.Server.Shutdown would otherwise wait on
+// them until its deadline.
+func (a *App) Drained() <-chan struct{} {
+	return a.drained
+}
+
+// Draining reports whether Drain has been called.
+func (a *App) Draining() bool {
+	return atomic.LoadInt32(&a.draining) == 1
+}
+
 // add `ops []operations.Option` before middleware variadic
 func (a *App) Handle(method string, path string, handler Handler, mw ...Middleware) {
 	// Wrap handler specific middlwares
+	handler = wrapMiddleware(mw, handler)
 
 	// Wrap service general middlewares
+	handler = wrapMiddleware(a.mw, handler)
 
 	// Request execution
 	h := func(w http.ResponseWriter, r *http.Request) {
 		// start trace, which I'm assuming relies on upstream logic for this
-		ctx := r.Context()
+		v := Values{
+			Now:            time.Now().UTC(),
+			Route:          path,
+			Accept:         r.Header.Get("Accept"),
+			AcceptEncoding: r.Header.Get("Accept-Encoding"),
+		}
+		ctx := context.WithValue(r.Context(), key, &v)
 
-		// call wrapped handler
-		if err := handler(ctx, w, r); err != nil {
-			// signal shutdown
-			return
+		// call wrapped handler, errors that haven't been responded to yet
+		// are sent to the client here
+		if err := handler(ctx, w, r); err != nil && v.StatusCode == 0 {
+			RespondError(ctx, w, err) //nolint:errcheck
 		}
 	}
 
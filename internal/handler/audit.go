@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+const (
+	// actorHeader names who's making the request. The service doesn't
+	// authenticate it, only a gateway in front of it that strips the header
+	// from client requests and sets it itself can vouch for it. Changes
+	// without it are audited as audit.UnknownActor.
+	actorHeader = "X-Actor"
+	// requestIDHeader carries the request's id, one is generated when the
+	// client doesn't send it. It's echoed back and recorded in the audit log.
+	requestIDHeader = "X-Request-ID"
+	// claimedActorPrefix marks audited actors taken from an actorHeader
+	// nobody vouched for, anyone could have sent it.
+	claimedActorPrefix = "claimed:"
+	// maxRequestInfo is the longest actor or request id the audit_log and
+	// outbox columns holding them take.
+	maxRequestInfo = 255
+)
+
+// requestInfo attributes the request's changes to its actorHeader, as is
+// when trustActor says a gateway sets it and prefixed with
+// claimedActorPrefix otherwise. Actors too long to record are refused, a
+// request id that is gets replaced like a missing one.
+func requestInfo(trustActor bool) web.Middleware {
+	return func(next web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			requestID := r.Header.Get(requestIDHeader)
+			if requestID == "" || len(requestID) > maxRequestInfo {
+				requestID = uuid.NewString()
+			}
+			w.Header().Set(requestIDHeader, requestID)
+			actor, maxActor := r.Header.Get(actorHeader), maxRequestInfo
+			if actor != "" && !trustActor {
+				actor = claimedActorPrefix + actor
+				maxActor -= len(claimedActorPrefix)
+			}
+			if len(actor) > maxRequestInfo {
+				return bestirerror.WithCodeAndMessagef(errors.New("actor too long"), http.StatusBadRequest, "%s can be at most %d bytes", actorHeader, maxActor)
+			}
+			ctx = audit.WithRequest(ctx, actor, requestID)
+			return next(ctx, w, r)
+		}
+	}
+}
+
+type auditGroup struct {
+	*audit.API
+}
+
+type ListAuditRecordsResponse struct {
+	Records []audit.Record `json:"records"`
+}
+
+func auditEndpoints(app *web.App, api *audit.API) {
+	ag := auditGroup{API: api}
+
+	app.Handle("GET", "/audit", ag.ListRecords)
+}
+
+// ListRecords serves the audit log newest first, filtered by the actor,
+// target_type, target_id, since and until (RFC 3339) query parameters and
+// capped at limit records.
+func (ag auditGroup) ListRecords(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	filter, err := auditFilter(r)
+	if err != nil {
+		return err
+	}
+
+	records, err := ag.API.ListRecords(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, ListAuditRecordsResponse{
+		Records: records,
+	}, http.StatusOK)
+}
+
+func auditFilter(r *http.Request) (audit.Filter, error) {
+	q := r.URL.Query()
+	filter := audit.Filter{
+		Actor:      q.Get("actor"),
+		TargetType: q.Get("target_type"),
+	}
+	if raw := q.Get("target_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return filter, bestirerror.WithCodeAndMessagef(err, http.StatusBadRequest, "target_id %q is not a valid uuid", raw)
+		}
+		filter.TargetID = id
+	}
+	for name, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		raw := q.Get(name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return filter, bestirerror.WithCodeAndMessagef(err, http.StatusBadRequest, "%s %q is not an RFC 3339 time", name, raw)
+		}
+		*dst = t
+	}
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > audit.MaxLimit {
+			return filter, bestirerror.WithCodeAndMessagef(err, http.StatusBadRequest, "limit must be between 1 and %d", audit.MaxLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+const (
+	// consistencyHeader lets a client that has to read its own writes send
+	// "X-Consistency: strong" to have its reads served by the primary
+	// instead of a replica.
+	consistencyHeader = "X-Consistency"
+	// revisionHeader carries the consistency token of a successful write.
+	// Reads given it as at_least_as_fresh see that write and everything
+	// before it, wherever they're served from.
+	revisionHeader = "X-Revision"
+	freshnessParam = "at_least_as_fresh"
+)
+
+func consistency(next web.Handler) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if strings.EqualFold(r.Header.Get(consistencyHeader), "strong") {
+			ctx = database.WithStrongConsistency(ctx)
+		}
+		if token := r.URL.Query().Get(freshnessParam); token != "" {
+			rev, err := outbox.ParseToken(token)
+			if err != nil {
+				return bestirerror.WithCodeAndMessagef(err, http.StatusBadRequest, "%s %q is not a revision token", freshnessParam, token)
+			}
+			ctx = database.WithMinRevision(ctx, rev)
+		}
+
+		ctx = outbox.WithRevision(ctx)
+		return next(ctx, &revisionWriter{ResponseWriter: w, ctx: ctx}, r)
+	}
+}
+
+// revisionWriter adds the revisionHeader to successful responses of
+// requests that wrote something.
+type revisionWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+}
+
+func (w *revisionWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status < http.StatusBadRequest {
+		if rev := outbox.Revision(w.ctx); rev > 0 {
+			w.Header().Set(revisionHeader, outbox.Token(rev))
+		}
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *revisionWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps /watch streaming through the wrapper.
+func (w *revisionWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+type decisionGroup struct {
+	engine *decision.Engine
+}
+
+func decisionEndpoints(app *web.App, engine *decision.Engine) {
+	dg := decisionGroup{engine: engine}
+
+	app.Handle("POST", "/check", dg.Check)
+}
+
+// Check decides whether a role is granted a permission, with the trace of
+// how.
+func (dg decisionGroup) Check(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var q decision.Query
+	if err := web.Decode(r.Body, &q); err != nil {
+		return err
+	}
+
+	d, err := dg.engine.Check(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, d, http.StatusOK)
+}
@@ -2,10 +2,55 @@ package handler
 
 import (
 	"database/sql"
+	"time"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/decisionlog"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/metrics"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/webhook"
 )
 
 type Deps struct {
 	// Logger // must have do eet
 	// Conn *pgx.Conn
 	DB *sql.DB
+
+	Permissions permission.Store
+	Roles       role.Store
+	// Audit, when set, serves the audit log the stores write on /audit.
+	Audit audit.Store
+	// TrustActorHeader records the X-Actor header as the audited actor. Only
+	// set it behind a gateway that strips the header from client requests
+	// and sets it itself, otherwise actors are recorded as claimed:<actor>.
+	TrustActorHeader bool
+	// Events, when set, streams the outbox on /watch, checking for new
+	// events every WatchInterval (DefaultWatchInterval when zero).
+	Events        outbox.Store
+	WatchInterval time.Duration
+	// Webhooks, when set, serves webhook subscriptions and their delivery
+	// logs on /webhook.
+	Webhooks webhook.Store
+	// Snapshots, when set, exports the policy on /export, imports it on
+	// /import and simulates changes to it on /simulate.
+	Snapshots snapshot.Store
+
+	// MigrationVersion is the schema version this build expects, readiness
+	// fails until the database has been migrated at least this far.
+	MigrationVersion int64
+
+	// DecisionLog, when set, gets the decisions made on /check it samples,
+	// OnDecisionLogError the errors writing them.
+	DecisionLog        *decisionlog.Logger
+	OnDecisionLogError func(error)
+
+	// Metrics, when set, records request metrics and is served on /metrics.
+	Metrics *metrics.Metrics
+
+	// Tracing, when set, opens a span around every request.
+	Tracing web.Middleware
 }
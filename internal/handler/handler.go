@@ -1,20 +1,107 @@
 package handler
 
 import (
+	"context"
 	"net/http"
+	"strconv"
 
-	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
 	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
 	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/webhook"
 )
 
 var _ http.Handler = (*web.App)(nil)
 
 // maybe we'll add gitsha and other params later
 func API(d Deps) *web.App {
-	app := web.NewApp()
-	dbrConn := database.NewDBR(d.DB)
-	permissionAPI := permission.NewAPI(permission.NewMySQLStore(dbrConn))
-	permissionEndpoints(app, permissionAPI)
+	var mw []web.Middleware
+	if d.Tracing != nil {
+		mw = append(mw, d.Tracing)
+	}
+	if d.Metrics != nil {
+		mw = append(mw, d.Metrics.Middleware())
+	}
+	mw = append(mw, requestInfo(d.TrustActorHeader), consistency)
+
+	app := web.NewApp(mw...)
+	healthEndpoints(app, d)
+	if d.Metrics != nil {
+		app.Mux.Method(http.MethodGet, "/metrics", d.Metrics.Handler())
+	}
+
+	permissionEndpoints(app, permission.NewAPI(d.Permissions))
+	roleEndpoints(app, role.NewAPI(d.Roles))
+	decisionEndpoints(app, newEngine(d))
+	if d.Audit != nil {
+		auditEndpoints(app, audit.NewAPI(d.Audit))
+	}
+	if d.Events != nil {
+		watchEndpoints(app, d.Events, d.WatchInterval)
+	}
+	if d.Webhooks != nil {
+		webhookEndpoints(app, webhook.NewAPI(d.Webhooks))
+	}
+	if d.Snapshots != nil {
+		snapshotEndpoints(app, snapshot.NewAPI(d.Snapshots))
+	}
 	return app
 }
+
+// newEngine is the decision engine behind /check, counting decisions in
+// d.Metrics and logging them to d.DecisionLog against the latest outbox
+// position as the policy revision.
+func newEngine(d Deps) *decision.Engine {
+	engine := decision.NewEngine(d.Permissions, d.Roles)
+	engine.Metrics = d.Metrics
+	engine.Log, engine.OnLogError = d.DecisionLog, d.OnDecisionLogError
+	if d.Events != nil {
+		engine.Revision = func(ctx context.Context) (string, error) {
+			position, err := d.Events.LatestPosition(ctx)
+			return strconv.FormatInt(position, 10), err
+		}
+	}
+	return engine
+}
+
+// idParam parses the {id} URL parameter of r.
+func idParam(r *http.Request) (uuid.UUID, error) {
+	raw := chi.URLParam(r, "id")
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, bestirerror.WithCodeAndMessagef(err, http.StatusBadRequest, "id %q is not a valid uuid", raw)
+	}
+	return id, nil
+}
+
+// includeDeleted parses the include_deleted query parameter of r, false when
+// it's absent.
+func includeDeleted(r *http.Request) (bool, error) {
+	return boolQuery(r, "include_deleted")
+}
+
+// cascade parses the cascade query parameter of r, false when it's absent.
+func cascade(r *http.Request) (bool, error) {
+	return boolQuery(r, "cascade")
+}
+
+// boolQuery parses the query parameter name of r as a bool, false when it's
+// absent.
+func boolQuery(r *http.Request, name string) (bool, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, bestirerror.WithCodeAndMessagef(err, http.StatusBadRequest, "%s %q is not a valid bool", name, raw)
+	}
+	return v, nil
+}
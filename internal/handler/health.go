@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/tracing"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+)
+
+// readinessTimeout bounds how long a readiness probe waits on the database,
+// orchestrators usually give up after a second or so anyway.
+const readinessTimeout = time.Second
+
+const (
+	statusOK       = "ok"
+	statusNotReady = "not ready"
+	statusDraining = "draining"
+)
+
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type healthGroup struct {
+	app            *web.App
+	db             *sql.DB
+	desiredVersion int64
+}
+
+func healthEndpoints(app *web.App, d Deps) {
+	hg := healthGroup{app: app, db: d.DB, desiredVersion: d.MigrationVersion}
+
+	app.Handle("GET", "/healthz", hg.Liveness)
+	app.Handle("GET", "/readyz", hg.Readiness)
+}
+
+// Liveness only says the process is up and serving, it deliberately doesn't
+// look at dependencies so a database outage doesn't get every pod restarted.
+func (hg healthGroup) Liveness(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	// probes hit this every few seconds, keep them out of the traces
+	tracing.Drop(ctx)
+
+	return web.Respond(ctx, w, HealthResponse{Status: statusOK}, http.StatusOK)
+}
+
+// Readiness says whether this instance should receive traffic: the database
+// must be reachable, its schema must be at least the version this build
+// expects, and the service must not be draining for shutdown.
+func (hg healthGroup) Readiness(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if hg.app.Draining() {
+		return web.Respond(ctx, w, HealthResponse{Status: statusDraining}, http.StatusServiceUnavailable)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	resp := HealthResponse{Status: statusOK, Checks: map[string]string{}}
+	status := http.StatusOK
+
+	if err := database.StatusCheck(ctx, hg.db); err != nil {
+		resp.Checks["database"] = err.Error()
+		status = http.StatusServiceUnavailable
+	} else {
+		resp.Checks["database"] = statusOK
+	}
+
+	version, err := db.CurrentVersion(ctx, hg.db)
+	switch {
+	case err != nil:
+		resp.Checks["migrations"] = err.Error()
+		status = http.StatusServiceUnavailable
+	case version < hg.desiredVersion:
+		// a newer schema is fine, that's just a rollout ahead of us
+		resp.Checks["migrations"] = fmt.Sprintf("at version %d, want %d", version, hg.desiredVersion)
+		status = http.StatusServiceUnavailable
+	default:
+		resp.Checks["migrations"] = statusOK
+	}
+
+	if status != http.StatusOK {
+		resp.Status = statusNotReady
+	}
+	return web.Respond(ctx, w, resp, status)
+}
@@ -2,25 +2,18 @@ package handler
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 
 	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
 	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
-	"github.com/go-chi/chi/v5"
 )
 
-type permission struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-}
-
 type permissionGroup struct {
 	*permission.API
 }
 
 type ListpermissionsResponse struct {
-	permissions []permission.permission `json:"permissions"`
+	Permissions []permission.Permission `json:"permissions"`
 }
 
 func permissionEndpoints(app *web.App, api *permission.API) {
@@ -29,34 +22,29 @@ func permissionEndpoints(app *web.App, api *permission.API) {
 	app.Handle("GET", "/permission/{id}", ag.Getpermission)
 	app.Handle("GET", "/permission", ag.Listpermissiones)
 	app.Handle("POST", "/permission", ag.Createpermission)
-	app.Handle("DELETE", "/permission/{id}", ag.Createpermission)
-	app.Handle("PUT", "/permission/{id}", ag.Createpermission)
+	app.Handle("DELETE", "/permission/{id}", ag.Deletepermission)
+	app.Handle("PUT", "/permission/{id}", ag.Updatepermission)
+	app.Handle("POST", "/permission/{id}:restore", ag.Restorepermission)
+	app.Handle("GET", "/permission/{id}/dependents", ag.ListpermissionDependents)
 }
 
 func (ag permissionGroup) Listpermissiones(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	permissions, err := ag.API.Listpermissiones(ctx)
+	include, err := includeDeleted(r)
+	if err != nil {
+		return err
+	}
+
+	permissions, err := ag.API.Listpermissiones(ctx, include)
 	if err != nil {
 		return err
 	}
 
 	return web.Respond(ctx, w, ListpermissionsResponse{
-		permissions: permissions,
+		Permissions: permissions,
 	}, http.StatusOK)
 }
 
-// permissions := []permission.permission{
-// 	{
-// 		ID:      69,
-// 		Balance: 69,
-// 	},
-// 	{
-// 		ID:      420,
-// 		Balance: 420,
-// 	},
-// }
-
 func (ag permissionGroup) Createpermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	fmt.Println("Create permission a invoked")
 	var input permission.Incomingpermission
 	if err := web.Decode(r.Body, &input); err != nil {
 		return err
@@ -71,41 +59,85 @@ func (ag permissionGroup) Createpermission(ctx context.Context, w http.ResponseW
 }
 
 func (ag permissionGroup) Getpermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	permissionID := chi.URLParam(r, "permission_id")
-	if permissionID == "" {
-		return nil
-		// return handleMissingURLParameter(ctx, permissionID, permission)
+	permissionID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	include, err := includeDeleted(r)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	permission, err := ag.API.Getpermission(ctx, permissionID, include)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, permission, http.StatusOK)
 }
 
 func (ag permissionGroup) Updatepermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	fmt.Println("Create permission a invoked")
+	permissionID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
 	var input permission.Incomingpermission
 	if err := web.Decode(r.Body, &input); err != nil {
 		return err
 	}
 
-	permission, err := ag.API.Updatepermission(ctx, input)
+	permission, err := ag.API.Updatepermission(ctx, permissionID, input)
 	if err != nil {
 		return err
 	}
 
-	return web.Respond(ctx, w, permission, http.StatusCreated)
+	return web.Respond(ctx, w, permission, http.StatusOK)
 }
 
 func (ag permissionGroup) Deletepermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	fmt.Println("Create permission a invoked")
-	var input permission.Incomingpermission
-	if err := web.Decode(r.Body, &input); err != nil {
+	permissionID, err := idParam(r)
+	if err != nil {
 		return err
 	}
 
-	permission, err := ag.API.Deletepermission(ctx, input)
+	cascading, err := cascade(r)
 	if err != nil {
 		return err
 	}
 
-	return web.Respond(ctx, w, permission, http.StatusCreated)
+	if err := ag.API.Deletepermission(ctx, permissionID, cascading); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (ag permissionGroup) Restorepermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	permissionID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	permission, err := ag.API.Restorepermission(ctx, permissionID)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, permission, http.StatusOK)
+}
+
+func (ag permissionGroup) ListpermissionDependents(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	permissionID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	dependents, err := ag.API.ListpermissionDependents(ctx, permissionID)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, dependents, http.StatusOK)
 }
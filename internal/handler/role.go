@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+type roleGroup struct {
+	*role.API
+}
+
+type ListRolesResponse struct {
+	Roles []role.Role `json:"roles"`
+}
+
+type RolePermissionsResponse struct {
+	PermissionIDs []uuid.UUID `json:"permission_ids"`
+}
+
+func roleEndpoints(app *web.App, api *role.API) {
+	rg := roleGroup{API: api}
+
+	app.Handle("GET", "/role/{id}", rg.GetRole)
+	app.Handle("GET", "/role", rg.ListRoles)
+	app.Handle("POST", "/role", rg.CreateRole)
+	app.Handle("DELETE", "/role/{id}", rg.DeleteRole)
+	app.Handle("PUT", "/role/{id}", rg.UpdateRole)
+	app.Handle("POST", "/role/{id}:restore", rg.RestoreRole)
+	app.Handle("GET", "/role/{id}/permissions", rg.ListRolePermissions)
+	app.Handle("PUT", "/role/{id}/permissions", rg.ReplaceRolePermissions)
+}
+
+func (rg roleGroup) ListRoles(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	include, err := includeDeleted(r)
+	if err != nil {
+		return err
+	}
+
+	roles, err := rg.API.ListRoles(ctx, include)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, ListRolesResponse{
+		Roles: roles,
+	}, http.StatusOK)
+}
+
+func (rg roleGroup) CreateRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var input role.IncomingRole
+	if err := web.Decode(r.Body, &input); err != nil {
+		return err
+	}
+
+	role, err := rg.API.CreateRole(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, role, http.StatusCreated)
+}
+
+func (rg roleGroup) GetRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roleID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	include, err := includeDeleted(r)
+	if err != nil {
+		return err
+	}
+
+	role, err := rg.API.GetRole(ctx, roleID, include)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, role, http.StatusOK)
+}
+
+func (rg roleGroup) UpdateRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roleID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	var input role.IncomingRole
+	if err := web.Decode(r.Body, &input); err != nil {
+		return err
+	}
+
+	role, err := rg.API.UpdateRole(ctx, roleID, input)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, role, http.StatusOK)
+}
+
+func (rg roleGroup) DeleteRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roleID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	if err := rg.API.DeleteRole(ctx, roleID); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (rg roleGroup) RestoreRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roleID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	role, err := rg.API.RestoreRole(ctx, roleID)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, role, http.StatusOK)
+}
+
+func (rg roleGroup) ListRolePermissions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roleID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	ids, err := rg.API.ListRolePermissions(ctx, roleID)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, RolePermissionsResponse{
+		PermissionIDs: ids,
+	}, http.StatusOK)
+}
+
+func (rg roleGroup) ReplaceRolePermissions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roleID, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	var input role.IncomingRolePermissions
+	if err := web.Decode(r.Body, &input); err != nil {
+		return err
+	}
+
+	ids, err := rg.API.ReplaceRolePermissions(ctx, roleID, input)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, RolePermissionsResponse{
+		PermissionIDs: ids,
+	}, http.StatusOK)
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/decisionlog"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/simulate"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+)
+
+// mediaTypeYAML is what snapshots are exported as when asked for YAML, and
+// how an import says its body is YAML.
+const mediaTypeYAML = "application/yaml"
+
+type snapshotGroup struct {
+	*snapshot.API
+}
+
+func snapshotEndpoints(app *web.App, api *snapshot.API) {
+	sg := snapshotGroup{API: api}
+
+	app.Handle("GET", "/export", sg.Export)
+	app.Handle("POST", "/import", sg.Import)
+	app.Handle("POST", "/simulate", sg.Simulate)
+}
+
+// SimulateRequest is a proposed change and the checks to replay against
+// it, given directly or as decision log records.
+type SimulateRequest struct {
+	// Mode is how Changes would be imported, merge when empty.
+	Mode      string               `json:"mode"`
+	Changes   snapshot.Snapshot    `json:"changes"`
+	Checks    []decision.Query     `json:"checks"`
+	Decisions []decisionlog.Record `json:"decisions"`
+}
+
+// Export serves the policy snapshot as indented JSON, or as YAML when the
+// format query parameter is yaml or the Accept header asks for it.
+func (sg snapshotGroup) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = "json"
+		if strings.Contains(r.Header.Get("Accept"), "yaml") {
+			format = "yaml"
+		}
+	case "json", "yaml":
+	default:
+		return bestirerror.WithCodeAndMessagef(errors.New("unknown format"), http.StatusBadRequest, "format %q must be json or yaml", format)
+	}
+
+	snap, err := sg.API.Export(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		data        []byte
+		contentType = web.MediaTypeJSON
+	)
+	if format == "yaml" {
+		contentType = mediaTypeYAML
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(snap); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	} else {
+		if data, err = json.MarshalIndent(snap, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if v := web.GetValues(ctx); v != nil {
+		v.StatusCode = http.StatusOK
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	return err
+}
+
+// Import applies the snapshot in the body, YAML when the Content-Type says
+// so and JSON otherwise, in the merge or replace mode query parameter
+// (merge when absent). It responds with what changed.
+func (sg snapshotGroup) Import(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var snap snapshot.Snapshot
+	if err := decodeSnapshot(r, &snap); err != nil {
+		return err
+	}
+
+	summary, err := sg.API.Import(ctx, snap, r.URL.Query().Get("mode"))
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, summary, http.StatusOK)
+}
+
+// Simulate replays the request's checks against the current policy and the
+// policy as importing the changes would leave it, responding with every
+// decision that flips. Nothing is written.
+func (sg snapshotGroup) Simulate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req SimulateRequest
+	if err := web.Decode(r.Body, &req); err != nil {
+		return err
+	}
+
+	current, err := sg.API.Export(ctx)
+	if err != nil {
+		return err
+	}
+	proposed, err := simulate.Proposed(current, req.Changes, req.Mode)
+	if err != nil {
+		if len(bestirerror.Details(err)) > 0 {
+			return err
+		}
+		return bestirerror.WithCodeAndMessage(err, http.StatusBadRequest, err.Error())
+	}
+
+	queries, skipped := req.Checks, 0
+	for _, rec := range req.Decisions {
+		q, ok := simulate.FromRecord(rec)
+		if !ok {
+			skipped++
+			continue
+		}
+		queries = append(queries, q)
+	}
+	report, err := simulate.Run(ctx, current, proposed, queries)
+	if err != nil {
+		return err
+	}
+	report.Skipped = skipped
+
+	return web.Respond(ctx, w, report, http.StatusOK)
+}
+
+// decodeSnapshot reads the body of r into snap. Unknown fields are errors,
+// a typo in a hand edited snapshot shouldn't be silently dropped.
+func decodeSnapshot(r *http.Request, snap *snapshot.Snapshot) error {
+	var err error
+	if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		dec := yaml.NewDecoder(r.Body)
+		dec.KnownFields(true)
+		err = dec.Decode(snap)
+	} else {
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(snap)
+	}
+	if errors.Is(err, io.EOF) {
+		return bestirerror.WithCodeAndMessage(err, http.StatusBadRequest, "required request body was not provided")
+	}
+	if err != nil {
+		return bestirerror.WithCodeAndMessage(err, http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
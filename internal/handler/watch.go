@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+const (
+	// DefaultWatchInterval is how often /watch checks for new events when
+	// Deps.WatchInterval isn't set.
+	DefaultWatchInterval = time.Second
+	// watchKeepalive is how often an idle stream gets a comment, so proxies
+	// don't time it out.
+	watchKeepalive = 15 * time.Second
+	watchBatchSize = 100
+)
+
+type watchGroup struct {
+	events   outbox.Store
+	interval time.Duration
+	drained  <-chan struct{}
+}
+
+func watchEndpoints(app *web.App, events outbox.Store, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	wg := watchGroup{events: events, interval: interval, drained: app.Drained()}
+
+	app.Handle("GET", "/watch", wg.Watch)
+}
+
+// Watch streams permission and role changes as Server-Sent Events, one
+// outbox.Event per message with its Position as the id. A client resumes after a
+// disconnect from the Last-Event-ID header its EventSource sends, or from
+// the after query parameter, without either the stream starts at the
+// latest change. The first message is a "ready" event carrying the cursor
+// the stream starts after. When the events after the client's cursor have
+// been purged it's a "reset" event instead and the stream starts at the
+// latest change, the client has missed changes and reloads what it caches.
+// Events show up once the relay has sequenced them.
+func (wg watchGroup) Watch(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return bestirerror.WithCodeAndMessage(errors.New("response writer can't flush"), http.StatusInternalServerError, "streaming is not supported")
+	}
+	cursor, reset, err := wg.cursor(ctx, r)
+	if err != nil {
+		return err
+	}
+	first := "ready"
+	if reset {
+		first = "reset"
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// nginx buffers responses unless told not to
+	h.Set("X-Accel-Buffering", "no")
+	if v := web.GetValues(ctx); v != nil {
+		v.StatusCode = http.StatusOK
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "retry: %d\nevent: %s\nid: %d\ndata: {\"position\":%d}\n\n", wg.interval.Milliseconds(), first, cursor, cursor); err != nil {
+		return nil
+	}
+	flusher.Flush()
+
+	poll := time.NewTicker(wg.interval)
+	defer poll.Stop()
+	keepalive := time.NewTicker(watchKeepalive)
+	defer keepalive.Stop()
+	for {
+		events, err := wg.events.ListSince(ctx, cursor, watchBatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// the client reconnects from its last id
+			return err
+		}
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Position, event.Type, data); err != nil {
+				return nil
+			}
+			cursor = event.Position
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+		if len(events) == watchBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wg.drained:
+			return nil
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case <-poll.C:
+		}
+	}
+}
+
+// cursor is the Position the stream starts after. It reports a reset when
+// the client's cursor is older than what the outbox still holds.
+func (wg watchGroup) cursor(ctx context.Context, r *http.Request) (int64, bool, error) {
+	name, raw := "Last-Event-ID", r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		name, raw = "after", r.URL.Query().Get("after")
+	}
+	if raw == "" {
+		position, err := wg.events.LatestPosition(ctx)
+		return position, false, err
+	}
+	position, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || position < 0 {
+		return 0, false, bestirerror.WithCodeAndMessagef(err, http.StatusBadRequest, "%s %q is not a valid event id", name, raw)
+	}
+
+	// the stream is only complete after the event before the oldest one
+	// left, or after the latest once they've all been purged. latest goes
+	// first so an event sequenced in between can't look purged.
+	latest, err := wg.events.LatestPosition(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	oldest, err := wg.events.OldestPosition(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	complete := latest
+	if oldest > 0 {
+		complete = oldest - 1
+	}
+	if position < complete {
+		return latest, true, nil
+	}
+	return position, false, nil
+}
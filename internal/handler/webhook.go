@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/webhook"
+)
+
+type webhookGroup struct {
+	*webhook.API
+}
+
+type ListWebhooksResponse struct {
+	Webhooks []webhook.Subscription `json:"webhooks"`
+}
+
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []webhook.Delivery `json:"deliveries"`
+}
+
+func webhookEndpoints(app *web.App, api *webhook.API) {
+	wg := webhookGroup{API: api}
+
+	app.Handle("GET", "/webhook/{id}", wg.GetWebhook)
+	app.Handle("GET", "/webhook", wg.ListWebhooks)
+	app.Handle("POST", "/webhook", wg.CreateWebhook)
+	app.Handle("PUT", "/webhook/{id}", wg.UpdateWebhook)
+	app.Handle("DELETE", "/webhook/{id}", wg.DeleteWebhook)
+	app.Handle("GET", "/webhook/{id}/deliveries", wg.ListDeliveries)
+}
+
+func (wg webhookGroup) ListWebhooks(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	subs, err := wg.API.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, ListWebhooksResponse{
+		Webhooks: subs,
+	}, http.StatusOK)
+}
+
+// CreateWebhook responds with the subscription's secret, the only time it's
+// shown.
+func (wg webhookGroup) CreateWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var input webhook.IncomingSubscription
+	if err := web.Decode(r.Body, &input); err != nil {
+		return err
+	}
+
+	sub, err := wg.API.CreateSubscription(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, sub, http.StatusCreated)
+}
+
+func (wg webhookGroup) GetWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	sub, err := wg.API.GetSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, sub, http.StatusOK)
+}
+
+func (wg webhookGroup) UpdateWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	var input webhook.IncomingSubscription
+	if err := web.Decode(r.Body, &input); err != nil {
+		return err
+	}
+
+	sub, err := wg.API.UpdateSubscription(ctx, id, input)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, sub, http.StatusOK)
+}
+
+func (wg webhookGroup) DeleteWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	if err := wg.API.DeleteSubscription(ctx, id); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// ListDeliveries serves the subscription's delivery log newest first,
+// filtered by the status query parameter and capped at limit deliveries.
+func (wg webhookGroup) ListDeliveries(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id, err := idParam(r)
+	if err != nil {
+		return err
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > webhook.MaxDeliveryLimit {
+			return bestirerror.WithCodeAndMessagef(err, http.StatusBadRequest, "limit must be between 1 and %d", webhook.MaxDeliveryLimit)
+		}
+	}
+
+	deliveries, err := wg.API.ListDeliveries(ctx, id, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, ListWebhookDeliveriesResponse{
+		Deliveries: deliveries,
+	}, http.StatusOK)
+}
@@ -0,0 +1,149 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+var _ Store = (*CockroachDBStorage)(nil)
+
+func NewCockroachDBStore(pool *pgxpool.Pool) *CockroachDBStorage {
+	return &CockroachDBStorage{pool: pool}
+}
+
+// CockroachDBStorage keeps the outbox in CockroachDB, or any other
+// Postgres compatible database, through pgx.
+type CockroachDBStorage struct {
+	pool *pgxpool.Pool
+}
+
+// WritePgx is Write for the pgx stores.
+func WritePgx(ctx context.Context, tx pgx.Tx, eventType, aggregateType string, aggregateID uuid.UUID, payload interface{}) error {
+	event, err := newEvent(ctx, eventType, aggregateType, aggregateID, payload)
+	if err != nil {
+		return err
+	}
+	var seq int64
+	if err := tx.QueryRow(ctx, `INSERT INTO outbox
+		(id, type, aggregate_type, aggregate_id, payload, request_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING seq`,
+		event.ID, event.Type, event.AggregateType, event.AggregateID, event.Payload, event.RequestID, event.CreatedAt).
+		Scan(&seq); err != nil {
+		return err
+	}
+	recordRevision(ctx, seq)
+	return nil
+}
+
+func (s *CockroachDBStorage) ListPending(ctx context.Context, limit int) ([]Event, error) {
+	return s.list(ctx, `WHERE published_at IS NULL AND position IS NOT NULL ORDER BY position LIMIT $1`, limit)
+}
+
+func (s *CockroachDBStorage) ListSince(ctx context.Context, position int64, limit int) ([]Event, error) {
+	return s.list(ctx, `WHERE position > $1 ORDER BY position LIMIT $2`, position, limit)
+}
+
+// list loads the events matching where, which holds everything after FROM.
+func (s *CockroachDBStorage) list(ctx context.Context, where string, args ...interface{}) ([]Event, error) {
+	rows, err := s.pool.Query(ctx, `SELECT seq, position, id, type, aggregate_type, aggregate_id, payload, request_id, created_at, published_at
+		FROM outbox `+where, args...)
+	if err != nil {
+		return []Event{}, database.ClassifyError(err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(&event.Seq, &event.Position, &event.ID, &event.Type, &event.AggregateType, &event.AggregateID,
+			&event.Payload, &event.RequestID, &event.CreatedAt, &event.PublishedAt); err != nil {
+			return events, database.ClassifyError(err)
+		}
+		events = append(events, event)
+	}
+
+	return events, database.ClassifyError(rows.Err())
+}
+
+func (s *CockroachDBStorage) LatestPosition(ctx context.Context) (int64, error) {
+	// the lease keeps counting after the events are purged
+	var position int64
+	err := s.pool.QueryRow(ctx, `SELECT last_position FROM outbox_lease WHERE name = $1`, relayLease).Scan(&position)
+	return position, database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) OldestPosition(ctx context.Context) (int64, error) {
+	var position int64
+	err := s.pool.QueryRow(ctx, `SELECT COALESCE(MIN(position), 0) FROM outbox`).Scan(&position)
+	return position, database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) Sequence(ctx context.Context, holder uuid.UUID, limit int) (int, error) {
+	var sequenced int
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		sequenced = 0
+		rows, err := tx.Query(ctx, `SELECT seq FROM outbox WHERE position IS NULL ORDER BY seq LIMIT $1`, limit)
+		if err != nil {
+			return err
+		}
+		var seqs []int64
+		for rows.Next() {
+			var seq int64
+			if err := rows.Scan(&seq); err != nil {
+				rows.Close()
+				return err
+			}
+			seqs = append(seqs, seq)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || len(seqs) == 0 {
+			return err
+		}
+
+		// bumping last_position locks the lease row until commit, so
+		// positions become visible in order
+		var last int64
+		err = tx.QueryRow(ctx, `UPDATE outbox_lease SET last_position = last_position + $1
+			WHERE name = $2 AND holder = $3 RETURNING last_position`, len(seqs), relayLease, holder).Scan(&last)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		first := last - int64(len(seqs)) + 1
+		for i, seq := range seqs {
+			if _, err := tx.Exec(ctx, `UPDATE outbox SET position = $1 WHERE seq = $2`, first+int64(i), seq); err != nil {
+				return err
+			}
+		}
+		sequenced = len(seqs)
+		return nil
+	})
+	return sequenced, database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := s.pool.Exec(ctx, `UPDATE outbox SET published_at = $1 WHERE id = $2`, at, id)
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) AcquireLease(ctx context.Context, holder uuid.UUID, now, leaseUntil time.Time) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `UPDATE outbox_lease SET holder = $1, lease_until = $2
+		WHERE name = $3 AND (holder = $1 OR lease_until <= $4)`,
+		holder, leaseUntil, relayLease, now)
+	return tag.RowsAffected() == 1, database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) PurgePublished(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM outbox WHERE published_at < $1`, publishedBefore)
+	return tag.RowsAffected(), database.ClassifyError(err)
+}
@@ -0,0 +1,98 @@
+// Package outbox carries domain events about permission and role changes to
+// downstream services. Stores write an Event in the same transaction as the
+// change it describes, a Relay then publishes the pending events in order,
+// so an event is never lost or sent for a change that rolled back. Only the
+// relay holding the lease publishes, however many instances run one.
+// Delivery is at least once, consumers dedupe on Event.ID.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+const (
+	PermissionCreated  = "PermissionCreated"
+	PermissionUpdated  = "PermissionUpdated"
+	PermissionDeleted  = "PermissionDeleted"
+	PermissionRestored = "PermissionRestored"
+	RoleCreated        = "RoleCreated"
+	RoleUpdated        = "RoleUpdated"
+	RoleDeleted        = "RoleDeleted"
+	RoleRestored       = "RoleRestored"
+	// RolePermissionsReplaced carries the role's new grants.
+	RolePermissionsReplaced = "RolePermissionsReplaced"
+	// RolePermissionRevoked is a grant revoked by a cascading permission
+	// delete, it carries the permission.
+	RolePermissionRevoked = "RolePermissionRevoked"
+)
+
+// EventTypes lists every event type, in the order they're declared above.
+var EventTypes = []string{
+	PermissionCreated, PermissionUpdated, PermissionDeleted, PermissionRestored,
+	RoleCreated, RoleUpdated, RoleDeleted, RoleRestored,
+	RolePermissionsReplaced, RolePermissionRevoked,
+}
+
+// IsEventType reports whether t is one of EventTypes.
+func IsEventType(t string) bool {
+	for _, et := range EventTypes {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Store is the relay's side of the outbox, writes go through Write and
+// WritePgx inside the transactions of the stores making the changes.
+type Store interface {
+	// ListPending returns up to limit unpublished events with a Position,
+	// in its order.
+	ListPending(ctx context.Context, limit int) ([]Event, error)
+	MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error
+	// ListSince returns up to limit events after position, published or
+	// not, in order.
+	ListSince(ctx context.Context, position int64, limit int) ([]Event, error)
+	// LatestPosition is the Position of the newest event, 0 when there are
+	// none.
+	LatestPosition(ctx context.Context) (int64, error)
+	// OldestPosition is the Position of the oldest event that hasn't been
+	// purged, 0 when there are none.
+	OldestPosition(ctx context.Context) (int64, error)
+	// Sequence gives up to limit committed events without a Position the
+	// next ones, in Seq order, if holder still has the lease. It returns
+	// how many it sequenced.
+	Sequence(ctx context.Context, holder uuid.UUID, limit int) (int, error)
+	// AcquireLease makes holder the relay until leaseUntil if it already
+	// is, or the last holder's lease ran out by now. It reports whether
+	// holder has the lease.
+	AcquireLease(ctx context.Context, holder uuid.UUID, now, leaseUntil time.Time) (bool, error)
+	// PurgePublished deletes the events published before publishedBefore
+	// and returns how many there were.
+	PurgePublished(ctx context.Context, publishedBefore time.Time) (int64, error)
+}
+
+// newEvent describes a change to the aggregate, payload is marshaled to
+// JSON.
+func newEvent(ctx context.Context, eventType, aggregateType string, aggregateID uuid.UUID, payload interface{}) (Event, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:            uuid.New(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       database.JSON(b),
+		RequestID:     audit.RequestID(ctx),
+		CreatedAt:     time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Publisher delivers an event downstream, an error leaves it pending to be
+// retried.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Publishers publishes to each of its publishers in turn, stopping at the
+// first that fails. The event is published again to all of them on the
+// retry, so they need to tolerate duplicates.
+type Publishers []Publisher
+
+func (ps Publishers) Publish(ctx context.Context, event Event) error {
+	for _, p := range ps {
+		if err := p.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriterPublisher writes events to w as JSON lines, for stdout or a local
+// file.
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, event Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(b, '\n'))
+	return err
+}
+
+// HTTPPublisher POSTs each event as JSON to URL, any status outside 2xx is
+// a failure.
+type HTTPPublisher struct {
+	URL    string
+	Header http.Header
+	Client *http.Client
+}
+
+// NewHTTPPublisher publishes to url with a client that gives up after
+// timeout.
+func NewHTTPPublisher(url string, timeout time.Duration) *HTTPPublisher {
+	return &HTTPPublisher{
+		URL:    url,
+		Header: http.Header{},
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, v := range p.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID.String())
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) //nolint:errcheck
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("publishing event %s: %s", event.ID, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+func TestHTTPPublisher(t *testing.T) {
+	event := outbox.Event{
+		ID:            uuid.New(),
+		Type:          outbox.RoleCreated,
+		AggregateType: "role",
+		AggregateID:   uuid.New(),
+		Payload:       database.JSON(`{"name":"developer"}`),
+	}
+
+	status := http.StatusAccepted
+	var got outbox.Event
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		b, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	p := outbox.NewHTTPPublisher(srv.URL, 0)
+	p.Header.Set("Authorization", "Bearer token")
+	if err := p.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(event, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	for k, want := range map[string]string{
+		"Content-Type":  "application/json",
+		"X-Event-Id":    event.ID.String(),
+		"X-Event-Type":  outbox.RoleCreated,
+		"Authorization": "Bearer token",
+	} {
+		if header.Get(k) != want {
+			t.Errorf("%s = %q, want %q", k, header.Get(k), want)
+		}
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := p.Publish(context.Background(), event); err == nil {
+		t.Error("a 503 was published")
+	}
+}
@@ -0,0 +1,134 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultBatchSize is how many events Relay reads per query.
+	DefaultBatchSize = 100
+	// DefaultLeaseDuration is how long a relay holds on to the lease
+	// without renewing it.
+	DefaultLeaseDuration = 30 * time.Second
+)
+
+// Relay gives committed events their Position and moves pending ones from
+// Store to Publisher. Every instance of the service runs one, but only the
+// relay holding the lease does either, so events go out once and in
+// order. The others take over when it stops renewing the lease.
+type Relay struct {
+	Store Store
+	// Publisher is nil when events are only sequenced for watchers, they're
+	// marked published as soon as they have a Position so they can be
+	// purged.
+	Publisher Publisher
+	BatchSize int
+	// ID identifies the relay as the lease holder.
+	ID            uuid.UUID
+	LeaseDuration time.Duration
+
+	// renewAt is when the lease is half gone and should be renewed, zero
+	// while the relay doesn't hold it.
+	renewAt time.Time
+}
+
+func NewRelay(store Store, publisher Publisher) *Relay {
+	return &Relay{
+		Store:         store,
+		Publisher:     publisher,
+		BatchSize:     DefaultBatchSize,
+		ID:            uuid.New(),
+		LeaseDuration: DefaultLeaseDuration,
+	}
+}
+
+// PublishPending sequences the events committed since the last call, then
+// publishes pending events in Position order until none are left, stopping
+// at the first failure so later events don't overtake it. It returns how
+// many were published, none when another relay holds the lease.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	if err := r.sequence(ctx); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for {
+		if held, err := r.renew(ctx); err != nil || !held {
+			return published, err
+		}
+		events, err := r.Store.ListPending(ctx, r.BatchSize)
+		if err != nil {
+			return published, err
+		}
+		for _, event := range events {
+			if held, err := r.renew(ctx); err != nil || !held {
+				return published, err
+			}
+			if r.Publisher != nil {
+				if err := r.Publisher.Publish(ctx, event); err != nil {
+					return published, err
+				}
+			}
+			if err := r.Store.MarkPublished(ctx, event.ID, time.Now().UTC()); err != nil {
+				return published, err
+			}
+			published++
+		}
+		if len(events) < r.BatchSize {
+			return published, nil
+		}
+	}
+}
+
+// sequence gives every committed event without a Position one.
+func (r *Relay) sequence(ctx context.Context) error {
+	for {
+		if held, err := r.renew(ctx); err != nil || !held {
+			return err
+		}
+		sequenced, err := r.Store.Sequence(ctx, r.ID, r.BatchSize)
+		if err != nil || sequenced < r.BatchSize {
+			return err
+		}
+	}
+}
+
+// renew takes the lease, or extends it once half of it is gone, and
+// reports whether r holds it.
+func (r *Relay) renew(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	if !r.renewAt.IsZero() && now.Before(r.renewAt) {
+		return true, nil
+	}
+	duration := r.LeaseDuration
+	if duration <= 0 {
+		duration = DefaultLeaseDuration
+	}
+	held, err := r.Store.AcquireLease(ctx, r.ID, now, now.Add(duration))
+	if err != nil || !held {
+		r.renewAt = time.Time{}
+		return false, err
+	}
+	r.renewAt = now.Add(duration / 2)
+	return true, nil
+}
+
+// Run calls PublishPending every interval until ctx is done, errors go to
+// onError and the events are retried on the next tick.
+func (r *Relay) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.PublishPending(ctx); err != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// A revision is the Seq of the newest event written, every change writes
+// events so it orders the changes. Clients get it as an opaque token from
+// writes and hand it back to reads that must see those writes.
+
+type revisionKey struct{}
+
+// WithRevision has Write and WritePgx record the events written with ctx,
+// Revision then reports the newest.
+func WithRevision(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revisionKey{}, new(int64))
+}
+
+// Revision is the Seq of the newest event written with ctx, zero when none
+// was or ctx doesn't come from WithRevision.
+func Revision(ctx context.Context) int64 {
+	if rev, ok := ctx.Value(revisionKey{}).(*int64); ok {
+		return atomic.LoadInt64(rev)
+	}
+	return 0
+}
+
+func recordRevision(ctx context.Context, seq int64) {
+	rev, ok := ctx.Value(revisionKey{}).(*int64)
+	if !ok {
+		return
+	}
+	for {
+		cur := atomic.LoadInt64(rev)
+		if seq <= cur || atomic.CompareAndSwapInt64(rev, cur, seq) {
+			return
+		}
+	}
+}
+
+const tokenPrefix = "rv1."
+
+var ErrInvalidToken = errors.New("invalid revision token")
+
+// Token encodes revision for clients, who should treat it as opaque.
+func Token(revision int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(tokenPrefix + strconv.FormatInt(revision, 10)))
+}
+
+// ParseToken decodes a Token.
+func ParseToken(token string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(b), tokenPrefix) {
+		return 0, ErrInvalidToken
+	}
+	revision, err := strconv.ParseInt(strings.TrimPrefix(string(b), tokenPrefix), 10, 64)
+	if err != nil || revision < 0 {
+		return 0, ErrInvalidToken
+	}
+	return revision, nil
+}
+
+// HasRevision is a database.RevisionFunc for MySQL replicas. It looks for
+// the event itself rather than comparing with the newest Seq, transactions
+// can commit in a different order than they got their Seq.
+func HasRevision(ctx context.Context, db *sql.DB, revision int64) (bool, error) {
+	var one int
+	err := db.QueryRowContext(ctx, `SELECT 1 FROM outbox WHERE seq = ?`, revision).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,176 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+var _ Store = (*MySQLStorage)(nil)
+
+func NewMySQLStore(conn *dbr.Connection) *MySQLStorage {
+	return &MySQLStorage{conn: conn, sess: conn.NewSession(nil)}
+}
+
+type MySQLStorage struct {
+	conn *dbr.Connection
+	sess *dbr.Session
+}
+
+var (
+	// outboxTable leaves out seq, the database assigns it
+	outboxTable   = database.NewTable("outbox", Event{})
+	outboxColumns = append(database.NewQuery(Event{}).Columns, outboxTable.Columns...)
+)
+
+const (
+	leaseTable = "outbox_lease"
+	// relayLease names the lease row the relays compete for
+	relayLease = "relay"
+)
+
+// Write adds an event about the aggregate to tx, the transaction making the
+// change.
+func Write(ctx context.Context, tx dbr.SessionRunner, eventType, aggregateType string, aggregateID uuid.UUID, payload interface{}) error {
+	event, err := newEvent(ctx, eventType, aggregateType, aggregateID, payload)
+	if err != nil {
+		return err
+	}
+	res, err := tx.InsertInto(outboxTable.Name).
+		Columns(outboxTable.Columns...).
+		Record(event).
+		ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	seq, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	recordRevision(ctx, seq)
+	return nil
+}
+
+func (s *MySQLStorage) ListPending(ctx context.Context, limit int) ([]Event, error) {
+	events := []Event{}
+	_, err := s.sess.Select(outboxColumns...).
+		From(outboxTable.Name).
+		Where("published_at IS NULL AND position IS NOT NULL").
+		OrderBy("position").
+		Limit(uint64(limit)).
+		LoadContext(ctx, &events)
+	return events, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := s.sess.Update(outboxTable.Name).
+		Set("published_at", at).
+		Where("id = ?", id).
+		ExecContext(ctx)
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) ListSince(ctx context.Context, position int64, limit int) ([]Event, error) {
+	events := []Event{}
+	_, err := s.sess.Select(outboxColumns...).
+		From(outboxTable.Name).
+		Where("position > ?", position).
+		OrderBy("position").
+		Limit(uint64(limit)).
+		LoadContext(ctx, &events)
+	return events, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) LatestPosition(ctx context.Context) (int64, error) {
+	// the lease keeps counting after the events are purged
+	var position int64
+	err := s.sess.Select("last_position").
+		From(leaseTable).
+		Where("name = ?", relayLease).
+		LoadOneContext(ctx, &position)
+	return position, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) OldestPosition(ctx context.Context) (int64, error) {
+	var position int64
+	err := s.sess.Select("COALESCE(MIN(position), 0)").
+		From(outboxTable.Name).
+		LoadOneContext(ctx, &position)
+	return position, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) Sequence(ctx context.Context, holder uuid.UUID, limit int) (int, error) {
+	var sequenced int
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		seqs := []int64{}
+		if _, err := tx.Select("seq").
+			From(outboxTable.Name).
+			Where("position IS NULL").
+			OrderBy("seq").
+			Limit(uint64(limit)).
+			LoadContext(ctx, &seqs); err != nil || len(seqs) == 0 {
+			return err
+		}
+
+		// bumping last_position locks the lease row until commit, so
+		// positions become visible in order
+		res, err := tx.Update(leaseTable).
+			Set("last_position", dbr.Expr("last_position + ?", len(seqs))).
+			Where("name = ? AND holder = ?", relayLease, holder).
+			ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		var last int64
+		if err := tx.Select("last_position").
+			From(leaseTable).
+			Where("name = ?", relayLease).
+			LoadOneContext(ctx, &last); err != nil {
+			return err
+		}
+
+		first := last - int64(len(seqs)) + 1
+		for i, seq := range seqs {
+			if _, err := tx.Update(outboxTable.Name).
+				Set("position", first+int64(i)).
+				Where("seq = ?", seq).
+				ExecContext(ctx); err != nil {
+				return err
+			}
+		}
+		sequenced = len(seqs)
+		return nil
+	})
+	return sequenced, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) AcquireLease(ctx context.Context, holder uuid.UUID, now, leaseUntil time.Time) (bool, error) {
+	res, err := s.sess.Update(leaseTable).
+		Set("holder", holder).
+		Set("lease_until", leaseUntil).
+		Where("name = ? AND (holder = ? OR lease_until <= ?)", relayLease, holder, now).
+		ExecContext(ctx)
+	if err != nil {
+		return false, database.ClassifyError(err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) PurgePublished(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	res, err := s.sess.DeleteFrom(outboxTable.Name).
+		Where("published_at < ?", publishedBefore).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, database.ClassifyError(err)
+	}
+	n, err := res.RowsAffected()
+	return n, database.ClassifyError(err)
+}
@@ -0,0 +1,32 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+// Event is one change to a permission or role.
+type Event struct {
+	// Seq is assigned by the database as the event is written, in
+	// increasing order. Transactions can commit in a different order, so
+	// it's no cursor.
+	Seq int64 `db:"seq" table:"outbox" json:"seq"`
+	// Position is assigned by the relay holding the lease once the event
+	// is committed, in increasing order with no gaps, so an event never
+	// shows up behind one with a higher Position. It's the cursor watchers
+	// resume from.
+	Position int64     `db:"position" table:"outbox" json:"position"`
+	ID       uuid.UUID `db:"id" json:"id"`
+	Type     string    `db:"type" json:"type"`
+	// AggregateType is "permission" or "role", AggregateID the one changed.
+	AggregateType string    `db:"aggregate_type" json:"aggregate_type"`
+	AggregateID   uuid.UUID `db:"aggregate_id" json:"aggregate_id"`
+	// Payload is the aggregate as JSON after the change.
+	Payload     database.JSON `db:"payload" json:"payload"`
+	RequestID   string        `db:"request_id" json:"request_id"`
+	CreatedAt   time.Time     `db:"created_at" json:"created_at"`
+	PublishedAt *time.Time    `db:"published_at" json:"-"`
+}
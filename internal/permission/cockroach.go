@@ -0,0 +1,210 @@
+package permission
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+var _ Store = (*CockroachDBStorage)(nil)
+
+func NewCockroachDBStore(pool *pgxpool.Pool) *CockroachDBStorage {
+	return &CockroachDBStorage{pool: pool}
+}
+
+// CockroachDBStorage stores permissions in CockroachDB, or any other
+// Postgres compatible database, through pgx.
+type CockroachDBStorage struct {
+	pool *pgxpool.Pool
+}
+
+func (s *CockroachDBStorage) Listpermissions(ctx context.Context, includeDeleted bool) ([]Permission, error) {
+	rows, err := s.pool.Query(ctx, `SELECT id, name, deleted_at FROM permission
+		WHERE deleted_at IS NULL OR $1 ORDER BY name`, includeDeleted)
+	if err != nil {
+		return []Permission{}, database.ClassifyError(err)
+	}
+	defer rows.Close()
+
+	permissions := []Permission{}
+	for rows.Next() {
+		var permission Permission
+		if err := rows.Scan(&permission.ID, &permission.Name, &permission.DeletedAt); err != nil {
+			return permissions, database.ClassifyError(err)
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, database.ClassifyError(rows.Err())
+}
+
+func (s *CockroachDBStorage) Getpermission(ctx context.Context, id uuid.UUID, includeDeleted bool) (Permission, error) {
+	permission, err := s.load(ctx, s.pool, id, includeDeleted, "")
+	return permission, database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) GetpermissionByName(ctx context.Context, name string) (Permission, error) {
+	var permission Permission
+	err := s.pool.QueryRow(ctx, `SELECT id, name, deleted_at FROM permission
+		WHERE name = $1 AND deleted_at IS NULL`, name).
+		Scan(&permission.ID, &permission.Name, &permission.DeletedAt)
+	return permission, database.ClassifyError(err)
+}
+
+// load reads permission id through q, lock is appended to the query so
+// transactions can lock the row with "FOR UPDATE".
+func (s *CockroachDBStorage) load(ctx context.Context, q querier, id uuid.UUID, includeDeleted bool, lock string) (Permission, error) {
+	var permission Permission
+	err := q.QueryRow(ctx, `SELECT id, name, deleted_at FROM permission
+		WHERE id = $1 AND (deleted_at IS NULL OR $2) `+lock, id, includeDeleted).
+		Scan(&permission.ID, &permission.Name, &permission.DeletedAt)
+	return permission, err
+}
+
+func (s *CockroachDBStorage) Createpermission(ctx context.Context, permission Permission) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `INSERT INTO permission (id, name) VALUES ($1, $2)`, permission.ID, permission.Name); err != nil {
+			return err
+		}
+		if err := audit.WritePgx(ctx, tx, audit.ActionCreate, audit.TargetPermission, permission.ID, nil, permission); err != nil {
+			return err
+		}
+		return outbox.WritePgx(ctx, tx, outbox.PermissionCreated, audit.TargetPermission, permission.ID, permission)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) Updatepermission(ctx context.Context, permission Permission) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		before, err := s.load(ctx, tx, permission.ID, false, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE permission SET name = $1 WHERE id = $2`, permission.Name, permission.ID); err != nil {
+			return err
+		}
+		if err := audit.WritePgx(ctx, tx, audit.ActionUpdate, audit.TargetPermission, permission.ID, before, permission); err != nil {
+			return err
+		}
+		return outbox.WritePgx(ctx, tx, outbox.PermissionUpdated, audit.TargetPermission, permission.ID, permission)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) Deletepermission(ctx context.Context, id uuid.UUID, at time.Time, cascade bool) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		before, err := s.load(ctx, tx, id, false, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE permission SET deleted_at = $1 WHERE id = $2`, at, id); err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = &at
+		if err := audit.WritePgx(ctx, tx, audit.ActionDelete, audit.TargetPermission, id, before, after); err != nil {
+			return err
+		}
+		if err := outbox.WritePgx(ctx, tx, outbox.PermissionDeleted, audit.TargetPermission, id, after); err != nil {
+			return err
+		}
+
+		roles, err := s.dependentRoles(ctx, tx, id)
+		if err != nil || len(roles) == 0 {
+			return err
+		}
+		if !cascade {
+			return errHasDependents(roles)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM role_permission WHERE permission_id = $1`, id); err != nil {
+			return err
+		}
+		for _, r := range roles {
+			if err := audit.WritePgx(ctx, tx, audit.ActionRevokePermission, audit.TargetRole, r.ID, before, nil); err != nil {
+				return err
+			}
+			if err := outbox.WritePgx(ctx, tx, outbox.RolePermissionRevoked, audit.TargetRole, r.ID, before); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) ListpermissionDependents(ctx context.Context, id uuid.UUID) (Dependents, error) {
+	if _, err := s.Getpermission(ctx, id, false); err != nil {
+		return Dependents{}, err
+	}
+
+	roles, err := s.dependentRoles(ctx, s.pool, id)
+	return Dependents{Roles: roles}, database.ClassifyError(err)
+}
+
+// querier is what pgxpool.Pool and pgx.Tx have in common for reads.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// dependentRoles loads the live roles permission id is granted to.
+func (s *CockroachDBStorage) dependentRoles(ctx context.Context, q querier, id uuid.UUID) ([]DependentRole, error) {
+	rows, err := q.Query(ctx, `SELECT r.id, r.name FROM role r
+		JOIN role_permission rp ON rp.role_id = r.id
+		WHERE rp.permission_id = $1 AND r.deleted_at IS NULL ORDER BY r.name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []DependentRole{}
+	for rows.Next() {
+		var role DependentRole
+		if err := rows.Scan(&role.ID, &role.Name); err != nil {
+			return roles, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (s *CockroachDBStorage) Restorepermission(ctx context.Context, id uuid.UUID) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		before, err := s.load(ctx, tx, id, true, "FOR UPDATE")
+		// nothing to restore is fine as long as the permission exists
+		if err != nil || before.DeletedAt == nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE permission SET deleted_at = NULL WHERE id = $1`, id); err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = nil
+		if err := audit.WritePgx(ctx, tx, audit.ActionRestore, audit.TargetPermission, id, before, after); err != nil {
+			return err
+		}
+		return outbox.WritePgx(ctx, tx, outbox.PermissionRestored, audit.TargetPermission, id, after)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) Purgepermissions(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM role_permission WHERE permission_id IN
+			(SELECT id FROM permission WHERE deleted_at < $1)`, deletedBefore); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `DELETE FROM permission WHERE deleted_at < $1`, deletedBefore)
+		purged = tag.RowsAffected()
+		return err
+	})
+	return purged, database.ClassifyError(err)
+}
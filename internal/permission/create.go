@@ -6,10 +6,10 @@ import (
 	"github.com/google/uuid"
 )
 
-func (api *API) Createpermission(ctx context.Context, incomingpermission Incomingpermission) (permission, error) {
+func (api *API) Createpermission(ctx context.Context, incomingpermission Incomingpermission) (Permission, error) {
 	id := uuid.New()
 
-	permission := permission{
+	permission := Permission{
 		ID:   id,
 		Name: incomingpermission.Name,
 	}
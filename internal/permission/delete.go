@@ -2,19 +2,34 @@ package permission
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
 )
 
-func (api *API) Deletepermission(ctx context.Context, incomingpermission Incomingpermission) (permission, error) {
-	id := uuid.New()
+// Deletepermission tombstones the permission, Restorepermission undoes it
+// until the retention job purges it. With cascade the permission's grants
+// are revoked too, and a restore doesn't bring them back.
+func (api *API) Deletepermission(ctx context.Context, id uuid.UUID, cascade bool) error {
+	return api.Store.Deletepermission(ctx, id, time.Now().UTC(), cascade)
+}
 
-	permission := permission{
-		ID:   id,
-		Name: incomingpermission.Name,
-	}
+// ListpermissionDependents shows what deleting the permission would affect.
+func (api *API) ListpermissionDependents(ctx context.Context, id uuid.UUID) (Dependents, error) {
+	return api.Store.ListpermissionDependents(ctx, id)
+}
 
-	err := api.Store.Deletepermission(ctx, permission)
+func (api *API) Restorepermission(ctx context.Context, id uuid.UUID) (Permission, error) {
+	if err := api.Store.Restorepermission(ctx, id); err != nil {
+		return Permission{}, err
+	}
+	return api.Store.Getpermission(database.WithStrongConsistency(ctx), id, false)
+}
 
-	return permission, err
+// Purgepermissions removes permissions that were deleted more than
+// retention ago.
+func (api *API) Purgepermissions(ctx context.Context, retention time.Duration) (int64, error) {
+	return api.Store.Purgepermissions(ctx, time.Now().UTC().Add(-retention))
 }
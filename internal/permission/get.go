@@ -1,5 +1,11 @@
 package permission
 
-func (ag permissionGroup) Getpermission() error {
-	return nil
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+func (api *API) Getpermission(ctx context.Context, id uuid.UUID, includeDeleted bool) (Permission, error) {
+	return api.Store.Getpermission(ctx, id, includeDeleted)
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 )
 
-func (api *API) Listpermissiones(ctx context.Context) ([]permission, error) {
-
-	return []permission{}, nil
+func (api *API) Listpermissiones(ctx context.Context, includeDeleted bool) ([]Permission, error) {
+	return api.Store.Listpermissions(ctx, includeDeleted)
 }
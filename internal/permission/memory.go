@@ -0,0 +1,198 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+var _ Store = (*MemoryStorage)(nil)
+
+// NewMemoryStore creates an empty MemoryStorage.
+func NewMemoryStore() *MemoryStorage {
+	return &MemoryStorage{permissions: map[uuid.UUID]Permission{}}
+}
+
+// MemoryStorage keeps permissions in memory. It enforces the same
+// constraints as the MySQL schema and classifies its errors the same way,
+// so it can stand in for MySQLStorage in tests and local development.
+// Grants are kept by the role.MemoryStorage created with it, until there
+// is one permissions have no dependents. It doesn't keep an audit log.
+// It's safe for concurrent use.
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	permissions map[uuid.UUID]Permission
+	grants      Grants
+}
+
+// Grants is what MemoryStorage needs to know about the roles its
+// permissions are granted to. Its methods are called with the
+// MemoryStorage's lock held, so they mustn't call back into it.
+type Grants interface {
+	// GrantedTo lists the live roles permission id is granted to, sorted
+	// by name.
+	GrantedTo(id uuid.UUID) []DependentRole
+	// RevokeAll revokes permission id from every role.
+	RevokeAll(id uuid.UUID)
+}
+
+// UseGrants has s refuse deleting permissions granted in g unless
+// cascading, and revoke them when it is, the way MySQLStorage does with
+// role_permission. role.NewMemoryStore calls it.
+func (s *MemoryStorage) UseGrants(g Grants) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grants = g
+}
+
+func (s *MemoryStorage) Listpermissions(ctx context.Context, includeDeleted bool) ([]Permission, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	permissions := make([]Permission, 0, len(s.permissions))
+	for _, p := range s.permissions {
+		if p.DeletedAt == nil || includeDeleted {
+			permissions = append(permissions, p)
+		}
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Name < permissions[j].Name })
+
+	return permissions, nil
+}
+
+func (s *MemoryStorage) Getpermission(ctx context.Context, id uuid.UUID, includeDeleted bool) (Permission, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	permission, ok := s.permissions[id]
+	if !ok || (permission.DeletedAt != nil && !includeDeleted) {
+		return Permission{}, database.ClassifyError(dbr.ErrNotFound)
+	}
+	return permission, nil
+}
+
+func (s *MemoryStorage) GetpermissionByName(ctx context.Context, name string) (Permission, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.permissions {
+		if p.Name == name && p.DeletedAt == nil {
+			return p, nil
+		}
+	}
+	return Permission{}, database.ClassifyError(dbr.ErrNotFound)
+}
+
+func (s *MemoryStorage) Createpermission(ctx context.Context, permission Permission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.permissions[permission.ID]; ok {
+		return database.ErrDuplicateUnique(fmt.Errorf("Duplicate entry '%s' for key 'PRIMARY'", permission.ID))
+	}
+	if err := s.checkName(permission); err != nil {
+		return err
+	}
+	s.permissions[permission.ID] = permission
+	return nil
+}
+
+func (s *MemoryStorage) Updatepermission(ctx context.Context, permission Permission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.permissions[permission.ID]; !ok || p.DeletedAt != nil {
+		return database.ClassifyError(dbr.ErrNotFound)
+	}
+	if err := s.checkName(permission); err != nil {
+		return err
+	}
+	s.permissions[permission.ID] = permission
+	return nil
+}
+
+func (s *MemoryStorage) Deletepermission(ctx context.Context, id uuid.UUID, at time.Time, cascade bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	permission, ok := s.permissions[id]
+	if !ok || permission.DeletedAt != nil {
+		return database.ClassifyError(dbr.ErrNotFound)
+	}
+	if s.grants != nil {
+		if roles := s.grants.GrantedTo(id); len(roles) > 0 {
+			if !cascade {
+				return errHasDependents(roles)
+			}
+			s.grants.RevokeAll(id)
+		}
+	}
+	permission.DeletedAt = &at
+	s.permissions[id] = permission
+	return nil
+}
+
+func (s *MemoryStorage) Restorepermission(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	permission, ok := s.permissions[id]
+	if !ok {
+		return database.ClassifyError(dbr.ErrNotFound)
+	}
+	permission.DeletedAt = nil
+	if err := s.checkName(permission); err != nil {
+		return err
+	}
+	s.permissions[id] = permission
+	return nil
+}
+
+func (s *MemoryStorage) Purgepermissions(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, p := range s.permissions {
+		if p.DeletedAt != nil && p.DeletedAt.Before(deletedBefore) {
+			delete(s.permissions, id)
+			if s.grants != nil {
+				s.grants.RevokeAll(id)
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *MemoryStorage) ListpermissionDependents(ctx context.Context, id uuid.UUID) (Dependents, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if p, ok := s.permissions[id]; !ok || p.DeletedAt != nil {
+		return Dependents{}, database.ClassifyError(dbr.ErrNotFound)
+	}
+	roles := []DependentRole{}
+	if s.grants != nil {
+		roles = append(roles, s.grants.GrantedTo(id)...)
+	}
+	return Dependents{Roles: roles}, nil
+}
+
+// checkName enforces the unique key on name, which only counts live
+// permissions, s.mu must be held.
+func (s *MemoryStorage) checkName(permission Permission) error {
+	for _, p := range s.permissions {
+		if p.Name == permission.Name && p.ID != permission.ID && p.DeletedAt == nil {
+			return database.ErrDuplicateUnique(fmt.Errorf("Duplicate entry '%s' for key 'name'", permission.Name))
+		}
+	}
+	return nil
+}
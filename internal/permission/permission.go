@@ -1,14 +1,48 @@
 package permission
 
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store is the persistence the permission API is built on. Implementations
+// must report missing permissions with database.ErrNotFound and name or id
+// collisions with database.ErrDuplicateUnique, the same classification
+// database.ClassifyError gives MySQL errors.
+//
+// Deleting only tombstones a permission with its deleted_at, it's left out of
+// reads unless they include deleted permissions and can be restored until
+// it's purged. A permission that's still granted to live roles is only
+// deleted with cascade, which revokes the grants in the same transaction,
+// otherwise the delete fails with database.ErrHasDependents.
+type Store interface {
+	Listpermissions(ctx context.Context, includeDeleted bool) ([]Permission, error)
+	Getpermission(ctx context.Context, id uuid.UUID, includeDeleted bool) (Permission, error)
+	// GetpermissionByName returns the live permission called name.
+	GetpermissionByName(ctx context.Context, name string) (Permission, error)
+	Createpermission(ctx context.Context, permission Permission) error
+	Updatepermission(ctx context.Context, permission Permission) error
+	Deletepermission(ctx context.Context, id uuid.UUID, at time.Time, cascade bool) error
+	Restorepermission(ctx context.Context, id uuid.UUID) error
+	// Purgepermissions removes permissions deleted before deletedBefore for
+	// good, along with their grants, and returns how many it removed.
+	Purgepermissions(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// ListpermissionDependents returns what still references the permission,
+	// roles ordered by name.
+	ListpermissionDependents(ctx context.Context, id uuid.UUID) (Dependents, error)
+}
+
 type API struct {
 	// Logger *bestirlog.Logger
 	// Store CockroachDBStorage // we'll do cockroach l8r
-	Store *MySQLStorage
+	Store Store
 }
 
-// we may want to parameterize storage and logging later
+// we may want to parameterize logging later
 // func NewAPI(conn *pgx.Conn) *API {
-func NewAPI(store *MySQLStorage) *API {
+func NewAPI(store Store) *API {
 	return &API{
 		Store: store,
 	}
@@ -0,0 +1,144 @@
+package permission_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+)
+
+func TestAPI(t *testing.T) {
+	ctx := context.Background()
+	api := permission.NewAPI(permission.NewMemoryStore())
+
+	deploy, err := api.Createpermission(ctx, permission.Incomingpermission{Name: "games.build.deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := api.Createpermission(ctx, permission.Incomingpermission{Name: "games.build.read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("DuplicateName", func(t *testing.T) {
+		_, err := api.Createpermission(ctx, permission.Incomingpermission{Name: "games.build.deploy"})
+		diff(t, http.StatusConflict, bestirerror.StatusCode(err))
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := api.Getpermission(ctx, deploy.ID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, deploy, got)
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		_, err := api.Getpermission(ctx, uuid.New(), false)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		got, err := api.Updatepermission(ctx, read.ID, permission.Incomingpermission{Name: "games.build.view"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		read.Name = "games.build.view"
+		diff(t, read, got)
+	})
+
+	t.Run("UpdateToTakenName", func(t *testing.T) {
+		_, err := api.Updatepermission(ctx, read.ID, permission.Incomingpermission{Name: "games.build.deploy"})
+		diff(t, http.StatusConflict, bestirerror.StatusCode(err))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		got, err := api.Listpermissiones(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, []permission.Permission{deploy, read}, got)
+	})
+
+	t.Run("Dependents", func(t *testing.T) {
+		got, err := api.ListpermissionDependents(ctx, deploy.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, permission.Dependents{Roles: []permission.DependentRole{}}, got)
+
+		_, err = api.ListpermissionDependents(ctx, uuid.New())
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := api.Deletepermission(ctx, deploy.ID, false); err != nil {
+			t.Fatal(err)
+		}
+		err := api.Deletepermission(ctx, deploy.ID, false)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+
+		_, err = api.Getpermission(ctx, deploy.ID, false)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+		list, err := api.Listpermissiones(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, []permission.Permission{read}, list)
+
+		deleted, err := api.Getpermission(ctx, deploy.ID, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted.DeletedAt == nil {
+			t.Error("deleted permission has no deleted_at")
+		}
+		_, err = api.Updatepermission(ctx, deploy.ID, permission.Incomingpermission{Name: "games.build.ship"})
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		got, err := api.Restorepermission(ctx, deploy.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, deploy, got)
+
+		_, err = api.Restorepermission(ctx, uuid.New())
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		if err := api.Deletepermission(ctx, deploy.ID, false); err != nil {
+			t.Fatal(err)
+		}
+
+		purged, err := api.Purgepermissions(ctx, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, int64(0), purged)
+
+		purged, err = api.Purgepermissions(ctx, -time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, int64(1), purged)
+
+		_, err = api.Getpermission(ctx, deploy.ID, true)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+	})
+}
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,20 @@
+package permission
+
+import (
+	"database/sql"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+var _ Store = (*SQLiteStorage)(nil)
+
+func NewSQLiteStore(db *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{MySQLStorage: NewMySQLStore(database.NewSQLiteDBR(db))}
+}
+
+// SQLiteStorage stores permissions in a SQLite database file. The queries
+// MySQLStorage builds are portable, so it's the same store built with the
+// SQLite dialect.
+type SQLiteStorage struct {
+	*MySQLStorage
+}
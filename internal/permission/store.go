@@ -2,29 +2,68 @@ package permission
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
 	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
 	"github.com/gocraft/dbr/v2"
+	"github.com/google/uuid"
 )
 
+var _ Store = (*MySQLStorage)(nil)
+
 func NewMySQLStore(conn *dbr.Connection) *MySQLStorage {
-	return &MySQLStorage{conn: conn, sess: conn.NewSession(nil)}
+	return &MySQLStorage{conn: conn, sess: conn.NewSession(nil), rowLock: database.RowLock(conn)}
+}
+
+// NewReplicatedMySQLStore is NewMySQLStore with reads routed through
+// replicas, writes still go to conn.
+func NewReplicatedMySQLStore(conn *dbr.Connection, replicas *database.Replicas) *MySQLStorage {
+	s := NewMySQLStore(conn)
+	s.replicas = replicas
+	return s
 }
 
 type MySQLStorage struct {
-	conn *dbr.Connection
-	sess *dbr.Session
+	conn     *dbr.Connection
+	sess     *dbr.Session
+	replicas *database.Replicas
+	// rowLock ends the reads of rows a transaction goes on to change, so
+	// the before-image it audits can't go stale.
+	rowLock string
+}
+
+// reader is the session reads made with ctx should use.
+func (s *MySQLStorage) reader(ctx context.Context) *dbr.Session {
+	if s.replicas == nil {
+		return s.sess
+	}
+	return s.replicas.Reader(ctx)
 }
 
 var (
-	permissionTable = database.NewTable("permission", permission{})
+	permissionTable = database.NewTable("permission", Permission{})
+)
+
+// rolePermissionTable holds the grants of permissions to roles, purging a
+// permission revokes them.
+const (
+	rolePermissionTable = "role_permission"
+	roleTable           = "role"
 )
 
-func (s *MySQLStorage) Listpermissions(ctx context.Context) ([]permission, error) {
-	query := s.sess.Select(permissionTable.Columns...).
-		From(permissionTable.Name)
+func (s *MySQLStorage) Listpermissions(ctx context.Context, includeDeleted bool) ([]Permission, error) {
+	query := s.reader(ctx).Select(permissionTable.Columns...).
+		From(permissionTable.Name).
+		OrderBy("name")
+	if !includeDeleted {
+		query = query.Where("deleted_at IS NULL")
+	}
 
-	permissions := []permission{}
+	permissions := []Permission{}
 
 	if _, err := query.LoadContext(ctx, &permissions); err != nil {
 		return permissions, database.ClassifyError(err)
@@ -33,8 +72,8 @@ func (s *MySQLStorage) Listpermissions(ctx context.Context) ([]permission, error
 	return permissions, nil
 }
 
-func (s *MySQLStorage) getpermissionByIdempotencyKey(ctx context.Context, idempotencyKey string) (permission, error) {
-	var permission permission
+func (s *MySQLStorage) getpermissionByIdempotencyKey(ctx context.Context, idempotencyKey string) (Permission, error) {
+	var permission Permission
 	err := s.sess.Select(permissionTable.Columns...).
 		From(permissionTable.Name).
 		Where("idempotency_key = ?", idempotencyKey).
@@ -42,26 +81,201 @@ func (s *MySQLStorage) getpermissionByIdempotencyKey(ctx context.Context, idempo
 	return permission, database.ClassifyError(err)
 }
 
-func (s *MySQLStorage) Createpermission(ctx context.Context, permission permission) error {
-	_, err := s.sess.InsertInto(permissionTable.Name).
-		Columns(permissionTable.Columns...).
-		Record(permission).
-		ExecContext(ctx)
+func (s *MySQLStorage) Getpermission(ctx context.Context, id uuid.UUID, includeDeleted bool) (Permission, error) {
+	permission, err := loadpermission(ctx, s.reader(ctx), id, includeDeleted, "")
+	return permission, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) GetpermissionByName(ctx context.Context, name string) (Permission, error) {
+	var permission Permission
+	err := s.reader(ctx).Select(permissionTable.Columns...).
+		From(permissionTable.Name).
+		Where("name = ? AND deleted_at IS NULL", name).
+		LoadOneContext(ctx, &permission)
+	return permission, database.ClassifyError(err)
+}
+
+// loadpermission reads permission id through sess, which may be a
+// transaction, lock is appended to the query like the Cockroach store's.
+func loadpermission(ctx context.Context, sess dbr.SessionRunner, id uuid.UUID, includeDeleted bool, lock string) (Permission, error) {
+	var permission Permission
+	query := sess.Select(permissionTable.Columns...).
+		From(permissionTable.Name).
+		Where("id = ?", id)
+	if !includeDeleted {
+		query = query.Where("deleted_at IS NULL")
+	}
+	if lock != "" {
+		query = query.Suffix(lock)
+	}
+	err := query.LoadOneContext(ctx, &permission)
+	return permission, err
+}
+
+func (s *MySQLStorage) Createpermission(ctx context.Context, permission Permission) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		if _, err := tx.InsertInto(permissionTable.Name).
+			Columns(permissionTable.Columns...).
+			Record(permission).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		if err := audit.Write(ctx, tx, audit.ActionCreate, audit.TargetPermission, permission.ID, nil, permission); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, outbox.PermissionCreated, audit.TargetPermission, permission.ID, permission)
+	})
 	return database.ClassifyError(err)
 }
 
-func (s *MySQLStorage) Deletepermission(ctx context.Context, permission permission) error {
-	_, err := s.sess.InsertInto(permissionTable.Name).
-		Columns(permissionTable.Columns...).
-		Record(permission).
-		ExecContext(ctx)
+func (s *MySQLStorage) Updatepermission(ctx context.Context, permission Permission) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		// the lookup also tells a missing permission from an unchanged one,
+		// MySQL reports 0 affected rows for both
+		before, err := loadpermission(ctx, tx, permission.ID, false, s.rowLock)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Update(permissionTable.Name).
+			Set("name", permission.Name).
+			Where("id = ?", permission.ID).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		if err := audit.Write(ctx, tx, audit.ActionUpdate, audit.TargetPermission, permission.ID, before, permission); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, outbox.PermissionUpdated, audit.TargetPermission, permission.ID, permission)
+	})
 	return database.ClassifyError(err)
 }
 
-func (s *MySQLStorage) Updatepermission(ctx context.Context, permission permission) error {
-	_, err := s.sess.InsertInto(permissionTable.Name).
-		Columns(permissionTable.Columns...).
-		Record(permission).
-		ExecContext(ctx)
+func (s *MySQLStorage) Deletepermission(ctx context.Context, id uuid.UUID, at time.Time, cascade bool) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		before, err := loadpermission(ctx, tx, id, false, s.rowLock)
+		if err != nil {
+			return err
+		}
+		// the tombstone goes before the grants are looked at so its row lock
+		// holds off concurrent deletes of the same permission
+		res, err := tx.Update(permissionTable.Name).
+			Set("deleted_at", at).
+			Where("id = ? AND deleted_at IS NULL", id).
+			ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return dbr.ErrNotFound
+		}
+		after := before
+		after.DeletedAt = &at
+		if err := audit.Write(ctx, tx, audit.ActionDelete, audit.TargetPermission, id, before, after); err != nil {
+			return err
+		}
+		if err := outbox.Write(ctx, tx, outbox.PermissionDeleted, audit.TargetPermission, id, after); err != nil {
+			return err
+		}
+
+		roles, err := dependentRoles(ctx, tx, id)
+		if err != nil || len(roles) == 0 {
+			return err
+		}
+		if !cascade {
+			return errHasDependents(roles)
+		}
+		if _, err := tx.DeleteFrom(rolePermissionTable).
+			Where("permission_id = ?", id).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		for _, r := range roles {
+			if err := audit.Write(ctx, tx, audit.ActionRevokePermission, audit.TargetRole, r.ID, before, nil); err != nil {
+				return err
+			}
+			if err := outbox.Write(ctx, tx, outbox.RolePermissionRevoked, audit.TargetRole, r.ID, before); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	return database.ClassifyError(err)
 }
+
+func (s *MySQLStorage) ListpermissionDependents(ctx context.Context, id uuid.UUID) (Dependents, error) {
+	if _, err := s.Getpermission(ctx, id, false); err != nil {
+		return Dependents{}, err
+	}
+
+	roles, err := dependentRoles(ctx, s.reader(ctx), id)
+	return Dependents{Roles: roles}, database.ClassifyError(err)
+}
+
+// dependentRoles loads the live roles permission id is granted to.
+func dependentRoles(ctx context.Context, sess dbr.SessionRunner, id uuid.UUID) ([]DependentRole, error) {
+	roles := []DependentRole{}
+	_, err := sess.Select("id", "name").
+		From(roleTable).
+		Where("deleted_at IS NULL AND id IN ?", sess.Select("role_id").
+			From(rolePermissionTable).
+			Where("permission_id = ?", id)).
+		OrderBy("name").
+		LoadContext(ctx, &roles)
+	return roles, err
+}
+
+// errHasDependents refuses a delete that would break access for roles.
+func errHasDependents(roles []DependentRole) error {
+	dependents := make([]string, len(roles))
+	for i, r := range roles {
+		dependents[i] = fmt.Sprintf("role %s (%s)", r.Name, r.ID)
+	}
+	return database.ErrHasDependents(errors.New("permission is still granted to roles, delete with cascade=true to revoke the grants"), dependents)
+}
+
+func (s *MySQLStorage) Restorepermission(ctx context.Context, id uuid.UUID) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		before, err := loadpermission(ctx, tx, id, true, s.rowLock)
+		// nothing to restore is fine as long as the permission exists
+		if err != nil || before.DeletedAt == nil {
+			return err
+		}
+		if _, err := tx.Update(permissionTable.Name).
+			Set("deleted_at", nil).
+			Where("id = ?", id).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = nil
+		if err := audit.Write(ctx, tx, audit.ActionRestore, audit.TargetPermission, id, before, after); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, outbox.PermissionRestored, audit.TargetPermission, id, after)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) Purgepermissions(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		purgeable := tx.Select("id").
+			From(permissionTable.Name).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore)
+		if _, err := tx.DeleteFrom(rolePermissionTable).
+			Where("permission_id IN ?", purgeable).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+
+		res, err := tx.DeleteFrom(permissionTable.Name).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		purged, err = res.RowsAffected()
+		return err
+	})
+	return purged, database.ClassifyError(err)
+}
@@ -1,20 +1,37 @@
 package permission
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 /*
  applicaiton should capture all the information needed to provision and
  manage an permission on the bestir network
 */
-type permission struct {
+type Permission struct {
 	ID   uuid.UUID `db:"id" json:"id"`
 	Name string    `db:"name" json:"name"`
+	// DeletedAt is set once the permission is deleted, until it's restored
+	// or purged.
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
 type Incomingpermission struct {
-	Name string `json:"name" required:"true"`
+	Name string `json:"name" validate:"required"`
 	// IdempotencyKey null.String `json:"-" db:"idempotency_key"`
 }
 
-type permissionGroup struct {
+// Dependents is what still references a permission, the grants a cascading
+// delete would revoke. Roles are all there is, the service has no bindings
+// or tuples that could reference one yet.
+type Dependents struct {
+	Roles []DependentRole `json:"roles"`
+}
+
+// DependentRole is a live role the permission is granted to.
+type DependentRole struct {
+	ID   uuid.UUID `db:"id" json:"id"`
+	Name string    `db:"name" json:"name"`
 }
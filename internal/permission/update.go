@@ -4,17 +4,20 @@ import (
 	"context"
 
 	"github.com/google/uuid"
-)
 
-func (api *API) Updatepermission(ctx context.Context, incomingpermission Incomingpermission) (permission, error) {
-	id := uuid.New()
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
 
-	permission := permission{
-		ID:   id,
-		Name: incomingpermission.Name,
+func (api *API) Updatepermission(ctx context.Context, id uuid.UUID, incomingpermission Incomingpermission) (Permission, error) {
+	// read from the primary, a replica may not have the latest name yet
+	permission, err := api.Store.Getpermission(database.WithStrongConsistency(ctx), id, false)
+	if err != nil {
+		return Permission{}, err
 	}
 
-	err := api.Store.Updatepermission(ctx, permission)
+	permission.Name = incomingpermission.Name
+
+	err = api.Store.Updatepermission(ctx, permission)
 
 	return permission, err
 }
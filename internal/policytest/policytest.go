@@ -0,0 +1,187 @@
+// Package policytest checks assertions about the policy, like
+//
+//	role:builder can games.build.deploy => allow
+//
+// against policy definitions loaded into the in-memory stores, so grant
+// changes can be tested before they're applied. Assertions live in
+// .policytest files next to the definitions, one per line, blank lines
+// and lines starting with # are skipped.
+//
+// Only roles can be subjects and permissions can't be scoped to a
+// resource, so user assertions like
+//
+//	alice can games.build.deploy on project:123 => allow
+//
+// are rejected until the service binds roles to users and scopes
+// permissions.
+package policytest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+)
+
+// Ext is the extension of assertion files.
+const Ext = ".policytest"
+
+// rolePrefix marks a subject as a role. Roles are the only subjects there
+// are until the service binds roles to users.
+const rolePrefix = "role:"
+
+type Assertion struct {
+	File string
+	Line int
+	// Text is the line the assertion was parsed from.
+	Text      string
+	Query     decision.Query
+	WantAllow bool
+}
+
+func (a Assertion) String() string {
+	return fmt.Sprintf("%s:%d: %s", a.File, a.Line, a.Text)
+}
+
+var assertionPattern = regexp.MustCompile(`^(\S+)\s+can\s+(\S+)(?:\s+on\s+(\S+))?\s*=>\s*(allow|deny)$`)
+
+// Parse reads the assertions in r, which was read from file.
+func Parse(file string, r io.Reader) ([]Assertion, error) {
+	var assertions []Assertion
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		m := assertionPattern.FindStringSubmatch(text)
+		if m == nil {
+			return nil, fmt.Errorf("%s:%d: %q isn't <subject> can <permission> => allow|deny", file, line, text)
+		}
+		subject, perm, resource, want := m[1], m[2], m[3], m[4]
+		if !strings.HasPrefix(subject, rolePrefix) || len(subject) == len(rolePrefix) {
+			return nil, fmt.Errorf("%s:%d: subject %q isn't a role, roles aren't bound to anyone yet so write %s<name>", file, line, subject, rolePrefix)
+		}
+		if resource != "" {
+			return nil, fmt.Errorf("%s:%d: permissions aren't scoped to resources yet, drop \"on %s\"", file, line, resource)
+		}
+		assertions = append(assertions, Assertion{
+			File:      file,
+			Line:      line,
+			Text:      text,
+			Query:     decision.Query{Role: strings.TrimPrefix(subject, rolePrefix), Permission: perm},
+			WantAllow: want == "allow",
+		})
+	}
+	return assertions, scanner.Err()
+}
+
+// Load parses the assertion files at path, an Ext file or a directory
+// searched recursively for them.
+func Load(path string) ([]Assertion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() && filepath.Ext(path) != Ext {
+		return nil, fmt.Errorf("%s isn't a %s file", path, Ext)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && filepath.Ext(p) == Ext {
+				files = append(files, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	var assertions []Assertion
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := Parse(file, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, parsed...)
+	}
+	return assertions, nil
+}
+
+// NewEngine validates snap and loads it into in-memory stores, returning
+// an engine that decides against them.
+func NewEngine(ctx context.Context, snap snapshot.Snapshot) (*decision.Engine, error) {
+	if err := snapshot.Validate(snap); err != nil {
+		return nil, err
+	}
+	permissions := permission.NewMemoryStore()
+	roles := role.NewMemoryStore(permissions)
+
+	ids := make(map[string]uuid.UUID, len(snap.Permissions))
+	for _, sp := range snap.Permissions {
+		p := permission.Permission{ID: uuid.New(), Name: sp.Name}
+		if err := permissions.Createpermission(ctx, p); err != nil {
+			return nil, err
+		}
+		ids[p.Name] = p.ID
+	}
+	for _, sr := range snap.Roles {
+		r := role.Role{ID: uuid.New(), Name: sr.Name}
+		if err := roles.CreateRole(ctx, r); err != nil {
+			return nil, err
+		}
+		granted := make([]uuid.UUID, 0, len(sr.Permissions))
+		for _, name := range sr.Permissions {
+			granted = append(granted, ids[name])
+		}
+		if err := roles.ReplaceRolePermissions(ctx, r.ID, granted); err != nil {
+			return nil, err
+		}
+	}
+	return decision.NewEngine(permissions, roles), nil
+}
+
+type Result struct {
+	Assertion Assertion
+	Decision  decision.Decision
+}
+
+// Passed reports whether the decision is the one asserted.
+func (r Result) Passed() bool {
+	return r.Decision.Allowed == r.Assertion.WantAllow
+}
+
+// Run checks every assertion with engine.
+func Run(ctx context.Context, engine *decision.Engine, assertions []Assertion) ([]Result, error) {
+	results := make([]Result, 0, len(assertions))
+	for _, a := range assertions {
+		d, err := engine.Check(ctx, a.Query)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", a, err)
+		}
+		results = append(results, Result{Assertion: a, Decision: d})
+	}
+	return results, nil
+}
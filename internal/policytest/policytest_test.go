@@ -0,0 +1,105 @@
+package policytest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/policytest"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	engine, err := policytest.NewEngine(ctx, snapshot.Snapshot{
+		Version:     snapshot.Version,
+		Permissions: []snapshot.Permission{{Name: "games.build.deploy"}, {Name: "games.build.ship"}},
+		Roles:       []snapshot.Role{{Name: "builder", Permissions: []string{"games.build.deploy"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertions, err := policytest.Parse("builder.policytest", strings.NewReader(`
+# builders deploy but don't ship
+role:builder can games.build.deploy => allow
+role:builder   can games.build.ship =>deny
+role:builder can games.build.ship => allow
+role:reviewer can games.build.deploy => deny
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := policytest.Run(ctx, engine, assertions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var passed []bool
+	for _, r := range results {
+		passed = append(passed, r.Passed())
+	}
+	if diff := cmp.Diff([]bool{true, true, false, true}, passed); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	failed := results[2]
+	if diff := cmp.Diff("builder.policytest:5: role:builder can games.build.ship => allow", failed.Assertion.String()); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	want := decision.Decision{Trace: []string{
+		`role "builder" exists`,
+		`permission "games.build.ship" exists`,
+		`permission "games.build.ship" isn't granted to role "builder"`,
+		"deny",
+	}}
+	if diff := cmp.Diff(want, failed.Decision); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	want = decision.Decision{Trace: []string{`role "reviewer" doesn't exist`, "deny"}}
+	if diff := cmp.Diff(want, results[3].Decision); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{
+		"role:builder may games.build.deploy => allow",
+		"role:builder can games.build.deploy => maybe",
+		"alice can games.build.deploy => allow",
+		"role: can games.build.deploy => allow",
+		"role:builder can games.build.deploy on project:123 => allow",
+	} {
+		if _, err := policytest.Parse("x.policytest", strings.NewReader(line)); err == nil {
+			t.Errorf("%q parsed", line)
+		}
+	}
+}
+
+func TestLoadSkipsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"policy.yaml":        "version: 1\n",
+		"builder.policytest": "role:builder can games.build.deploy => allow\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertions, err := policytest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(1, len(assertions)); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	if _, err := policytest.Load(filepath.Join(dir, "policy.yaml")); err == nil {
+		t.Error("policy.yaml loaded as assertions")
+	}
+}
@@ -0,0 +1,242 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+var _ Store = (*CockroachDBStorage)(nil)
+
+func NewCockroachDBStore(pool *pgxpool.Pool) *CockroachDBStorage {
+	return &CockroachDBStorage{pool: pool}
+}
+
+// CockroachDBStorage stores roles in CockroachDB, or any other
+// Postgres compatible database, through pgx.
+type CockroachDBStorage struct {
+	pool *pgxpool.Pool
+}
+
+func (s *CockroachDBStorage) ListRoles(ctx context.Context, includeDeleted bool) ([]Role, error) {
+	rows, err := s.pool.Query(ctx, `SELECT id, name, deleted_at FROM role
+		WHERE deleted_at IS NULL OR $1 ORDER BY name`, includeDeleted)
+	if err != nil {
+		return []Role{}, database.ClassifyError(err)
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.DeletedAt); err != nil {
+			return roles, database.ClassifyError(err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, database.ClassifyError(rows.Err())
+}
+
+func (s *CockroachDBStorage) GetRole(ctx context.Context, id uuid.UUID, includeDeleted bool) (Role, error) {
+	role, err := s.load(ctx, s.pool, id, includeDeleted, "")
+	return role, database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) GetRoleByName(ctx context.Context, name string) (Role, error) {
+	var role Role
+	err := s.pool.QueryRow(ctx, `SELECT id, name, deleted_at FROM role
+		WHERE name = $1 AND deleted_at IS NULL`, name).
+		Scan(&role.ID, &role.Name, &role.DeletedAt)
+	return role, database.ClassifyError(err)
+}
+
+// querier is what pgxpool.Pool and pgx.Tx have in common for reads.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// load reads role id through q, lock is appended to the query so
+// transactions can lock the row with "FOR UPDATE".
+func (s *CockroachDBStorage) load(ctx context.Context, q querier, id uuid.UUID, includeDeleted bool, lock string) (Role, error) {
+	var role Role
+	err := q.QueryRow(ctx, `SELECT id, name, deleted_at FROM role
+		WHERE id = $1 AND (deleted_at IS NULL OR $2) `+lock, id, includeDeleted).
+		Scan(&role.ID, &role.Name, &role.DeletedAt)
+	return role, err
+}
+
+func (s *CockroachDBStorage) CreateRole(ctx context.Context, role Role) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `INSERT INTO role (id, name) VALUES ($1, $2)`, role.ID, role.Name); err != nil {
+			return err
+		}
+		if err := audit.WritePgx(ctx, tx, audit.ActionCreate, audit.TargetRole, role.ID, nil, role); err != nil {
+			return err
+		}
+		return outbox.WritePgx(ctx, tx, outbox.RoleCreated, audit.TargetRole, role.ID, role)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) UpdateRole(ctx context.Context, role Role) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		before, err := s.load(ctx, tx, role.ID, false, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE role SET name = $1 WHERE id = $2`, role.Name, role.ID); err != nil {
+			return err
+		}
+		if err := audit.WritePgx(ctx, tx, audit.ActionUpdate, audit.TargetRole, role.ID, before, role); err != nil {
+			return err
+		}
+		return outbox.WritePgx(ctx, tx, outbox.RoleUpdated, audit.TargetRole, role.ID, role)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) ListRolePermissions(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error) {
+	if _, err := s.GetRole(ctx, roleID, false); err != nil {
+		return []uuid.UUID{}, err
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT rp.permission_id FROM role_permission rp
+		JOIN permission p ON p.id = rp.permission_id
+		WHERE rp.role_id = $1 AND p.deleted_at IS NULL ORDER BY rp.permission_id`, roleID)
+	if err != nil {
+		return []uuid.UUID{}, database.ClassifyError(err)
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return ids, database.ClassifyError(err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, database.ClassifyError(rows.Err())
+}
+
+func (s *CockroachDBStorage) HasRolePermission(ctx context.Context, roleID, permissionID uuid.UUID) (bool, error) {
+	var granted bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM role_permission
+		WHERE role_id = $1 AND permission_id = $2)`, roleID, permissionID).Scan(&granted)
+	return granted, database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) ReplaceRolePermissions(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := s.load(ctx, tx, roleID, false, "FOR UPDATE"); err != nil {
+			return err
+		}
+		// the foreign key only catches missing permissions, deleted ones are
+		// still in the table
+		ids := make([]string, len(permissionIDs))
+		for i, pid := range permissionIDs {
+			ids[i] = pid.String()
+		}
+		var live int
+		if err := tx.QueryRow(ctx, `SELECT count(*) FROM permission WHERE id = ANY($1) AND deleted_at IS NULL`, ids).Scan(&live); err != nil {
+			return err
+		}
+		if live != len(permissionIDs) {
+			return database.ErrForeignKeyConstraint(errors.New("granted permission does not exist"))
+		}
+
+		before := []uuid.UUID{}
+		rows, err := tx.Query(ctx, `DELETE FROM role_permission WHERE role_id = $1 RETURNING permission_id`, roleID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			before = append(before, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		sort.Slice(before, func(i, j int) bool { return before[i].String() < before[j].String() })
+
+		for _, pid := range permissionIDs {
+			if _, err := tx.Exec(ctx, `INSERT INTO role_permission (role_id, permission_id) VALUES ($1, $2)`, roleID, pid); err != nil {
+				return err
+			}
+		}
+		if err := audit.WritePgx(ctx, tx, audit.ActionReplacePermissions, audit.TargetRole, roleID,
+			IncomingRolePermissions{PermissionIDs: before},
+			IncomingRolePermissions{PermissionIDs: permissionIDs}); err != nil {
+			return err
+		}
+		return outbox.WritePgx(ctx, tx, outbox.RolePermissionsReplaced, audit.TargetRole, roleID,
+			IncomingRolePermissions{PermissionIDs: permissionIDs})
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) DeleteRole(ctx context.Context, id uuid.UUID, at time.Time) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		before, err := s.load(ctx, tx, id, false, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE role SET deleted_at = $1 WHERE id = $2`, at, id); err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = &at
+		if err := audit.WritePgx(ctx, tx, audit.ActionDelete, audit.TargetRole, id, before, after); err != nil {
+			return err
+		}
+		return outbox.WritePgx(ctx, tx, outbox.RoleDeleted, audit.TargetRole, id, after)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) RestoreRole(ctx context.Context, id uuid.UUID) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		before, err := s.load(ctx, tx, id, true, "FOR UPDATE")
+		// nothing to restore is fine as long as the role exists
+		if err != nil || before.DeletedAt == nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE role SET deleted_at = NULL WHERE id = $1`, id); err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = nil
+		if err := audit.WritePgx(ctx, tx, audit.ActionRestore, audit.TargetRole, id, before, after); err != nil {
+			return err
+		}
+		return outbox.WritePgx(ctx, tx, outbox.RoleRestored, audit.TargetRole, id, after)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) PurgeRoles(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		// grants go with the role through ON DELETE CASCADE
+		tag, err := tx.Exec(ctx, `DELETE FROM role WHERE deleted_at < $1`, deletedBefore)
+		purged = tag.RowsAffected()
+		return err
+	})
+	return purged, database.ClassifyError(err)
+}
@@ -0,0 +1,18 @@
+package role
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+func (api *API) CreateRole(ctx context.Context, incomingRole IncomingRole) (Role, error) {
+	role := Role{
+		ID:   uuid.New(),
+		Name: incomingRole.Name,
+	}
+
+	err := api.Store.CreateRole(ctx, role)
+
+	return role, err
+}
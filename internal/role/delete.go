@@ -0,0 +1,28 @@
+package role
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+// DeleteRole tombstones the role, RestoreRole undoes it until the retention
+// job purges it. The role's grants survive the tombstone.
+func (api *API) DeleteRole(ctx context.Context, id uuid.UUID) error {
+	return api.Store.DeleteRole(ctx, id, time.Now().UTC())
+}
+
+func (api *API) RestoreRole(ctx context.Context, id uuid.UUID) (Role, error) {
+	if err := api.Store.RestoreRole(ctx, id); err != nil {
+		return Role{}, err
+	}
+	return api.Store.GetRole(database.WithStrongConsistency(ctx), id, false)
+}
+
+// PurgeRoles removes roles that were deleted more than retention ago.
+func (api *API) PurgeRoles(ctx context.Context, retention time.Duration) (int64, error) {
+	return api.Store.PurgeRoles(ctx, time.Now().UTC().Add(-retention))
+}
@@ -0,0 +1,11 @@
+package role
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+func (api *API) GetRole(ctx context.Context, id uuid.UUID, includeDeleted bool) (Role, error) {
+	return api.Store.GetRole(ctx, id, includeDeleted)
+}
@@ -0,0 +1,9 @@
+package role
+
+import (
+	"context"
+)
+
+func (api *API) ListRoles(ctx context.Context, includeDeleted bool) ([]Role, error) {
+	return api.Store.ListRoles(ctx, includeDeleted)
+}
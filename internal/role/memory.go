@@ -0,0 +1,263 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+)
+
+var _ Store = (*MemoryStorage)(nil)
+
+// NewMemoryStore creates an empty MemoryStorage granting the permissions
+// in permissions, and has permissions refuse deleting the ones it grants.
+func NewMemoryStore(permissions *permission.MemoryStorage) *MemoryStorage {
+	s := &MemoryStorage{
+		roles:       map[uuid.UUID]Role{},
+		grants:      map[uuid.UUID][]uuid.UUID{},
+		permissions: permissions,
+	}
+	permissions.UseGrants(s)
+	return s
+}
+
+// MemoryStorage keeps roles in memory. It enforces the same
+// constraints as the MySQL schema and classifies its errors the same way,
+// so it can stand in for MySQLStorage in tests and local development.
+// It doesn't keep an audit log.
+// It's safe for concurrent use.
+//
+// The permission store locks itself before calling GrantedTo and
+// RevokeAll, so s never calls into it with s.mu held.
+type MemoryStorage struct {
+	mu          sync.RWMutex
+	roles       map[uuid.UUID]Role
+	grants      map[uuid.UUID][]uuid.UUID
+	permissions *permission.MemoryStorage
+}
+
+func (s *MemoryStorage) ListRoles(ctx context.Context, includeDeleted bool) ([]Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := make([]Role, 0, len(s.roles))
+	for _, r := range s.roles {
+		if r.DeletedAt == nil || includeDeleted {
+			roles = append(roles, r)
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+
+	return roles, nil
+}
+
+func (s *MemoryStorage) GetRole(ctx context.Context, id uuid.UUID, includeDeleted bool) (Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	role, ok := s.roles[id]
+	if !ok || (role.DeletedAt != nil && !includeDeleted) {
+		return Role{}, database.ClassifyError(dbr.ErrNotFound)
+	}
+	return role, nil
+}
+
+func (s *MemoryStorage) GetRoleByName(ctx context.Context, name string) (Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.roles {
+		if r.Name == name && r.DeletedAt == nil {
+			return r, nil
+		}
+	}
+	return Role{}, database.ClassifyError(dbr.ErrNotFound)
+}
+
+func (s *MemoryStorage) CreateRole(ctx context.Context, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[role.ID]; ok {
+		return database.ErrDuplicateUnique(fmt.Errorf("Duplicate entry '%s' for key 'PRIMARY'", role.ID))
+	}
+	if err := s.checkName(role); err != nil {
+		return err
+	}
+	s.roles[role.ID] = role
+	return nil
+}
+
+func (s *MemoryStorage) UpdateRole(ctx context.Context, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.roles[role.ID]; !ok || r.DeletedAt != nil {
+		return database.ClassifyError(dbr.ErrNotFound)
+	}
+	if err := s.checkName(role); err != nil {
+		return err
+	}
+	s.roles[role.ID] = role
+	return nil
+}
+
+func (s *MemoryStorage) DeleteRole(ctx context.Context, id uuid.UUID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, ok := s.roles[id]
+	if !ok || role.DeletedAt != nil {
+		return database.ClassifyError(dbr.ErrNotFound)
+	}
+	role.DeletedAt = &at
+	s.roles[id] = role
+	return nil
+}
+
+func (s *MemoryStorage) RestoreRole(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, ok := s.roles[id]
+	if !ok {
+		return database.ClassifyError(dbr.ErrNotFound)
+	}
+	role.DeletedAt = nil
+	if err := s.checkName(role); err != nil {
+		return err
+	}
+	s.roles[id] = role
+	return nil
+}
+
+func (s *MemoryStorage) PurgeRoles(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, r := range s.roles {
+		if r.DeletedAt != nil && r.DeletedAt.Before(deletedBefore) {
+			delete(s.roles, id)
+			delete(s.grants, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *MemoryStorage) ListRolePermissions(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error) {
+	s.mu.RLock()
+	r, ok := s.roles[roleID]
+	granted := append([]uuid.UUID{}, s.grants[roleID]...)
+	s.mu.RUnlock()
+
+	if !ok || r.DeletedAt != nil {
+		return []uuid.UUID{}, database.ClassifyError(dbr.ErrNotFound)
+	}
+	// grants of deleted permissions are kept, they come back on restore
+	ids := []uuid.UUID{}
+	for _, id := range granted {
+		if _, err := s.permissions.Getpermission(ctx, id, false); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryStorage) HasRolePermission(ctx context.Context, roleID, permissionID uuid.UUID) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, id := range s.grants[roleID] {
+		if id == permissionID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStorage) ReplaceRolePermissions(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error {
+	if _, err := s.GetRole(ctx, roleID, false); err != nil {
+		return err
+	}
+	for _, id := range permissionIDs {
+		if _, err := s.permissions.Getpermission(ctx, id, false); err != nil {
+			return database.ErrForeignKeyConstraint(errors.New("granted permission does not exist"))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.roles[roleID]; !ok || r.DeletedAt != nil {
+		return database.ClassifyError(dbr.ErrNotFound)
+	}
+	ids := append([]uuid.UUID{}, permissionIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	for i := 1; i < len(ids); i++ {
+		if ids[i] == ids[i-1] {
+			return database.ErrDuplicateUnique(fmt.Errorf("Duplicate entry '%s-%s' for key 'PRIMARY'", roleID, ids[i]))
+		}
+	}
+	s.grants[roleID] = ids
+	return nil
+}
+
+// GrantedTo lists the live roles permission id is granted to, sorted by
+// name.
+func (s *MemoryStorage) GrantedTo(id uuid.UUID) []permission.DependentRole {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := []permission.DependentRole{}
+	for roleID, granted := range s.grants {
+		r := s.roles[roleID]
+		if r.DeletedAt != nil {
+			continue
+		}
+		for _, pid := range granted {
+			if pid == id {
+				roles = append(roles, permission.DependentRole{ID: r.ID, Name: r.Name})
+				break
+			}
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles
+}
+
+// RevokeAll revokes permission id from every role, deleted or not.
+func (s *MemoryStorage) RevokeAll(id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for roleID, granted := range s.grants {
+		kept := granted[:0]
+		for _, pid := range granted {
+			if pid != id {
+				kept = append(kept, pid)
+			}
+		}
+		s.grants[roleID] = kept
+	}
+}
+
+// checkName enforces the unique key on name, which only counts live
+// roles, s.mu must be held.
+func (s *MemoryStorage) checkName(role Role) error {
+	for _, r := range s.roles {
+		if r.Name == role.Name && r.ID != role.ID && r.DeletedAt == nil {
+			return database.ErrDuplicateUnique(fmt.Errorf("Duplicate entry '%s' for key 'name'", role.Name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package role
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+func (api *API) ListRolePermissions(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	return api.Store.ListRolePermissions(ctx, id)
+}
+
+// ReplaceRolePermissions makes the incoming permissions the role's only
+// grants and returns them as stored, repeated ids are granted once.
+func (api *API) ReplaceRolePermissions(ctx context.Context, id uuid.UUID, incoming IncomingRolePermissions) ([]uuid.UUID, error) {
+	seen := make(map[uuid.UUID]bool, len(incoming.PermissionIDs))
+	ids := make([]uuid.UUID, 0, len(incoming.PermissionIDs))
+	for _, pid := range incoming.PermissionIDs {
+		if !seen[pid] {
+			seen[pid] = true
+			ids = append(ids, pid)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	if err := api.Store.ReplaceRolePermissions(ctx, id, ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
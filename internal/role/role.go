@@ -1,14 +1,54 @@
 package role
 
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store is the persistence the role API is built on. Implementations must
+// report missing roles with database.ErrNotFound and name or id collisions
+// with database.ErrDuplicateUnique, the same classification
+// database.ClassifyError gives MySQL errors. Granting a permission that
+// doesn't exist, or is deleted, is database.ErrForeignKeyConstraint.
+//
+// Deleting only tombstones a role with its deleted_at, it's left out of reads
+// unless they include deleted roles and can be restored, grants and all,
+// until it's purged.
+type Store interface {
+	ListRoles(ctx context.Context, includeDeleted bool) ([]Role, error)
+	GetRole(ctx context.Context, id uuid.UUID, includeDeleted bool) (Role, error)
+	// GetRoleByName returns the live role called name.
+	GetRoleByName(ctx context.Context, name string) (Role, error)
+	CreateRole(ctx context.Context, role Role) error
+	UpdateRole(ctx context.Context, role Role) error
+	DeleteRole(ctx context.Context, id uuid.UUID, at time.Time) error
+	RestoreRole(ctx context.Context, id uuid.UUID) error
+	// PurgeRoles removes roles deleted before deletedBefore for good, along
+	// with their grants, and returns how many it removed.
+	PurgeRoles(ctx context.Context, deletedBefore time.Time) (int64, error)
+
+	// ListRolePermissions returns the ids of the live permissions granted to
+	// the role, ordered by id.
+	ListRolePermissions(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error)
+	// HasRolePermission reports whether the role is granted the permission.
+	// It doesn't look at whether either of them is live.
+	HasRolePermission(ctx context.Context, roleID, permissionID uuid.UUID) (bool, error)
+	// ReplaceRolePermissions atomically replaces the role's grants with
+	// permissionIDs, either all of them are granted or none are.
+	ReplaceRolePermissions(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error
+}
+
 type API struct {
 	// Logger *bestirlog.Logger
 	// Store CockroachDBStorage // we'll do cockroach l8r
-	Store *MySQLStorage
+	Store Store
 }
 
-// we may want to parameterize storage and logging later
+// we may want to parameterize logging later
 // func NewAPI(conn *pgx.Conn) *API {
-func NewAPI(store *MySQLStorage) *API {
+func NewAPI(store Store) *API {
 	return &API{
 		Store: store,
 	}
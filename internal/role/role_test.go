@@ -0,0 +1,155 @@
+package role_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+func TestAPI(t *testing.T) {
+	ctx := context.Background()
+	permissions := permission.NewMemoryStore()
+	api := role.NewAPI(role.NewMemoryStore(permissions))
+
+	developer, err := api.CreateRole(ctx, role.IncomingRole{Name: "developer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer, err := api.CreateRole(ctx, role.IncomingRole{Name: "viewer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("DuplicateName", func(t *testing.T) {
+		_, err := api.CreateRole(ctx, role.IncomingRole{Name: "developer"})
+		diff(t, http.StatusConflict, bestirerror.StatusCode(err))
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := api.GetRole(ctx, developer.ID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, developer, got)
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		_, err := api.GetRole(ctx, uuid.New(), false)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		got, err := api.UpdateRole(ctx, viewer.ID, role.IncomingRole{Name: "reader"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		viewer.Name = "reader"
+		diff(t, viewer, got)
+	})
+
+	t.Run("UpdateToTakenName", func(t *testing.T) {
+		_, err := api.UpdateRole(ctx, viewer.ID, role.IncomingRole{Name: "developer"})
+		diff(t, http.StatusConflict, bestirerror.StatusCode(err))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		got, err := api.ListRoles(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, []role.Role{developer, viewer}, got)
+	})
+
+	t.Run("ReplacePermissions", func(t *testing.T) {
+		a, b := uuid.New(), uuid.New()
+		for _, p := range []permission.Permission{{ID: a, Name: "deploy"}, {ID: b, Name: "read"}} {
+			if err := permissions.Createpermission(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := api.ReplaceRolePermissions(ctx, developer.ID, role.IncomingRolePermissions{PermissionIDs: []uuid.UUID{b, a, b}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		list, err := api.ListRolePermissions(ctx, developer.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, got, list)
+		diff(t, 2, len(list))
+
+		got, err = api.ReplaceRolePermissions(ctx, developer.ID, role.IncomingRolePermissions{PermissionIDs: []uuid.UUID{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, []uuid.UUID{}, got)
+	})
+
+	t.Run("ReplacePermissionsMissingPermission", func(t *testing.T) {
+		_, err := api.ReplaceRolePermissions(ctx, developer.ID, role.IncomingRolePermissions{PermissionIDs: []uuid.UUID{uuid.New()}})
+		diff(t, http.StatusBadRequest, bestirerror.StatusCode(err))
+	})
+
+	t.Run("ReplacePermissionsMissingRole", func(t *testing.T) {
+		_, err := api.ReplaceRolePermissions(ctx, uuid.New(), role.IncomingRolePermissions{PermissionIDs: []uuid.UUID{}})
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := api.DeleteRole(ctx, developer.ID); err != nil {
+			t.Fatal(err)
+		}
+		err := api.DeleteRole(ctx, developer.ID)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+
+		_, err = api.GetRole(ctx, developer.ID, false)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+		_, err = api.ListRolePermissions(ctx, developer.ID)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+		list, err := api.ListRoles(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, 2, len(list))
+		if list[0].DeletedAt == nil {
+			t.Error("deleted role has no deleted_at")
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		got, err := api.RestoreRole(ctx, developer.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, developer, got)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		if err := api.DeleteRole(ctx, developer.ID); err != nil {
+			t.Fatal(err)
+		}
+		purged, err := api.PurgeRoles(ctx, -time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, int64(1), purged)
+
+		_, err = api.RestoreRole(ctx, developer.ID)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+	})
+}
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
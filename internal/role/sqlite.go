@@ -0,0 +1,20 @@
+package role
+
+import (
+	"database/sql"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+var _ Store = (*SQLiteStorage)(nil)
+
+func NewSQLiteStore(db *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{MySQLStorage: NewMySQLStore(database.NewSQLiteDBR(db))}
+}
+
+// SQLiteStorage stores roles in a SQLite database file. The queries
+// MySQLStorage builds are portable, so it's the same store built with the
+// SQLite dialect.
+type SQLiteStorage struct {
+	*MySQLStorage
+}
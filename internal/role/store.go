@@ -1,19 +1,287 @@
 package role
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
 	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
 	"github.com/gocraft/dbr/v2"
+	"github.com/google/uuid"
 )
 
+var _ Store = (*MySQLStorage)(nil)
+
 func NewMySQLStore(conn *dbr.Connection) *MySQLStorage {
-	return &MySQLStorage{conn: conn, sess: conn.NewSession(nil)}
+	return &MySQLStorage{conn: conn, sess: conn.NewSession(nil), rowLock: database.RowLock(conn)}
+}
+
+// NewReplicatedMySQLStore is NewMySQLStore with reads routed through
+// replicas, writes still go to conn.
+func NewReplicatedMySQLStore(conn *dbr.Connection, replicas *database.Replicas) *MySQLStorage {
+	s := NewMySQLStore(conn)
+	s.replicas = replicas
+	return s
 }
 
 type MySQLStorage struct {
-	conn *dbr.Connection
-	sess *dbr.Session
+	conn     *dbr.Connection
+	sess     *dbr.Session
+	replicas *database.Replicas
+	// rowLock ends the reads of rows a transaction goes on to change, so
+	// the before-image it audits can't go stale.
+	rowLock string
+}
+
+// reader is the session reads made with ctx should use.
+func (s *MySQLStorage) reader(ctx context.Context) *dbr.Session {
+	if s.replicas == nil {
+		return s.sess
+	}
+	return s.replicas.Reader(ctx)
 }
 
 var (
-	permissionTable = database.NewTable("role", Role{})
+	roleTable = database.NewTable("role", Role{})
 )
+
+const (
+	rolePermissionTable = "role_permission"
+	permissionTable     = "permission"
+)
+
+func (s *MySQLStorage) ListRoles(ctx context.Context, includeDeleted bool) ([]Role, error) {
+	query := s.reader(ctx).Select(roleTable.Columns...).
+		From(roleTable.Name).
+		OrderBy("name")
+	if !includeDeleted {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	roles := []Role{}
+
+	if _, err := query.LoadContext(ctx, &roles); err != nil {
+		return roles, database.ClassifyError(err)
+	}
+
+	return roles, nil
+}
+
+func (s *MySQLStorage) GetRole(ctx context.Context, id uuid.UUID, includeDeleted bool) (Role, error) {
+	role, err := loadRole(ctx, s.reader(ctx), id, includeDeleted, "")
+	return role, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) GetRoleByName(ctx context.Context, name string) (Role, error) {
+	var role Role
+	err := s.reader(ctx).Select(roleTable.Columns...).
+		From(roleTable.Name).
+		Where("name = ? AND deleted_at IS NULL", name).
+		LoadOneContext(ctx, &role)
+	return role, database.ClassifyError(err)
+}
+
+// loadRole reads role id through sess, which may be a transaction, lock
+// is appended to the query like the Cockroach store's.
+func loadRole(ctx context.Context, sess dbr.SessionRunner, id uuid.UUID, includeDeleted bool, lock string) (Role, error) {
+	var role Role
+	query := sess.Select(roleTable.Columns...).
+		From(roleTable.Name).
+		Where("id = ?", id)
+	if !includeDeleted {
+		query = query.Where("deleted_at IS NULL")
+	}
+	if lock != "" {
+		query = query.Suffix(lock)
+	}
+	err := query.LoadOneContext(ctx, &role)
+	return role, err
+}
+
+func (s *MySQLStorage) CreateRole(ctx context.Context, role Role) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		if _, err := tx.InsertInto(roleTable.Name).
+			Columns(roleTable.Columns...).
+			Record(role).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		if err := audit.Write(ctx, tx, audit.ActionCreate, audit.TargetRole, role.ID, nil, role); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, outbox.RoleCreated, audit.TargetRole, role.ID, role)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) UpdateRole(ctx context.Context, role Role) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		// the lookup also tells a missing role from an unchanged one, MySQL
+		// reports 0 affected rows for both
+		before, err := loadRole(ctx, tx, role.ID, false, s.rowLock)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Update(roleTable.Name).
+			Set("name", role.Name).
+			Where("id = ?", role.ID).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		if err := audit.Write(ctx, tx, audit.ActionUpdate, audit.TargetRole, role.ID, before, role); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, outbox.RoleUpdated, audit.TargetRole, role.ID, role)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) ListRolePermissions(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error) {
+	if _, err := s.GetRole(ctx, roleID, false); err != nil {
+		return []uuid.UUID{}, err
+	}
+
+	live := s.reader(ctx).Select("id").
+		From(permissionTable).
+		Where("deleted_at IS NULL")
+
+	ids := []uuid.UUID{}
+	_, err := s.reader(ctx).Select("permission_id").
+		From(rolePermissionTable).
+		Where("role_id = ? AND permission_id IN ?", roleID, live).
+		OrderBy("permission_id").
+		LoadContext(ctx, &ids)
+	return ids, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) HasRolePermission(ctx context.Context, roleID, permissionID uuid.UUID) (bool, error) {
+	var grants int
+	err := s.reader(ctx).Select("COUNT(*)").
+		From(rolePermissionTable).
+		Where("role_id = ? AND permission_id = ?", roleID, permissionID).
+		LoadOneContext(ctx, &grants)
+	return grants > 0, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) ReplaceRolePermissions(ctx context.Context, roleID uuid.UUID, permissionIDs []uuid.UUID) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		// concurrent replacements of the same role serialize on the role's
+		// row lock, so the grants read next are the ones being replaced
+		if _, err := loadRole(ctx, tx, roleID, false, s.rowLock); err != nil {
+			return err
+		}
+
+		before := []uuid.UUID{}
+		if _, err := tx.Select("permission_id").
+			From(rolePermissionTable).
+			Where("role_id = ?", roleID).
+			OrderBy("permission_id").
+			LoadContext(ctx, &before); err != nil {
+			return err
+		}
+		if _, err := tx.DeleteFrom(rolePermissionTable).
+			Where("role_id = ?", roleID).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+
+		if len(permissionIDs) > 0 {
+			// the foreign key only catches missing permissions, deleted ones
+			// are still in the table
+			var live int
+			if err := tx.Select("COUNT(*)").
+				From(permissionTable).
+				Where("id IN ? AND deleted_at IS NULL", permissionIDs).
+				LoadOneContext(ctx, &live); err != nil {
+				return err
+			}
+			if live != len(permissionIDs) {
+				return database.ErrForeignKeyConstraint(errors.New("granted permission does not exist"))
+			}
+
+			insert := tx.InsertInto(rolePermissionTable).Columns("role_id", "permission_id")
+			for _, pid := range permissionIDs {
+				insert = insert.Values(roleID, pid)
+			}
+			if _, err := insert.ExecContext(ctx); err != nil {
+				return err
+			}
+		}
+
+		if err := audit.Write(ctx, tx, audit.ActionReplacePermissions, audit.TargetRole, roleID,
+			IncomingRolePermissions{PermissionIDs: before},
+			IncomingRolePermissions{PermissionIDs: permissionIDs}); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, outbox.RolePermissionsReplaced, audit.TargetRole, roleID,
+			IncomingRolePermissions{PermissionIDs: permissionIDs})
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) DeleteRole(ctx context.Context, id uuid.UUID, at time.Time) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		before, err := loadRole(ctx, tx, id, false, s.rowLock)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Update(roleTable.Name).
+			Set("deleted_at", at).
+			Where("id = ? AND deleted_at IS NULL", id).
+			ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return dbr.ErrNotFound
+		}
+		after := before
+		after.DeletedAt = &at
+		if err := audit.Write(ctx, tx, audit.ActionDelete, audit.TargetRole, id, before, after); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, outbox.RoleDeleted, audit.TargetRole, id, after)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) RestoreRole(ctx context.Context, id uuid.UUID) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		before, err := loadRole(ctx, tx, id, true, s.rowLock)
+		// nothing to restore is fine as long as the role exists
+		if err != nil || before.DeletedAt == nil {
+			return err
+		}
+		if _, err := tx.Update(roleTable.Name).
+			Set("deleted_at", nil).
+			Where("id = ?", id).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		after := before
+		after.DeletedAt = nil
+		if err := audit.Write(ctx, tx, audit.ActionRestore, audit.TargetRole, id, before, after); err != nil {
+			return err
+		}
+		return outbox.Write(ctx, tx, outbox.RoleRestored, audit.TargetRole, id, after)
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) PurgeRoles(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		// grants go with the role through ON DELETE CASCADE
+		res, err := tx.DeleteFrom(roleTable.Name).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		purged, err = res.RowsAffected()
+		return err
+	})
+	return purged, database.ClassifyError(err)
+}
@@ -1,6 +1,10 @@
 package role
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 /*
  applicaiton should capture all the information needed to provision and
@@ -9,4 +13,17 @@ import "github.com/google/uuid"
 type Role struct {
 	ID   uuid.UUID `db:"id" json:"id"`
 	Name string    `db:"name" json:"name"`
+	// DeletedAt is set once the role is deleted, until it's restored or
+	// purged.
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+}
+
+type IncomingRole struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// IncomingRolePermissions replaces a role's grants, an empty list revokes
+// them all.
+type IncomingRolePermissions struct {
+	PermissionIDs []uuid.UUID `json:"permission_ids" validate:"required"`
 }
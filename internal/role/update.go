@@ -1,20 +1,23 @@
-package permission
+package role
 
 import (
 	"context"
 
 	"github.com/google/uuid"
-)
 
-func (api *API) Updatepermission(ctx context.Context, incomingpermission Incomingpermission) (permission, error) {
-	id := uuid.New()
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
 
-	permission := permission{
-		ID:   id,
-		Name: incomingpermission.Name,
+func (api *API) UpdateRole(ctx context.Context, id uuid.UUID, incomingRole IncomingRole) (Role, error) {
+	// read from the primary, a replica may not have the latest name yet
+	role, err := api.Store.GetRole(database.WithStrongConsistency(ctx), id, false)
+	if err != nil {
+		return Role{}, err
 	}
 
-	err := api.Store.Updatepermission(ctx, permission)
+	role.Name = incomingRole.Name
+
+	err = api.Store.UpdateRole(ctx, role)
 
-	return permission, err
+	return role, err
 }
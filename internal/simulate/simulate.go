@@ -0,0 +1,144 @@
+// Package simulate replays checks against the current policy and a
+// proposed change to it, reporting every decision the change would flip.
+// Nothing is written, both policies are loaded into in-memory stores.
+package simulate
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/decisionlog"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/policytest"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+)
+
+// Flip is a check whose decision the change reverses.
+type Flip struct {
+	Query decision.Query `json:"query"`
+	// Count is how many times the check was replayed.
+	Count  int               `json:"count"`
+	Before decision.Decision `json:"before"`
+	After  decision.Decision `json:"after"`
+}
+
+type Report struct {
+	// Checked is how many checks were replayed, Skipped how many decision
+	// log records couldn't be.
+	Checked int    `json:"checked"`
+	Skipped int    `json:"skipped"`
+	Flips   []Flip `json:"flips"`
+}
+
+// Proposed is current with changes imported in mode, the way
+// snapshot.API.Import would leave it.
+func Proposed(current, changes snapshot.Snapshot, mode string) (snapshot.Snapshot, error) {
+	if err := snapshot.Validate(changes); err != nil {
+		return snapshot.Snapshot{}, err
+	}
+	switch mode {
+	case snapshot.ModeReplace:
+		return changes, nil
+	case "", snapshot.ModeMerge:
+	default:
+		return snapshot.Snapshot{}, fmt.Errorf("mode must be %s or %s", snapshot.ModeMerge, snapshot.ModeReplace)
+	}
+
+	proposed := snapshot.Snapshot{Version: snapshot.Version}
+	permissions := map[string]bool{}
+	for _, p := range append(append([]snapshot.Permission{}, current.Permissions...), changes.Permissions...) {
+		if !permissions[p.Name] {
+			permissions[p.Name] = true
+			proposed.Permissions = append(proposed.Permissions, p)
+		}
+	}
+	replaced := map[string]bool{}
+	for _, r := range changes.Roles {
+		replaced[r.Name] = true
+	}
+	for _, r := range current.Roles {
+		if !replaced[r.Name] {
+			proposed.Roles = append(proposed.Roles, r)
+		}
+	}
+	proposed.Roles = append(proposed.Roles, changes.Roles...)
+	return proposed, nil
+}
+
+// ReadDecisionLog reads the checks recorded in a decisionlog file. Records
+// FromRecord can't replay are counted in skipped.
+func ReadDecisionLog(r io.Reader) (queries []decision.Query, skipped int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var rec decisionlog.Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return queries, skipped, fmt.Errorf("line %d: %w", line, err)
+		}
+		q, ok := FromRecord(rec)
+		if !ok {
+			skipped++
+			continue
+		}
+		queries = append(queries, q)
+	}
+	return queries, skipped, scanner.Err()
+}
+
+// FromRecord is the check rec recorded, false when it can't be replayed:
+// its subject isn't a role:<name> or it's scoped to a resource, which the
+// policy doesn't model yet.
+func FromRecord(rec decisionlog.Record) (decision.Query, bool) {
+	if !strings.HasPrefix(rec.Subject, decision.SubjectPrefix) || rec.Resource != "" {
+		return decision.Query{}, false
+	}
+	return decision.Query{Role: strings.TrimPrefix(rec.Subject, decision.SubjectPrefix), Permission: rec.Action}, true
+}
+
+// Run replays queries against current and proposed, in-memory.
+func Run(ctx context.Context, current, proposed snapshot.Snapshot, queries []decision.Query) (Report, error) {
+	report := Report{Checked: len(queries), Flips: []Flip{}}
+	before, err := policytest.NewEngine(ctx, current)
+	if err != nil {
+		return report, fmt.Errorf("loading current policy: %w", err)
+	}
+	after, err := policytest.NewEngine(ctx, proposed)
+	if err != nil {
+		return report, fmt.Errorf("loading proposed policy: %w", err)
+	}
+
+	// recorded traffic repeats itself, every distinct check is decided once
+	flips := map[decision.Query]int{}
+	decided := map[decision.Query]bool{}
+	for _, q := range queries {
+		if i, ok := flips[q]; ok {
+			report.Flips[i].Count++
+			continue
+		}
+		if decided[q] {
+			continue
+		}
+		decided[q] = true
+
+		b, err := before.Check(ctx, q)
+		if err != nil {
+			return report, err
+		}
+		a, err := after.Check(ctx, q)
+		if err != nil {
+			return report, err
+		}
+		if a.Allowed != b.Allowed {
+			flips[q] = len(report.Flips)
+			report.Flips = append(report.Flips, Flip{Query: q, Count: 1, Before: b, After: a})
+		}
+	}
+	return report, nil
+}
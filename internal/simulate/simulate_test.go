@@ -0,0 +1,106 @@
+package simulate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/simulate"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+)
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+var current = snapshot.Snapshot{
+	Version:     snapshot.Version,
+	Permissions: []snapshot.Permission{{Name: "games.build.deploy"}, {Name: "games.build.ship"}},
+	Roles: []snapshot.Role{
+		{Name: "builder", Permissions: []string{"games.build.deploy", "games.build.ship"}},
+		{Name: "shipper", Permissions: []string{"games.build.ship"}},
+	},
+}
+
+func TestProposed(t *testing.T) {
+	changes := snapshot.Snapshot{
+		Version:     snapshot.Version,
+		Permissions: []snapshot.Permission{{Name: "games.build.deploy"}},
+		Roles:       []snapshot.Role{{Name: "builder", Permissions: []string{"games.build.deploy"}}},
+	}
+
+	proposed, err := simulate.Proposed(current, changes, snapshot.ModeMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, snapshot.Snapshot{
+		Version:     snapshot.Version,
+		Permissions: current.Permissions,
+		Roles: []snapshot.Role{
+			{Name: "shipper", Permissions: []string{"games.build.ship"}},
+			{Name: "builder", Permissions: []string{"games.build.deploy"}},
+		},
+	}, proposed)
+
+	proposed, err = simulate.Proposed(current, changes, snapshot.ModeReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, changes, proposed)
+
+	if _, err := simulate.Proposed(current, snapshot.Snapshot{Version: 2}, snapshot.ModeMerge); err == nil {
+		t.Error("invalid changes accepted")
+	}
+}
+
+func TestRun(t *testing.T) {
+	queries, skipped, err := simulate.ReadDecisionLog(strings.NewReader(`
+{"subject":"role:builder","action":"games.build.ship","allowed":true,"latency_ms":0.1}
+{"subject":"role:builder","action":"games.build.deploy","allowed":true,"latency_ms":0.1}
+{"subject":"role:builder","action":"games.build.ship","allowed":true,"latency_ms":0.1}
+{"subject":"alice","action":"games.build.ship","resource":"project:123","allowed":true,"latency_ms":0.1}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, 1, skipped)
+	queries = append(queries, decision.Query{Role: "shipper", Permission: "games.build.deploy"})
+
+	proposed, err := simulate.Proposed(current, snapshot.Snapshot{
+		Version:     snapshot.Version,
+		Permissions: []snapshot.Permission{{Name: "games.build.deploy"}},
+		Roles:       []snapshot.Role{{Name: "builder", Permissions: []string{"games.build.deploy"}}},
+	}, snapshot.ModeMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := simulate.Run(context.Background(), current, proposed, queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, simulate.Report{
+		Checked: 4,
+		Flips: []simulate.Flip{{
+			Query: decision.Query{Role: "builder", Permission: "games.build.ship"},
+			Count: 2,
+			Before: decision.Decision{Allowed: true, Trace: []string{
+				`role "builder" exists`,
+				`permission "games.build.ship" exists`,
+				`permission "games.build.ship" is granted by role "builder"`,
+				"allow",
+			}},
+			After: decision.Decision{Trace: []string{
+				`role "builder" exists`,
+				`permission "games.build.ship" exists`,
+				`permission "games.build.ship" isn't granted to role "builder"`,
+				"deny",
+			}},
+		}},
+	}, report)
+}
@@ -0,0 +1,171 @@
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+var _ Store = (*CockroachDBStorage)(nil)
+
+func NewCockroachDBStore(pool *pgxpool.Pool) *CockroachDBStorage {
+	return &CockroachDBStorage{pool: pool}
+}
+
+// CockroachDBStorage imports and exports snapshots of CockroachDB, or any
+// other Postgres compatible database, through pgx.
+type CockroachDBStorage struct {
+	pool *pgxpool.Pool
+}
+
+func (s *CockroachDBStorage) Export(ctx context.Context) (Snapshot, error) {
+	var st state
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		var err error
+		st, err = loadStatePgx(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return Snapshot{}, database.ClassifyError(err)
+	}
+	return st.export(), nil
+}
+
+// loadStatePgx reads every permission, role and grant through tx.
+func loadStatePgx(ctx context.Context, tx pgx.Tx) (state, error) {
+	st := state{grants: map[uuid.UUID][]uuid.UUID{}}
+
+	rows, err := tx.Query(ctx, `SELECT id, name, deleted_at FROM permission ORDER BY name`)
+	if err != nil {
+		return st, err
+	}
+	for rows.Next() {
+		var p permission.Permission
+		if err := rows.Scan(&p.ID, &p.Name, &p.DeletedAt); err != nil {
+			rows.Close()
+			return st, err
+		}
+		st.permissions = append(st.permissions, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+
+	rows, err = tx.Query(ctx, `SELECT id, name, deleted_at FROM role ORDER BY name`)
+	if err != nil {
+		return st, err
+	}
+	for rows.Next() {
+		var r role.Role
+		if err := rows.Scan(&r.ID, &r.Name, &r.DeletedAt); err != nil {
+			rows.Close()
+			return st, err
+		}
+		st.roles = append(st.roles, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+
+	rows, err = tx.Query(ctx, `SELECT role_id, permission_id FROM role_permission`)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var g grant
+		if err := rows.Scan(&g.RoleID, &g.PermissionID); err != nil {
+			return st, err
+		}
+		st.grants[g.RoleID] = append(st.grants[g.RoleID], g.PermissionID)
+	}
+	return st, rows.Err()
+}
+
+func (s *CockroachDBStorage) Plan(ctx context.Context, snap Snapshot, mode string) ([]Change, Summary, error) {
+	var st state
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		var err error
+		st, err = loadStatePgx(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, Summary{}, database.ClassifyError(err)
+	}
+	changes, summary := st.plan(snap, mode)
+	return describe(changes), summary, nil
+}
+
+func (s *CockroachDBStorage) Import(ctx context.Context, snap Snapshot, mode string, at time.Time, approved []Change) (Summary, error) {
+	var summary Summary
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		st, err := loadStatePgx(ctx, tx)
+		if err != nil {
+			return err
+		}
+		var changes []change
+		changes, summary = st.plan(snap, mode)
+		if err := checkApproved(changes, approved); err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if err := applyChangePgx(ctx, tx, c, at); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return summary, database.ClassifyError(err)
+}
+
+// applyChangePgx is applyChange through pgx.
+func applyChangePgx(ctx context.Context, tx pgx.Tx, c change, at time.Time) error {
+	var err error
+	switch c.Kind {
+	case createPermission:
+		_, err = tx.Exec(ctx, `INSERT INTO permission (id, name) VALUES ($1, $2)`, c.Permission.ID, c.Permission.Name)
+	case restorePermission:
+		_, err = tx.Exec(ctx, `UPDATE permission SET deleted_at = NULL WHERE id = $1`, c.Permission.ID)
+	case deletePermission:
+		if _, err = tx.Exec(ctx, `UPDATE permission SET deleted_at = $1 WHERE id = $2`, at, c.Permission.ID); err == nil {
+			// the roles kept had their grants replaced already, these are
+			// the grants of deleted roles
+			_, err = tx.Exec(ctx, `DELETE FROM role_permission WHERE permission_id = $1`, c.Permission.ID)
+		}
+	case createRole:
+		_, err = tx.Exec(ctx, `INSERT INTO role (id, name) VALUES ($1, $2)`, c.Role.ID, c.Role.Name)
+	case restoreRole:
+		_, err = tx.Exec(ctx, `UPDATE role SET deleted_at = NULL WHERE id = $1`, c.Role.ID)
+	case deleteRole:
+		_, err = tx.Exec(ctx, `UPDATE role SET deleted_at = $1 WHERE id = $2`, at, c.Role.ID)
+	case replaceGrants:
+		if _, err = tx.Exec(ctx, `DELETE FROM role_permission WHERE role_id = $1`, c.Role.ID); err != nil {
+			break
+		}
+		for _, pid := range c.After {
+			if _, err = tx.Exec(ctx, `INSERT INTO role_permission (role_id, permission_id) VALUES ($1, $2)`, c.Role.ID, pid); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	r := c.record(at)
+	if err := audit.WritePgx(ctx, tx, r.action, r.targetType, r.targetID, r.before, r.after); err != nil {
+		return err
+	}
+	return outbox.WritePgx(ctx, tx, r.eventType, r.targetType, r.targetID, r.after)
+}
@@ -0,0 +1,77 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads the policy definitions at path, a YAML file or a directory
+// searched recursively for .yaml and .yml files. Every file can hold
+// several documents, each with any of the permissions and roles, and they
+// are all merged into one snapshot. A document's version can be left out,
+// it defaults to Version.
+func Load(path string) (Snapshot, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := strings.ToLower(filepath.Ext(p)); !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return Snapshot{}, err
+		}
+		sort.Strings(files)
+	}
+
+	snap := Snapshot{Version: Version, Permissions: []Permission{}, Roles: []Role{}}
+	for _, file := range files {
+		if err := loadFile(file, &snap); err != nil {
+			return Snapshot{}, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return snap, nil
+}
+
+// loadFile appends the documents in file to snap.
+func loadFile(file string, snap *Snapshot) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	for {
+		var doc Snapshot
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if doc.Version != 0 && doc.Version != Version {
+			return fmt.Errorf("version %d isn't supported, only %d is", doc.Version, Version)
+		}
+		snap.Permissions = append(snap.Permissions, doc.Permissions...)
+		snap.Roles = append(snap.Roles, doc.Roles...)
+	}
+}
@@ -0,0 +1,73 @@
+package snapshot_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"permissions.yaml": `permissions:
+  - name: games.build.deploy
+---
+version: 1
+permissions:
+  - name: games.build.ship
+`,
+		"roles/builder.yml": `roles:
+  - name: builder
+    permissions: [games.build.deploy, games.build.ship]
+`,
+		"README.md": "not policy",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap, err := snapshot.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := snapshot.Snapshot{
+		Version:     snapshot.Version,
+		Permissions: []snapshot.Permission{{Name: "games.build.deploy"}, {Name: "games.build.ship"}},
+		Roles:       []snapshot.Role{{Name: "builder", Permissions: []string{"games.build.deploy", "games.build.ship"}}},
+	}
+	if diff := cmp.Diff(want, snap); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	// a single file works too
+	snap, err = snapshot.Load(filepath.Join(dir, "roles", "builder.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want.Roles, snap.Roles); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	for name, content := range map[string]string{
+		"version.yaml": "version: 2\n",
+		"typo.yaml":    "permisions: []\n",
+	} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := snapshot.Load(path); err == nil {
+			t.Errorf("%s loaded", name)
+		}
+	}
+}
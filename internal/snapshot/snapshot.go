@@ -0,0 +1,450 @@
+// Package snapshot exports the permissions, roles and grants as a single
+// document and imports one back, to copy policy between environments.
+// An import runs in one transaction, audited and evented like any other
+// change to the stores.
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+// Import modes.
+const (
+	// ModeMerge creates, restores and regrants what's in the snapshot and
+	// leaves everything else alone.
+	ModeMerge = "merge"
+	// ModeReplace is ModeMerge that also deletes the permissions and roles
+	// missing from the snapshot, revoking their grants.
+	ModeReplace = "replace"
+)
+
+type Store interface {
+	Export(ctx context.Context) (Snapshot, error)
+	// Plan lists the changes Import would make, without making them.
+	Plan(ctx context.Context, snap Snapshot, mode string) ([]Change, Summary, error)
+	// Import applies the changes plan makes to the current state, in one
+	// transaction. When approved isn't nil it's the plan the changes were
+	// approved from, and the import fails with ErrStalePlan rather than
+	// apply anything else.
+	Import(ctx context.Context, snap Snapshot, mode string, at time.Time, approved []Change) (Summary, error)
+}
+
+// ErrStalePlan is an approved plan that no longer matches the policy, it
+// changed between planning and applying.
+var ErrStalePlan = errors.New("the policy changed since it was planned")
+
+type API struct {
+	Store Store
+}
+
+func NewAPI(store Store) *API {
+	return &API{
+		Store: store,
+	}
+}
+
+func (api *API) Export(ctx context.Context) (Snapshot, error) {
+	return api.Store.Export(ctx)
+}
+
+// Plan validates snap and lists the changes importing it in mode would
+// make.
+func (api *API) Plan(ctx context.Context, snap Snapshot, mode string) ([]Change, Summary, error) {
+	mode, err := checkImport(snap, mode)
+	if err != nil {
+		return nil, Summary{}, err
+	}
+	return api.Store.Plan(ctx, snap, mode)
+}
+
+// Import validates snap and applies it in mode.
+func (api *API) Import(ctx context.Context, snap Snapshot, mode string) (Summary, error) {
+	mode, err := checkImport(snap, mode)
+	if err != nil {
+		return Summary{}, err
+	}
+	return api.Store.Import(ctx, snap, mode, time.Now().UTC(), nil)
+}
+
+// Apply imports snap in mode only if doing so makes exactly the approved
+// changes, which came from Plan. A stale plan is a 409 wrapping
+// ErrStalePlan.
+func (api *API) Apply(ctx context.Context, snap Snapshot, mode string, approved []Change) (Summary, error) {
+	mode, err := checkImport(snap, mode)
+	if err != nil {
+		return Summary{}, err
+	}
+	if approved == nil {
+		approved = []Change{}
+	}
+	summary, err := api.Store.Import(ctx, snap, mode, time.Now().UTC(), approved)
+	if errors.Is(err, ErrStalePlan) {
+		return summary, bestirerror.WithCodeAndMessage(err, http.StatusConflict, err.Error())
+	}
+	return summary, err
+}
+
+// checkImport validates snap and mode, returning mode with the default
+// filled in.
+func checkImport(snap Snapshot, mode string) (string, error) {
+	if mode == "" {
+		mode = ModeMerge
+	}
+	if mode != ModeMerge && mode != ModeReplace {
+		return mode, web.ErrValidation(errors.New("unknown import mode"), []string{
+			fmt.Sprintf("mode must be %s or %s", ModeMerge, ModeReplace),
+		})
+	}
+	return mode, Validate(snap)
+}
+
+// Validate checks snap is a Version snapshot with unique, non-empty names
+// whose roles only grant permissions it contains. Every problem is listed
+// in the error's details.
+func Validate(snap Snapshot) error {
+	var problems []string
+	if snap.Version != Version {
+		problems = append(problems, fmt.Sprintf("version must be %d", Version))
+	}
+	permissions := map[string]bool{}
+	for i, p := range snap.Permissions {
+		switch {
+		case p.Name == "":
+			problems = append(problems, fmt.Sprintf("permissions[%d] has no name", i))
+		case permissions[p.Name]:
+			problems = append(problems, fmt.Sprintf("permission %s is listed twice", p.Name))
+		}
+		permissions[p.Name] = true
+	}
+	roles := map[string]bool{}
+	for i, r := range snap.Roles {
+		switch {
+		case r.Name == "":
+			problems = append(problems, fmt.Sprintf("roles[%d] has no name", i))
+		case roles[r.Name]:
+			problems = append(problems, fmt.Sprintf("role %s is listed twice", r.Name))
+		}
+		roles[r.Name] = true
+		granted := map[string]bool{}
+		for _, p := range r.Permissions {
+			if !permissions[p] {
+				problems = append(problems, fmt.Sprintf("role %s grants permission %s, which isn't in the snapshot", r.Name, p))
+			}
+			if granted[p] {
+				problems = append(problems, fmt.Sprintf("role %s grants permission %s twice", r.Name, p))
+			}
+			granted[p] = true
+		}
+	}
+	if len(problems) > 0 {
+		return web.ErrValidation(errors.New("invalid snapshot"), problems)
+	}
+	return nil
+}
+
+// state is what's in the stores, deleted permissions and roles included.
+type state struct {
+	permissions []permission.Permission
+	roles       []role.Role
+	// grants maps role ids to the ids of the permissions granted to them.
+	grants map[uuid.UUID][]uuid.UUID
+}
+
+// export is the snapshot of the live part of s.
+func (s state) export() Snapshot {
+	snap := Snapshot{Version: Version, Permissions: []Permission{}, Roles: []Role{}}
+	names := map[uuid.UUID]string{}
+	for _, p := range s.permissions {
+		if p.DeletedAt == nil {
+			names[p.ID] = p.Name
+			snap.Permissions = append(snap.Permissions, Permission{Name: p.Name})
+		}
+	}
+	for _, r := range s.roles {
+		if r.DeletedAt != nil {
+			continue
+		}
+		granted := []string{}
+		for _, id := range s.grants[r.ID] {
+			if name, ok := names[id]; ok {
+				granted = append(granted, name)
+			}
+		}
+		sort.Strings(granted)
+		snap.Roles = append(snap.Roles, Role{Name: r.Name, Permissions: granted})
+	}
+	sort.Slice(snap.Permissions, func(i, j int) bool { return snap.Permissions[i].Name < snap.Permissions[j].Name })
+	sort.Slice(snap.Roles, func(i, j int) bool { return snap.Roles[i].Name < snap.Roles[j].Name })
+	return snap
+}
+
+type changeKind int
+
+const (
+	createPermission changeKind = iota
+	restorePermission
+	deletePermission
+	createRole
+	restoreRole
+	deleteRole
+	// replaceGrants sets Role's grants from Before to After.
+	replaceGrants
+)
+
+// change is one step of an import, in the order they're applied.
+type change struct {
+	Kind       changeKind
+	Permission permission.Permission
+	Role       role.Role
+	Before     []uuid.UUID
+	After      []uuid.UUID
+	// Granted and Revoked are the names of the permissions replaceGrants
+	// adds and removes.
+	Granted []string
+	Revoked []string
+}
+
+// Change actions.
+const (
+	ActionCreate  = "create"
+	ActionRestore = "restore"
+	ActionDelete  = "delete"
+	// ActionRegrant replaces a role's grants.
+	ActionRegrant = "regrant"
+)
+
+// Change describes one step of an import by name, for plans.
+type Change struct {
+	Action string `json:"action"`
+	// Type is audit.TargetPermission or audit.TargetRole.
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// Granted and Revoked are the permissions a regrant adds to and
+	// removes from the role.
+	Granted []string `json:"granted,omitempty"`
+	Revoked []string `json:"revoked,omitempty"`
+}
+
+func (c change) describe() Change {
+	switch c.Kind {
+	case createPermission:
+		return Change{Action: ActionCreate, Type: audit.TargetPermission, Name: c.Permission.Name}
+	case restorePermission:
+		return Change{Action: ActionRestore, Type: audit.TargetPermission, Name: c.Permission.Name}
+	case deletePermission:
+		return Change{Action: ActionDelete, Type: audit.TargetPermission, Name: c.Permission.Name}
+	case createRole:
+		return Change{Action: ActionCreate, Type: audit.TargetRole, Name: c.Role.Name}
+	case restoreRole:
+		return Change{Action: ActionRestore, Type: audit.TargetRole, Name: c.Role.Name}
+	case deleteRole:
+		return Change{Action: ActionDelete, Type: audit.TargetRole, Name: c.Role.Name}
+	default:
+		return Change{Action: ActionRegrant, Type: audit.TargetRole, Name: c.Role.Name, Granted: c.Granted, Revoked: c.Revoked}
+	}
+}
+
+// checkApproved is ErrStalePlan unless changes are the approved ones, or
+// nothing was approved in particular.
+func checkApproved(changes []change, approved []Change) error {
+	if approved != nil && !reflect.DeepEqual(describe(changes), approved) {
+		return ErrStalePlan
+	}
+	return nil
+}
+
+func describe(changes []change) []Change {
+	described := make([]Change, len(changes))
+	for i, c := range changes {
+		described[i] = c.describe()
+	}
+	return described
+}
+
+// plan works out the changes that bring s to snap in mode, which have
+// been validated. Changes that need new ids get them here.
+func (s state) plan(snap Snapshot, mode string) ([]change, Summary) {
+	var (
+		changes []change
+		summary Summary
+	)
+	permissions := map[string]permission.Permission{}
+	// a name can belong to one live permission or role and any number of
+	// deleted ones, the live one wins
+	for _, p := range s.permissions {
+		if seen, ok := permissions[p.Name]; ok && seen.DeletedAt == nil {
+			continue
+		}
+		permissions[p.Name] = p
+	}
+	wantPermissions := map[string]bool{}
+	for _, sp := range snap.Permissions {
+		wantPermissions[sp.Name] = true
+		p, ok := permissions[sp.Name]
+		switch {
+		case !ok:
+			p = permission.Permission{ID: uuid.New(), Name: sp.Name}
+			changes = append(changes, change{Kind: createPermission, Permission: p})
+			summary.PermissionsCreated++
+		case p.DeletedAt != nil:
+			changes = append(changes, change{Kind: restorePermission, Permission: p})
+			p.DeletedAt = nil
+			summary.PermissionsRestored++
+		}
+		permissions[sp.Name] = p
+	}
+
+	names := map[uuid.UUID]string{}
+	for name, p := range permissions {
+		names[p.ID] = name
+	}
+
+	roles := map[string]role.Role{}
+	for _, r := range s.roles {
+		if seen, ok := roles[r.Name]; ok && seen.DeletedAt == nil {
+			continue
+		}
+		roles[r.Name] = r
+	}
+	wantRoles := map[string]bool{}
+	for _, sr := range snap.Roles {
+		wantRoles[sr.Name] = true
+		r, ok := roles[sr.Name]
+		switch {
+		case !ok:
+			r = role.Role{ID: uuid.New(), Name: sr.Name}
+			changes = append(changes, change{Kind: createRole, Role: r})
+			summary.RolesCreated++
+		case r.DeletedAt != nil:
+			changes = append(changes, change{Kind: restoreRole, Role: r})
+			r.DeletedAt = nil
+			summary.RolesRestored++
+		}
+
+		before := sortedIDs(s.grants[r.ID])
+		after := make([]uuid.UUID, 0, len(sr.Permissions))
+		for _, name := range sr.Permissions {
+			after = append(after, permissions[name].ID)
+		}
+		after = sortedIDs(after)
+		if !equalIDs(before, after) {
+			granted, revoked := grantDiff(before, after, names)
+			changes = append(changes, change{Kind: replaceGrants, Role: r, Before: before, After: after, Granted: granted, Revoked: revoked})
+			summary.RolesRegranted++
+		}
+	}
+
+	if mode != ModeReplace {
+		return changes, summary
+	}
+	// roles first, so the permissions going next have no live dependents
+	for _, r := range s.roles {
+		if r.DeletedAt == nil && !wantRoles[r.Name] {
+			changes = append(changes, change{Kind: deleteRole, Role: r})
+			summary.RolesDeleted++
+		}
+	}
+	for _, p := range s.permissions {
+		if p.DeletedAt == nil && !wantPermissions[p.Name] {
+			changes = append(changes, change{Kind: deletePermission, Permission: p})
+			summary.PermissionsDeleted++
+		}
+	}
+	return changes, summary
+}
+
+// grantDiff names the permissions in after but not before, and the other
+// way around, sorted.
+func grantDiff(before, after []uuid.UUID, names map[uuid.UUID]string) (granted, revoked []string) {
+	had := map[uuid.UUID]bool{}
+	for _, id := range before {
+		had[id] = true
+	}
+	has := map[uuid.UUID]bool{}
+	for _, id := range after {
+		has[id] = true
+		if !had[id] {
+			granted = append(granted, names[id])
+		}
+	}
+	for _, id := range before {
+		if !has[id] {
+			revoked = append(revoked, names[id])
+		}
+	}
+	sort.Strings(granted)
+	sort.Strings(revoked)
+	return granted, revoked
+}
+
+func sortedIDs(ids []uuid.UUID) []uuid.UUID {
+	sorted := append([]uuid.UUID{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+	return sorted
+}
+
+func equalIDs(a, b []uuid.UUID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// record is how a change is audited and evented.
+type record struct {
+	action     string
+	eventType  string
+	targetType string
+	targetID   uuid.UUID
+	before     interface{}
+	after      interface{}
+}
+
+func (c change) record(at time.Time) record {
+	switch c.Kind {
+	case createPermission:
+		return record{audit.ActionCreate, outbox.PermissionCreated, audit.TargetPermission, c.Permission.ID, nil, c.Permission}
+	case restorePermission:
+		after := c.Permission
+		after.DeletedAt = nil
+		return record{audit.ActionRestore, outbox.PermissionRestored, audit.TargetPermission, c.Permission.ID, c.Permission, after}
+	case deletePermission:
+		after := c.Permission
+		after.DeletedAt = &at
+		return record{audit.ActionDelete, outbox.PermissionDeleted, audit.TargetPermission, c.Permission.ID, c.Permission, after}
+	case createRole:
+		return record{audit.ActionCreate, outbox.RoleCreated, audit.TargetRole, c.Role.ID, nil, c.Role}
+	case restoreRole:
+		after := c.Role
+		after.DeletedAt = nil
+		return record{audit.ActionRestore, outbox.RoleRestored, audit.TargetRole, c.Role.ID, c.Role, after}
+	case deleteRole:
+		after := c.Role
+		after.DeletedAt = &at
+		return record{audit.ActionDelete, outbox.RoleDeleted, audit.TargetRole, c.Role.ID, c.Role, after}
+	default:
+		return record{audit.ActionReplacePermissions, outbox.RolePermissionsReplaced, audit.TargetRole, c.Role.ID,
+			role.IncomingRolePermissions{PermissionIDs: c.Before},
+			role.IncomingRolePermissions{PermissionIDs: c.After}}
+	}
+}
@@ -0,0 +1,20 @@
+package snapshot
+
+import (
+	"database/sql"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+var _ Store = (*SQLiteStorage)(nil)
+
+func NewSQLiteStore(db *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{MySQLStorage: NewMySQLStore(database.NewSQLiteDBR(db))}
+}
+
+// SQLiteStorage imports and exports snapshots of a SQLite database file.
+// The queries MySQLStorage builds are portable, so it's the same store
+// built with the SQLite dialect.
+type SQLiteStorage struct {
+	*MySQLStorage
+}
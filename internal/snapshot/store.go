@@ -0,0 +1,206 @@
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+var _ Store = (*MySQLStorage)(nil)
+
+func NewMySQLStore(conn *dbr.Connection) *MySQLStorage {
+	return &MySQLStorage{conn: conn, sess: conn.NewSession(nil)}
+}
+
+// NewReplicatedMySQLStore is NewMySQLStore with exports read through
+// replicas, imports still go to conn.
+func NewReplicatedMySQLStore(conn *dbr.Connection, replicas *database.Replicas) *MySQLStorage {
+	s := NewMySQLStore(conn)
+	s.replicas = replicas
+	return s
+}
+
+type MySQLStorage struct {
+	conn     *dbr.Connection
+	sess     *dbr.Session
+	replicas *database.Replicas
+}
+
+// reader is the session reads made with ctx should use.
+func (s *MySQLStorage) reader(ctx context.Context) *dbr.Session {
+	if s.replicas == nil {
+		return s.sess
+	}
+	return s.replicas.Reader(ctx)
+}
+
+var (
+	permissionTable = database.NewTable("permission", permission.Permission{})
+	roleTable       = database.NewTable("role", role.Role{})
+)
+
+const rolePermissionTable = "role_permission"
+
+type grant struct {
+	RoleID       uuid.UUID `db:"role_id"`
+	PermissionID uuid.UUID `db:"permission_id"`
+}
+
+func (s *MySQLStorage) Export(ctx context.Context) (Snapshot, error) {
+	var st state
+	// one transaction so the three reads see the same policy
+	err := database.WithTransaction(ctx, s.reader(ctx), func(tx dbr.SessionRunner) error {
+		var err error
+		st, err = loadState(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return Snapshot{}, database.ClassifyError(err)
+	}
+	return st.export(), nil
+}
+
+// loadState reads every permission, role and grant through sess.
+func loadState(ctx context.Context, sess dbr.SessionRunner) (state, error) {
+	st := state{grants: map[uuid.UUID][]uuid.UUID{}}
+	if _, err := sess.Select(permissionTable.Columns...).
+		From(permissionTable.Name).
+		OrderBy("name").
+		LoadContext(ctx, &st.permissions); err != nil {
+		return st, err
+	}
+	if _, err := sess.Select(roleTable.Columns...).
+		From(roleTable.Name).
+		OrderBy("name").
+		LoadContext(ctx, &st.roles); err != nil {
+		return st, err
+	}
+	var grants []grant
+	if _, err := sess.Select("role_id", "permission_id").
+		From(rolePermissionTable).
+		LoadContext(ctx, &grants); err != nil {
+		return st, err
+	}
+	for _, g := range grants {
+		st.grants[g.RoleID] = append(st.grants[g.RoleID], g.PermissionID)
+	}
+	return st, nil
+}
+
+func (s *MySQLStorage) Plan(ctx context.Context, snap Snapshot, mode string) ([]Change, Summary, error) {
+	var st state
+	// the plan is made against the primary, it's what Import would see
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		var err error
+		st, err = loadState(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, Summary{}, database.ClassifyError(err)
+	}
+	changes, summary := st.plan(snap, mode)
+	return describe(changes), summary, nil
+}
+
+func (s *MySQLStorage) Import(ctx context.Context, snap Snapshot, mode string, at time.Time, approved []Change) (Summary, error) {
+	var summary Summary
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		st, err := loadState(ctx, tx)
+		if err != nil {
+			return err
+		}
+		var changes []change
+		changes, summary = st.plan(snap, mode)
+		if err := checkApproved(changes, approved); err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if err := applyChange(ctx, tx, c, at); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return summary, database.ClassifyError(err)
+}
+
+// applyChange makes c through tx, recording it in the audit log and the
+// outbox the way the permission and role stores do.
+func applyChange(ctx context.Context, tx dbr.SessionRunner, c change, at time.Time) error {
+	switch c.Kind {
+	case createPermission:
+		if _, err := tx.InsertInto(permissionTable.Name).
+			Columns(permissionTable.Columns...).
+			Record(c.Permission).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+	case restorePermission, deletePermission:
+		var deletedAt *time.Time
+		if c.Kind == deletePermission {
+			deletedAt = &at
+		}
+		if _, err := tx.Update(permissionTable.Name).
+			Set("deleted_at", deletedAt).
+			Where("id = ?", c.Permission.ID).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		if c.Kind == deletePermission {
+			// the roles kept had their grants replaced already, these are
+			// the grants of deleted roles
+			if _, err := tx.DeleteFrom(rolePermissionTable).
+				Where("permission_id = ?", c.Permission.ID).
+				ExecContext(ctx); err != nil {
+				return err
+			}
+		}
+	case createRole:
+		if _, err := tx.InsertInto(roleTable.Name).
+			Columns(roleTable.Columns...).
+			Record(c.Role).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+	case restoreRole, deleteRole:
+		var deletedAt *time.Time
+		if c.Kind == deleteRole {
+			deletedAt = &at
+		}
+		if _, err := tx.Update(roleTable.Name).
+			Set("deleted_at", deletedAt).
+			Where("id = ?", c.Role.ID).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+	case replaceGrants:
+		if _, err := tx.DeleteFrom(rolePermissionTable).
+			Where("role_id = ?", c.Role.ID).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		if len(c.After) > 0 {
+			insert := tx.InsertInto(rolePermissionTable).Columns("role_id", "permission_id")
+			for _, pid := range c.After {
+				insert = insert.Values(c.Role.ID, pid)
+			}
+			if _, err := insert.ExecContext(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	r := c.record(at)
+	if err := audit.Write(ctx, tx, r.action, r.targetType, r.targetID, r.before, r.after); err != nil {
+		return err
+	}
+	return outbox.Write(ctx, tx, r.eventType, r.targetType, r.targetID, r.after)
+}
@@ -0,0 +1,37 @@
+package snapshot
+
+// Version is the snapshot format version Export writes and Import reads.
+const Version = 1
+
+// Snapshot is the whole policy: every live permission and role, and the
+// grants between them. Everything is referenced and ordered by name, so
+// snapshots from different environments compare line by line. The
+// service has no bindings, tuples or tenants yet, so there's nothing of
+// theirs to carry and a snapshot that has them is refused.
+type Snapshot struct {
+	Version     int          `json:"version" yaml:"version"`
+	Permissions []Permission `json:"permissions" yaml:"permissions"`
+	Roles       []Role       `json:"roles" yaml:"roles"`
+}
+
+type Permission struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+type Role struct {
+	Name string `json:"name" yaml:"name"`
+	// Permissions are the names of the permissions granted to the role.
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+// Summary counts what an Import changed.
+type Summary struct {
+	PermissionsCreated  int `json:"permissions_created"`
+	PermissionsRestored int `json:"permissions_restored"`
+	PermissionsDeleted  int `json:"permissions_deleted"`
+	RolesCreated        int `json:"roles_created"`
+	RolesRestored       int `json:"roles_restored"`
+	RolesDeleted        int `json:"roles_deleted"`
+	// RolesRegranted counts the roles whose grants were replaced.
+	RolesRegranted int `json:"roles_regranted"`
+}
@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+var _ Store = (*CockroachDBStorage)(nil)
+
+func NewCockroachDBStore(pool *pgxpool.Pool) *CockroachDBStorage {
+	return &CockroachDBStorage{pool: pool}
+}
+
+// CockroachDBStorage keeps webhooks in CockroachDB, or any other Postgres
+// compatible database, through pgx.
+type CockroachDBStorage struct {
+	pool *pgxpool.Pool
+}
+
+const (
+	subscriptionColumns = `id, url, event_types, secret, active, created_at, updated_at`
+	deliveryColumns     = `id, subscription_id, event_id, event_type, payload, status, attempts,
+		next_attempt_at, response_status, last_error, created_at, updated_at`
+)
+
+// querier is what pgxpool.Pool and pgx.Tx have in common for reads.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func (s *CockroachDBStorage) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	subs, err := listSubscriptions(ctx, s.pool, `ORDER BY created_at, id`)
+	return subs, database.ClassifyError(err)
+}
+
+// listSubscriptions loads the subscriptions matching where, which holds
+// everything after FROM.
+func listSubscriptions(ctx context.Context, q querier, where string, args ...interface{}) ([]Subscription, error) {
+	rows, err := q.Query(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscription `+where, args...)
+	if err != nil {
+		return []Subscription{}, err
+	}
+	defer rows.Close()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.ID, &sub.URL, &sub.EventTypes, &sub.Secret, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt); err != nil {
+			return subs, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *CockroachDBStorage) GetSubscription(ctx context.Context, id uuid.UUID) (Subscription, error) {
+	var sub Subscription
+	err := s.pool.QueryRow(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscription WHERE id = $1`, id).
+		Scan(&sub.ID, &sub.URL, &sub.EventTypes, &sub.Secret, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt)
+	return sub, database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) CreateSubscription(ctx context.Context, sub Subscription) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO webhook_subscription (`+subscriptionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		sub.ID, sub.URL, sub.EventTypes, sub.Secret, sub.Active, sub.CreatedAt, sub.UpdatedAt)
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) UpdateSubscription(ctx context.Context, sub Subscription) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE webhook_subscription
+		SET url = $1, event_types = $2, secret = $3, active = $4, updated_at = $5 WHERE id = $6`,
+		sub.URL, sub.EventTypes, sub.Secret, sub.Active, sub.UpdatedAt, sub.ID)
+	if err == nil && tag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+	}
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM webhook_delivery WHERE subscription_id = $1`, id); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `DELETE FROM webhook_subscription WHERE id = $1`, id)
+		if err == nil && tag.RowsAffected() == 0 {
+			err = pgx.ErrNoRows
+		}
+		return err
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) Enqueue(ctx context.Context, event outbox.Event, payload []byte, at time.Time) error {
+	err := database.WithPgxTransaction(ctx, s.pool, func(tx pgx.Tx) error {
+		subs, err := listSubscriptions(ctx, tx, `WHERE active AND id NOT IN
+			(SELECT subscription_id FROM webhook_delivery WHERE event_id = $1)`, event.ID)
+		if err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			if !sub.Wants(event.Type) {
+				continue
+			}
+			d := newDelivery(sub, event, payload, at)
+			if _, err := tx.Exec(ctx, `INSERT INTO webhook_delivery (`+deliveryColumns+`)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+				d.ID, d.SubscriptionID, d.EventID, d.EventType, d.Payload, d.Status, d.Attempts,
+				d.NextAttemptAt, d.ResponseStatus, d.LastError, d.CreatedAt, d.UpdatedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	return s.listDeliveries(ctx, `WHERE status = $1 AND next_attempt_at <= $2 ORDER BY next_attempt_at LIMIT $3`,
+		StatusPending, now, limit)
+}
+
+func (s *CockroachDBStorage) ClaimDelivery(ctx context.Context, d Delivery, leaseUntil time.Time) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `UPDATE webhook_delivery SET attempts = $1, next_attempt_at = $2
+		WHERE id = $3 AND status = $4 AND attempts = $5`,
+		d.Attempts+1, leaseUntil, d.ID, StatusPending, d.Attempts)
+	return tag.RowsAffected() == 1, database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) RecordAttempt(ctx context.Context, d Delivery) error {
+	_, err := s.pool.Exec(ctx, `UPDATE webhook_delivery
+		SET status = $1, next_attempt_at = $2, response_status = $3, last_error = $4, updated_at = $5 WHERE id = $6`,
+		d.Status, d.NextAttemptAt, d.ResponseStatus, d.LastError, d.UpdatedAt, d.ID)
+	return database.ClassifyError(err)
+}
+
+func (s *CockroachDBStorage) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status string, limit int) ([]Delivery, error) {
+	return s.listDeliveries(ctx, `WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC LIMIT $3`, subscriptionID, status, limit)
+}
+
+// listDeliveries loads the deliveries matching where, which holds
+// everything after FROM.
+func (s *CockroachDBStorage) listDeliveries(ctx context.Context, where string, args ...interface{}) ([]Delivery, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+deliveryColumns+` FROM webhook_delivery `+where, args...)
+	if err != nil {
+		return []Delivery{}, database.ClassifyError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return deliveries, database.ClassifyError(err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, database.ClassifyError(rows.Err())
+}
@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+const (
+	DefaultMaxAttempts = 10
+	DefaultBackoffBase = 10 * time.Second
+	DefaultBackoffMax  = time.Hour
+	DefaultTimeout     = 5 * time.Second
+	dispatchBatchSize  = 100
+)
+
+// Headers sent with every delivery besides SignatureHeader.
+const (
+	DeliveryIDHeader = "X-Webhook-Delivery"
+	EventIDHeader    = "X-Event-ID"
+	EventTypeHeader  = "X-Event-Type"
+)
+
+var _ outbox.Publisher = (*Dispatcher)(nil)
+
+// errInactive fails the pending deliveries of a deactivated subscription.
+var errInactive = errors.New("subscription is inactive")
+
+// Dispatcher queues events for subscriptions as an outbox.Publisher and
+// delivers the queue. A failed delivery is retried after BackoffBase,
+// doubling every attempt up to BackoffMax, and fails for good after
+// MaxAttempts. Several dispatchers can share a Store, each delivery is
+// claimed by one of them at a time.
+type Dispatcher struct {
+	Store       Store
+	Client      *http.Client
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Now is the clock, time.Now when nil.
+	Now func() time.Time
+}
+
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{
+		Store:       store,
+		Client:      &http.Client{Timeout: DefaultTimeout},
+		MaxAttempts: DefaultMaxAttempts,
+		BackoffBase: DefaultBackoffBase,
+		BackoffMax:  DefaultBackoffMax,
+	}
+}
+
+func (d *Dispatcher) now() time.Time {
+	if d.Now != nil {
+		return d.Now().UTC().Truncate(time.Microsecond)
+	}
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// Publish queues event for the subscriptions that want it, the delivery
+// itself happens in DeliverDue.
+func (d *Dispatcher) Publish(ctx context.Context, event outbox.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return d.Store.Enqueue(ctx, event, payload, d.now())
+}
+
+// DeliverDue attempts every delivery that's due and returns how many it
+// attempted. Failures are recorded on the deliveries, the error is only
+// for the store.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	attempted := 0
+	subs := map[uuid.UUID]Subscription{}
+	for {
+		due, err := d.Store.ListDueDeliveries(ctx, d.now(), dispatchBatchSize)
+		if err != nil {
+			return attempted, err
+		}
+		claimed := 0
+		for _, delivery := range due {
+			// the lease outlasts the request, it's replaced by RecordAttempt
+			ok, err := d.Store.ClaimDelivery(ctx, delivery, d.now().Add(2*d.timeout()))
+			if err != nil {
+				return attempted, err
+			}
+			if !ok {
+				continue
+			}
+			claimed++
+			delivery.Attempts++
+
+			sub, ok := subs[delivery.SubscriptionID]
+			if !ok {
+				sub, err = d.Store.GetSubscription(ctx, delivery.SubscriptionID)
+				if bestirerror.StatusCode(err) == http.StatusNotFound {
+					// deleted since, its deliveries went with it
+					continue
+				}
+				if err != nil {
+					return attempted, err
+				}
+				subs[sub.ID] = sub
+			}
+			if err := d.Store.RecordAttempt(ctx, d.attempt(ctx, sub, delivery)); err != nil {
+				return attempted, err
+			}
+			attempted++
+		}
+		// a batch lost entirely to other dispatchers could come back the
+		// same, leave it to them
+		if len(due) < dispatchBatchSize || claimed == 0 {
+			return attempted, nil
+		}
+	}
+}
+
+// attempt sends delivery to sub and returns it updated with the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, sub Subscription, delivery Delivery) Delivery {
+	var (
+		status int
+		err    = errInactive
+	)
+	if sub.Active {
+		status, err = d.send(ctx, sub, delivery)
+	}
+	now := d.now()
+	delivery.UpdatedAt = now
+	delivery.ResponseStatus, delivery.LastError = nil, nil
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+	switch {
+	case err == nil:
+		delivery.Status = StatusSucceeded
+		return delivery
+	case !sub.Active, delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = StatusFailed
+	default:
+		delivery.Status = StatusPending
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+	msg := err.Error()
+	delivery.LastError = &msg
+	return delivery
+}
+
+// send POSTs the delivery and returns the response status, 0 when there
+// was no response.
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, delivery Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, d.now(), body))
+	req.Header.Set(DeliveryIDHeader, delivery.ID.String())
+	req.Header.Set(EventIDHeader, delivery.EventID.String())
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) //nolint:errcheck
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is how long to wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.BackoffBase
+	for i := 1; i < attempts && wait < d.BackoffMax; i++ {
+		wait *= 2
+	}
+	if wait > d.BackoffMax {
+		wait = d.BackoffMax
+	}
+	return wait
+}
+
+func (d *Dispatcher) timeout() time.Duration {
+	if d.Client.Timeout > 0 {
+		return d.Client.Timeout
+	}
+	return DefaultTimeout
+}
+
+// Run calls DeliverDue every interval until ctx is done, errors go to
+// onError.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
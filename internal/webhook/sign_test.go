@@ -0,0 +1,37 @@
+package webhook_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/webhook"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret, body := "whsec_test", []byte(`{"type":"RoleCreated"}`)
+	signedAt := time.Unix(1792400000, 0)
+	header := webhook.Sign(secret, signedAt, body)
+
+	for name, tc := range map[string]struct {
+		secret, header string
+		body           []byte
+		now            time.Time
+		want           error
+	}{
+		"Valid":        {secret, header, body, signedAt.Add(time.Minute), nil},
+		"WrongSecret":  {"whsec_other", header, body, signedAt, webhook.ErrSignatureMismatch},
+		"TamperedBody": {secret, header, []byte(`{"type":"RoleDeleted"}`), signedAt, webhook.ErrSignatureMismatch},
+		"Expired":      {secret, header, body, signedAt.Add(time.Hour), webhook.ErrSignatureExpired},
+		"FromFuture":   {secret, header, body, signedAt.Add(-time.Hour), webhook.ErrSignatureExpired},
+		"Missing":      {secret, "", body, signedAt, webhook.ErrMalformedSignature},
+		"Garbage":      {secret, "t=soon,v1=abc", body, signedAt, webhook.ErrMalformedSignature},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := webhook.Verify(tc.secret, tc.header, tc.body, tc.now, webhook.DefaultTolerance)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("Verify() = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
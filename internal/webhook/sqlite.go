@@ -0,0 +1,19 @@
+package webhook
+
+import (
+	"database/sql"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+var _ Store = (*SQLiteStorage)(nil)
+
+func NewSQLiteStore(db *sql.DB) *SQLiteStorage {
+	return &SQLiteStorage{MySQLStorage: NewMySQLStore(database.NewSQLiteDBR(db))}
+}
+
+// SQLiteStorage keeps webhooks in a SQLite database file, with the same
+// queries as MySQLStorage.
+type SQLiteStorage struct {
+	*MySQLStorage
+}
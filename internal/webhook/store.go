@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/gocraft/dbr/v2"
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+var _ Store = (*MySQLStorage)(nil)
+
+func NewMySQLStore(conn *dbr.Connection) *MySQLStorage {
+	return &MySQLStorage{conn: conn, sess: conn.NewSession(nil)}
+}
+
+type MySQLStorage struct {
+	conn *dbr.Connection
+	sess *dbr.Session
+}
+
+var (
+	subscriptionTable = database.NewTable("webhook_subscription", Subscription{})
+	deliveryTable     = database.NewTable("webhook_delivery", Delivery{})
+)
+
+func (s *MySQLStorage) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	subs := []Subscription{}
+	_, err := s.sess.Select(subscriptionTable.Columns...).
+		From(subscriptionTable.Name).
+		OrderBy("created_at").
+		OrderBy("id").
+		LoadContext(ctx, &subs)
+	return subs, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) GetSubscription(ctx context.Context, id uuid.UUID) (Subscription, error) {
+	var sub Subscription
+	err := s.sess.Select(subscriptionTable.Columns...).
+		From(subscriptionTable.Name).
+		Where("id = ?", id).
+		LoadOneContext(ctx, &sub)
+	return sub, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) CreateSubscription(ctx context.Context, sub Subscription) error {
+	_, err := s.sess.InsertInto(subscriptionTable.Name).
+		Columns(subscriptionTable.Columns...).
+		Record(sub).
+		ExecContext(ctx)
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) UpdateSubscription(ctx context.Context, sub Subscription) error {
+	res, err := s.sess.Update(subscriptionTable.Name).
+		Set("url", sub.URL).
+		Set("event_types", sub.EventTypes).
+		Set("secret", sub.Secret).
+		Set("active", sub.Active).
+		Set("updated_at", sub.UpdatedAt).
+		Where("id = ?", sub.ID).
+		ExecContext(ctx)
+	if err != nil {
+		return database.ClassifyError(err)
+	}
+	// updated_at always changes, so 0 rows means the subscription is gone
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return database.ClassifyError(dbr.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *MySQLStorage) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		// the foreign key cascades too, but SQLite only enforces it when
+		// the connection asks
+		if _, err := tx.DeleteFrom(deliveryTable.Name).
+			Where("subscription_id = ?", id).
+			ExecContext(ctx); err != nil {
+			return err
+		}
+		res, err := tx.DeleteFrom(subscriptionTable.Name).
+			Where("id = ?", id).
+			ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return dbr.ErrNotFound
+		}
+		return nil
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) Enqueue(ctx context.Context, event outbox.Event, payload []byte, at time.Time) error {
+	err := database.WithTransaction(ctx, s.sess, func(tx dbr.SessionRunner) error {
+		subs := []Subscription{}
+		if _, err := tx.Select(subscriptionTable.Columns...).
+			From(subscriptionTable.Name).
+			Where("active = ?", true).
+			LoadContext(ctx, &subs); err != nil {
+			return err
+		}
+
+		queued := []uuid.UUID{}
+		if _, err := tx.Select("subscription_id").
+			From(deliveryTable.Name).
+			Where("event_id = ?", event.ID).
+			LoadContext(ctx, &queued); err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			if !sub.Wants(event.Type) || contains(queued, sub.ID) {
+				continue
+			}
+			if _, err := tx.InsertInto(deliveryTable.Name).
+				Columns(deliveryTable.Columns...).
+				Record(newDelivery(sub, event, payload, at)).
+				ExecContext(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	_, err := s.sess.Select(deliveryTable.Columns...).
+		From(deliveryTable.Name).
+		Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+		OrderBy("next_attempt_at").
+		Limit(uint64(limit)).
+		LoadContext(ctx, &deliveries)
+	return deliveries, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) ClaimDelivery(ctx context.Context, d Delivery, leaseUntil time.Time) (bool, error) {
+	res, err := s.sess.Update(deliveryTable.Name).
+		Set("attempts", d.Attempts+1).
+		Set("next_attempt_at", leaseUntil).
+		Where("id = ? AND status = ? AND attempts = ?", d.ID, StatusPending, d.Attempts).
+		ExecContext(ctx)
+	if err != nil {
+		return false, database.ClassifyError(err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) RecordAttempt(ctx context.Context, d Delivery) error {
+	_, err := s.sess.Update(deliveryTable.Name).
+		Set("status", d.Status).
+		Set("next_attempt_at", d.NextAttemptAt).
+		Set("response_status", d.ResponseStatus).
+		Set("last_error", d.LastError).
+		Set("updated_at", d.UpdatedAt).
+		Where("id = ?", d.ID).
+		ExecContext(ctx)
+	return database.ClassifyError(err)
+}
+
+func (s *MySQLStorage) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status string, limit int) ([]Delivery, error) {
+	query := s.sess.Select(deliveryTable.Columns...).
+		From(deliveryTable.Name).
+		Where("subscription_id = ?", subscriptionID).
+		OrderDesc("created_at").
+		OrderDesc("id").
+		Limit(uint64(limit))
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	deliveries := []Delivery{}
+	_, err := query.LoadContext(ctx, &deliveries)
+	return deliveries, database.ClassifyError(err)
+}
+
+// newDelivery is a pending delivery of event to sub, due right away.
+func newDelivery(sub Subscription, event outbox.Event, payload []byte, at time.Time) Delivery {
+	return Delivery{
+		ID:             uuid.New(),
+		SubscriptionID: sub.ID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		Payload:        database.JSON(payload),
+		Status:         StatusPending,
+		NextAttemptAt:  at,
+		CreatedAt:      at,
+		UpdatedAt:      at,
+	}
+}
+
+func contains(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
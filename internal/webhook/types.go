@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+)
+
+// Subscription is an endpoint that wants events POSTed to it.
+type Subscription struct {
+	ID  uuid.UUID `db:"id" json:"id"`
+	URL string    `db:"url" json:"url"`
+	// EventTypes filters the events delivered, empty delivers all of them.
+	EventTypes EventTypes `db:"event_types" json:"event_types"`
+	// Secret signs deliveries, it's only returned when the subscription is
+	// created.
+	Secret string `db:"secret" json:"secret,omitempty"`
+	// Active subscriptions get deliveries, inactive ones keep their log.
+	Active    bool      `db:"active" json:"active"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Wants reports whether sub should get events of eventType.
+func (sub Subscription) Wants(eventType string) bool {
+	if !sub.Active {
+		return false
+	}
+	if len(sub.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range sub.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type IncomingSubscription struct {
+	URL        string   `json:"url" validate:"required"`
+	EventTypes []string `json:"event_types"`
+	// Secret is generated on create when empty, and left alone on update.
+	Secret string `json:"secret"`
+	// Active defaults to true on create, and is left alone on update when
+	// it's absent.
+	Active *bool `json:"active"`
+	// Tenant is refused, the service has no tenants to filter on yet and
+	// taking it would deliver every tenant's events to the subscription.
+	Tenant string `json:"tenant"`
+}
+
+// Delivery is one event on its way to a subscription, and once it's
+// settled, the record of how that went.
+type Delivery struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	SubscriptionID uuid.UUID `db:"subscription_id" json:"subscription_id"`
+	EventID        uuid.UUID `db:"event_id" json:"event_id"`
+	EventType      string    `db:"event_type" json:"event_type"`
+	// Payload is the request body, the outbox.Event as JSON.
+	Payload database.JSON `db:"payload" json:"-"`
+	Status  string        `db:"status" json:"status"`
+	// Attempts counts the requests made so far.
+	Attempts int `db:"attempts" json:"attempts"`
+	// NextAttemptAt is when a pending delivery is tried again.
+	NextAttemptAt time.Time `db:"next_attempt_at" json:"next_attempt_at"`
+	// ResponseStatus and LastError describe the latest attempt, LastError
+	// is empty when it succeeded.
+	ResponseStatus *int      `db:"response_status" json:"response_status,omitempty"`
+	LastError      *string   `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// EventTypes is stored as a JSON array.
+type EventTypes []string
+
+func (et EventTypes) Value() (driver.Value, error) {
+	if et == nil {
+		et = EventTypes{}
+	}
+	b, err := json.Marshal([]string(et))
+	return string(b), err
+}
+
+func (et *EventTypes) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*et = EventTypes{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("scanning event types from %T", value)
+	}
+	types := []string{}
+	if err := json.Unmarshal(b, &types); err != nil {
+		return err
+	}
+	*et = EventTypes(types)
+	return nil
+}
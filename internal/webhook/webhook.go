@@ -0,0 +1,205 @@
+// Package webhook pushes permission and role events to subscribed HTTP
+// endpoints, for consumers that can't hold a /watch stream open. The outbox
+// relay hands every event to a Dispatcher, which queues a Delivery for each
+// matching Subscription and then POSTs them, signed with the subscription's
+// secret and retried with exponential backoff. Deliveries are kept as the
+// subscription's delivery log.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	DefaultDeliveryLimit = 100
+	MaxDeliveryLimit     = 1000
+)
+
+// Store keeps subscriptions and their deliveries. Implementations must
+// report missing subscriptions with database.ErrNotFound.
+type Store interface {
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	GetSubscription(ctx context.Context, id uuid.UUID) (Subscription, error)
+	CreateSubscription(ctx context.Context, sub Subscription) error
+	UpdateSubscription(ctx context.Context, sub Subscription) error
+	// DeleteSubscription removes the subscription with its deliveries.
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+
+	// Enqueue queues a pending delivery of event for every active
+	// subscription that wants its type. Subscriptions that already have a
+	// delivery of the event are skipped, so relaying an event twice doesn't
+	// deliver it twice.
+	Enqueue(ctx context.Context, event outbox.Event, payload []byte, at time.Time) error
+	// ListDueDeliveries returns up to limit pending deliveries due by now,
+	// the longest waiting first.
+	ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	// ClaimDelivery counts an attempt at d and holds it off until
+	// leaseUntil, so other dispatchers skip it while it's in flight. It
+	// reports false when another dispatcher got to d first.
+	ClaimDelivery(ctx context.Context, d Delivery, leaseUntil time.Time) (bool, error)
+	// RecordAttempt saves the outcome of an attempt at d.
+	RecordAttempt(ctx context.Context, d Delivery) error
+	// ListDeliveries returns the subscription's deliveries newest first,
+	// only those with status unless it's empty.
+	ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status string, limit int) ([]Delivery, error)
+}
+
+type API struct {
+	Store Store
+}
+
+func NewAPI(store Store) *API {
+	return &API{
+		Store: store,
+	}
+}
+
+// ListSubscriptions returns every subscription, without secrets.
+func (api *API) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	subs, err := api.Store.ListSubscriptions(ctx)
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, err
+}
+
+// GetSubscription returns subscription id, without its secret.
+func (api *API) GetSubscription(ctx context.Context, id uuid.UUID) (Subscription, error) {
+	sub, err := api.Store.GetSubscription(ctx, id)
+	sub.Secret = ""
+	return sub, err
+}
+
+// CreateSubscription subscribes incoming.URL to events. The returned
+// subscription is the only one that includes the secret, one is generated
+// when incoming doesn't set it.
+func (api *API) CreateSubscription(ctx context.Context, incoming IncomingSubscription) (Subscription, error) {
+	if err := validate(incoming); err != nil {
+		return Subscription{}, err
+	}
+	secret := incoming.Secret
+	if secret == "" {
+		var err error
+		if secret, err = newSecret(); err != nil {
+			return Subscription{}, err
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	sub := Subscription{
+		ID:         uuid.New(),
+		URL:        incoming.URL,
+		EventTypes: normalize(incoming.EventTypes),
+		Secret:     secret,
+		Active:     incoming.Active == nil || *incoming.Active,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	err := api.Store.CreateSubscription(ctx, sub)
+
+	return sub, err
+}
+
+// UpdateSubscription replaces the subscription's URL, event types and
+// active flag, and its secret when incoming sets one.
+func (api *API) UpdateSubscription(ctx context.Context, id uuid.UUID, incoming IncomingSubscription) (Subscription, error) {
+	if err := validate(incoming); err != nil {
+		return Subscription{}, err
+	}
+	sub, err := api.Store.GetSubscription(ctx, id)
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	sub.URL = incoming.URL
+	sub.EventTypes = normalize(incoming.EventTypes)
+	if incoming.Active != nil {
+		sub.Active = *incoming.Active
+	}
+	if incoming.Secret != "" {
+		sub.Secret = incoming.Secret
+	}
+	sub.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	err = api.Store.UpdateSubscription(ctx, sub)
+	sub.Secret = ""
+
+	return sub, err
+}
+
+func (api *API) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	return api.Store.DeleteSubscription(ctx, id)
+}
+
+// ListDeliveries returns the subscription's delivery log newest first.
+func (api *API) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status string, limit int) ([]Delivery, error) {
+	switch status {
+	case "", StatusPending, StatusSucceeded, StatusFailed:
+	default:
+		return []Delivery{}, bestirerror.WithCodeAndMessagef(errors.New("unknown delivery status"), http.StatusBadRequest,
+			"status must be %s, %s or %s", StatusPending, StatusSucceeded, StatusFailed)
+	}
+	if limit <= 0 || limit > MaxDeliveryLimit {
+		limit = DefaultDeliveryLimit
+	}
+	if _, err := api.Store.GetSubscription(ctx, subscriptionID); err != nil {
+		return []Delivery{}, err
+	}
+	return api.Store.ListDeliveries(ctx, subscriptionID, status, limit)
+}
+
+func validate(incoming IncomingSubscription) error {
+	u, err := url.Parse(incoming.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return bestirerror.WithCodeAndMessagef(errors.New("invalid webhook url"), http.StatusBadRequest, "url %q is not an absolute http or https url", incoming.URL)
+	}
+	if incoming.Tenant != "" {
+		return bestirerror.WithCodeAndMessage(errors.New("tenant filter"), http.StatusBadRequest, "webhooks can't filter on tenant, the service has no tenants yet")
+	}
+	var unknown []string
+	for _, t := range incoming.EventTypes {
+		if !outbox.IsEventType(t) {
+			unknown = append(unknown, t)
+		}
+	}
+	if len(unknown) > 0 {
+		err := bestirerror.WithCodeAndMessage(errors.New("unknown event type"), http.StatusBadRequest, "unknown event types")
+		return bestirerror.WithDetails(err, unknown)
+	}
+	return nil
+}
+
+// normalize never stores a nil list, so it's [] rather than null in JSON.
+func normalize(eventTypes []string) EventTypes {
+	if eventTypes == nil {
+		return EventTypes{}
+	}
+	return EventTypes(eventTypes)
+}
+
+// newSecret generates a 256 bit signing secret.
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
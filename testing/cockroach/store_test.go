@@ -0,0 +1,241 @@
+package cockroach_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/ory/dockertest"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+var (
+	port string
+	pool *pgxpool.Pool
+)
+
+// Taken from: https://github.com/ory/dockertest#using-dockertest
+func TestMain(m *testing.M) {
+	dp, err := dockertest.NewPool("")
+	if err != nil {
+		log.Fatalf("Could not connect to docker: %s", err)
+	}
+	resource, err := dp.RunWithOptions(&dockertest.RunOptions{
+		Repository: "cockroachdb/cockroach",
+		Tag:        "v22.1.10",
+		Cmd:        []string{"start-single-node", "--insecure"},
+	})
+	if err != nil {
+		log.Fatalf("Could not start resource: %s", err)
+	}
+	// Tell docker to hard kill the container in 600 seconds
+	if err := resource.Expire(600); err != nil {
+		log.Fatalf("Could not set resource expiration time: %s", err)
+	}
+
+	// exponential backoff-retry, because cockroach might not be ready to accept connections yet
+	if err := dp.Retry(func() error {
+		var err error
+		port = resource.GetPort("26257/tcp")
+		pool, err = pgxpool.Connect(context.Background(),
+			fmt.Sprintf("postgres://root@localhost:%s/defaultdb?sslmode=disable", port))
+		if err != nil {
+			return err
+		}
+		return pool.Ping(context.Background())
+	}); err != nil {
+		log.Fatalf("Could not connect to database: %s", err)
+	}
+	code := m.Run()
+
+	pool.Close()
+	// You can't defer this because os.Exit doesn't care for defer
+	if err := dp.Purge(resource); err != nil {
+		log.Fatalf("Could not purge resource: %s", err)
+	}
+
+	os.Exit(code)
+}
+
+func TestStores(t *testing.T) {
+	migrate.TestEnsureMigrations(t, migrate.Config{
+		Driver:           migrate.DriverPostgres,
+		User:             "root",
+		Host:             "localhost",
+		Port:             port,
+		Name:             "defaultdb",
+		AdditionalParams: map[string]string{"sslmode": "disable"},
+	})
+	ctx := context.Background()
+
+	t.Run("Permission", func(t *testing.T) {
+		api := permission.NewAPI(permission.NewCockroachDBStore(pool))
+
+		created, err := api.Createpermission(ctx, permission.Incomingpermission{Name: "games.build.deploy"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = api.Createpermission(ctx, permission.Incomingpermission{Name: "games.build.deploy"})
+		diff(t, http.StatusConflict, bestirerror.StatusCode(err))
+
+		got, err := api.Getpermission(ctx, created.ID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, created, got)
+
+		_, err = api.Getpermission(ctx, uuid.New(), false)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+
+		updated, err := api.Updatepermission(ctx, created.ID, permission.Incomingpermission{Name: "games.build.ship"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		list, err := api.Listpermissiones(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, []permission.Permission{updated}, list)
+
+		if err := api.Deletepermission(ctx, created.ID, false); err != nil {
+			t.Fatal(err)
+		}
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(api.Deletepermission(ctx, created.ID, false)))
+
+		restored, err := api.Restorepermission(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, updated, restored)
+
+		if err := api.Deletepermission(ctx, created.ID, false); err != nil {
+			t.Fatal(err)
+		}
+		purged, err := api.Purgepermissions(ctx, -time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, int64(1), purged)
+
+		records, err := audit.NewAPI(audit.NewCockroachDBStore(pool)).ListRecords(ctx, audit.Filter{TargetID: created.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actions []string
+		for _, rec := range records {
+			actions = append(actions, rec.Action)
+		}
+		diff(t, []string{"delete", "restore", "delete", "update", "create"}, actions)
+
+		// without a publisher the relay sequences the events and marks them
+		// published
+		store := outbox.NewCockroachDBStore(pool)
+		if _, err := outbox.NewRelay(store, nil).PublishPending(ctx); err != nil {
+			t.Fatal(err)
+		}
+		pending, err := store.ListPending(ctx, outbox.DefaultBatchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, 0, len(pending))
+		events, err := store.ListSince(ctx, 0, outbox.DefaultBatchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var types []string
+		for _, event := range events {
+			if event.AggregateID == created.ID {
+				types = append(types, event.Type)
+			}
+		}
+		diff(t, []string{outbox.PermissionCreated, outbox.PermissionUpdated, outbox.PermissionDeleted,
+			outbox.PermissionRestored, outbox.PermissionDeleted}, types)
+	})
+
+	t.Run("Role", func(t *testing.T) {
+		api := role.NewAPI(role.NewCockroachDBStore(pool))
+
+		created, err := api.CreateRole(ctx, role.IncomingRole{Name: "developer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = api.CreateRole(ctx, role.IncomingRole{Name: "developer"})
+		diff(t, http.StatusConflict, bestirerror.StatusCode(err))
+
+		_, err = api.UpdateRole(ctx, uuid.New(), role.IncomingRole{Name: "viewer"})
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+
+		perm, err := permission.NewAPI(permission.NewCockroachDBStore(pool)).
+			Createpermission(ctx, permission.Incomingpermission{Name: "games.build.view"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		granted, err := api.ReplaceRolePermissions(ctx, created.ID, role.IncomingRolePermissions{PermissionIDs: []uuid.UUID{perm.ID, perm.ID}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, []uuid.UUID{perm.ID}, granted)
+		_, err = api.ReplaceRolePermissions(ctx, created.ID, role.IncomingRolePermissions{PermissionIDs: []uuid.UUID{uuid.New()}})
+		diff(t, http.StatusBadRequest, bestirerror.StatusCode(err))
+		list, err := api.ListRolePermissions(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, granted, list)
+
+		permissions := permission.NewAPI(permission.NewCockroachDBStore(pool))
+		dependents, err := permissions.ListpermissionDependents(ctx, perm.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, []permission.DependentRole{{ID: created.ID, Name: created.Name}}, dependents.Roles)
+		diff(t, http.StatusConflict, bestirerror.StatusCode(permissions.Deletepermission(ctx, perm.ID, false)))
+		if err := permissions.Deletepermission(ctx, perm.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		list, err = api.ListRolePermissions(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, []uuid.UUID{}, list)
+
+		if err := api.DeleteRole(ctx, created.ID); err != nil {
+			t.Fatal(err)
+		}
+		_, err = api.GetRole(ctx, created.ID, false)
+		diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+		deleted, err := api.GetRole(ctx, created.ID, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted.DeletedAt == nil {
+			t.Error("deleted role has no deleted_at")
+		}
+
+		purged, err := api.PurgeRoles(ctx, -time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff(t, int64(1), purged)
+	})
+}
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
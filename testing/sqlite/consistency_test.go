@@ -0,0 +1,73 @@
+package sqlite_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gocraft/dbr/v2"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/handler"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+func TestConsistencyTokens(t *testing.T) {
+	// the replica never replicates, anything written after it was taken is
+	// missing from it
+	db, stale := newDB(t), newDB(t)
+	primary := database.NewSQLiteDBR(db)
+	replicas := database.NewReplicas(primary, []*dbr.Connection{database.NewSQLiteDBR(stale)}, 0)
+	replicas.HasRevision = outbox.HasRevision
+
+	srv := httptest.NewServer(handler.API(handler.Deps{
+		DB:               db,
+		Permissions:      permission.NewReplicatedMySQLStore(primary, replicas),
+		Roles:            role.NewSQLiteStore(db),
+		MigrationVersion: migrate.DesiredVersion,
+	}))
+	t.Cleanup(srv.Close)
+
+	var created permission.Permission
+	resp := do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &created)
+	diff(t, http.StatusCreated, resp.StatusCode)
+	token := resp.Header.Get("X-Revision")
+	rev, err := outbox.ParseToken(token)
+	if err != nil {
+		t.Fatalf("write returned token %q: %v", token, err)
+	}
+	diff(t, int64(1), rev)
+
+	// the replica serves reads that don't care
+	resp = do(t, srv, http.MethodGet, "/permission/"+created.ID.String(), "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+
+	// it hasn't applied the write the token is from, so the primary does
+	var got permission.Permission
+	resp = do(t, srv, http.MethodGet, "/permission/"+created.ID.String()+"?at_least_as_fresh="+token, "", &got)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, created, got)
+	diff(t, "", resp.Header.Get("X-Revision"))
+
+	resp = do(t, srv, http.MethodGet, "/permission?at_least_as_fresh=latest", "", nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	// failed writes don't hand out tokens
+	resp = do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, nil)
+	diff(t, http.StatusConflict, resp.StatusCode)
+	diff(t, "", resp.Header.Get("X-Revision"))
+
+	var updated permission.Permission
+	resp = do(t, srv, http.MethodPut, "/permission/"+created.ID.String(), `{"name":"games.build.ship"}`, &updated)
+	diff(t, http.StatusOK, resp.StatusCode)
+	newer, err := outbox.ParseToken(resp.Header.Get("X-Revision"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newer <= rev {
+		t.Errorf("revision went from %d to %d", rev, newer)
+	}
+}
@@ -0,0 +1,124 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/decision"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/decisionlog"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/metrics"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/handler"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+func TestCheck(t *testing.T) {
+	db := newDB(t)
+	events := outbox.NewSQLiteStore(db)
+	var log bytes.Buffer
+	m := metrics.New()
+	srv := httptest.NewServer(handler.API(handler.Deps{
+		DB:               db,
+		Permissions:      permission.NewSQLiteStore(db),
+		Roles:            role.NewSQLiteStore(db),
+		Events:           events,
+		MigrationVersion: migrate.DesiredVersion,
+		Metrics:          m,
+		DecisionLog:      decisionlog.New(&log, decisionlog.Config{SampleRate: 1}),
+		OnDecisionLogError: func(err error) {
+			t.Error(err)
+		},
+	}))
+	t.Cleanup(srv.Close)
+
+	var deploy permission.Permission
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &deploy)
+	var builder role.Role
+	do(t, srv, http.MethodPost, "/role", `{"name":"builder"}`, &builder)
+	do(t, srv, http.MethodPut, "/role/"+builder.ID.String()+"/permissions", fmt.Sprintf(`{"permission_ids":[%q]}`, deploy.ID), nil)
+	if _, err := outbox.NewRelay(events, nil).PublishPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var d decision.Decision
+	resp := do(t, srv, http.MethodPost, "/check", `{"role":"builder","permission":"games.build.deploy"}`, &d)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, true, d.Allowed)
+	do(t, srv, http.MethodPost, "/check", `{"role":"builder","permission":"games.build.ship"}`, &d)
+	diff(t, false, d.Allowed)
+	resp = do(t, srv, http.MethodPost, "/check", `{"role":"builder"}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	// the request that failed validation never got to a decision
+	want := `
+# HELP permission_decisions_total Authorization decisions by result.
+# TYPE permission_decisions_total counter
+permission_decisions_total{result="allow"} 1
+permission_decisions_total{result="deny"} 1
+`
+	if err := testutil.GatherAndCompare(m.Registry, strings.NewReader(want), "permission_decisions_total"); err != nil {
+		t.Error(err)
+	}
+
+	var records []decisionlog.Record
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		var rec decisionlog.Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	wantRecords := []decisionlog.Record{
+		{Subject: "role:builder", Action: "games.build.deploy", Allowed: true, MatchedRule: "role:builder grants games.build.deploy", PolicyRevision: "3"},
+		{Subject: "role:builder", Action: "games.build.ship", PolicyRevision: "3"},
+	}
+	if d := cmp.Diff(wantRecords, records, cmpopts.IgnoreFields(decisionlog.Record{}, "Time", "RequestID")); d != "" {
+		t.Errorf("(-want +got):\n%s", d)
+	}
+}
+
+func TestCheckTrace(t *testing.T) {
+	srv := newServer(t)
+
+	var deploy permission.Permission
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &deploy)
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.ship"}`, nil)
+	var builder role.Role
+	do(t, srv, http.MethodPost, "/role", `{"name":"builder"}`, &builder)
+	do(t, srv, http.MethodPut, "/role/"+builder.ID.String()+"/permissions", fmt.Sprintf(`{"permission_ids":[%q]}`, deploy.ID), nil)
+
+	tests := []struct {
+		query string
+		want  decision.Decision
+	}{
+		{`{"role":"builder","permission":"games.build.deploy"}`, decision.Decision{Allowed: true, Trace: []string{
+			`role "builder" exists`, `permission "games.build.deploy" exists`, `permission "games.build.deploy" is granted by role "builder"`, "allow",
+		}}},
+		{`{"role":"builder","permission":"games.build.ship"}`, decision.Decision{Trace: []string{
+			`role "builder" exists`, `permission "games.build.ship" exists`, `permission "games.build.ship" isn't granted to role "builder"`, "deny",
+		}}},
+		{`{"role":"builder","permission":"games.build.test"}`, decision.Decision{Trace: []string{
+			`role "builder" exists`, `permission "games.build.test" doesn't exist`, "deny",
+		}}},
+		{`{"role":"tester","permission":"games.build.deploy"}`, decision.Decision{Trace: []string{
+			`role "tester" doesn't exist`, "deny",
+		}}},
+	}
+	for _, tt := range tests {
+		var got decision.Decision
+		do(t, srv, http.MethodPost, "/check", tt.query, &got)
+		diff(t, tt.want, got)
+	}
+}
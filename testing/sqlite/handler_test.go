@@ -0,0 +1,582 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/audit"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/database"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/handler"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+// newDB runs the real migrations against a fresh SQLite file, no docker
+// needed.
+func newDB(t *testing.T) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "permission.db")
+	migrate.TestEnsureMigrations(t, migrate.Config{Driver: migrate.DriverSQLite, Name: path})
+
+	db, err := database.Open(database.Config{Driver: database.DriverSQLite, Name: path}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newServer serves the full API on top of a newDB.
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	db := newDB(t)
+
+	srv := httptest.NewServer(handler.API(handler.Deps{
+		DB:               db,
+		Permissions:      permission.NewSQLiteStore(db),
+		Roles:            role.NewSQLiteStore(db),
+		Audit:            audit.NewSQLiteStore(db),
+		TrustActorHeader: true,
+		MigrationVersion: migrate.DesiredVersion,
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newMemoryServer serves the full API on top of the in-memory stores.
+func newMemoryServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	permissions := permission.NewMemoryStore()
+
+	srv := httptest.NewServer(handler.API(handler.Deps{
+		Permissions:      permissions,
+		Roles:            role.NewMemoryStore(permissions),
+		TrustActorHeader: true,
+		MigrationVersion: migrate.DesiredVersion,
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// backends are the servers the tests that hold the memory stores to the
+// SQL ones run against.
+var backends = map[string]func(*testing.T) *httptest.Server{
+	"sqlite": newServer,
+	"memory": newMemoryServer,
+}
+
+func TestPermissionEndpoints(t *testing.T) {
+	srv := newServer(t)
+
+	var created permission.Permission
+	resp := do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &created)
+	diff(t, http.StatusCreated, resp.StatusCode)
+	diff(t, "games.build.deploy", created.Name)
+
+	resp = do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, nil)
+	diff(t, http.StatusConflict, resp.StatusCode)
+
+	var got permission.Permission
+	resp = do(t, srv, http.MethodGet, "/permission/"+created.ID.String(), "", &got)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, created, got)
+
+	resp = do(t, srv, http.MethodGet, "/permission/not-a-uuid", "", nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = do(t, srv, http.MethodPut, "/permission/"+created.ID.String(), `{"name":"games.build.ship"}`, &got)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, "games.build.ship", got.Name)
+
+	var list handler.ListpermissionsResponse
+	resp = do(t, srv, http.MethodGet, "/permission", "", &list)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, []permission.Permission{got}, list.Permissions)
+
+	resp = do(t, srv, http.MethodDelete, "/permission/"+created.ID.String(), "", nil)
+	diff(t, http.StatusNoContent, resp.StatusCode)
+	resp = do(t, srv, http.MethodGet, "/permission/"+created.ID.String(), "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRoleEndpoints(t *testing.T) {
+	srv := newServer(t)
+
+	var created role.Role
+	resp := do(t, srv, http.MethodPost, "/role", `{"name":"developer"}`, &created)
+	diff(t, http.StatusCreated, resp.StatusCode)
+
+	resp = do(t, srv, http.MethodPost, "/role", `{"name":"developer"}`, nil)
+	diff(t, http.StatusConflict, resp.StatusCode)
+
+	resp = do(t, srv, http.MethodPost, "/role", `{}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = do(t, srv, http.MethodDelete, "/role/"+created.ID.String(), "", nil)
+	diff(t, http.StatusNoContent, resp.StatusCode)
+	resp = do(t, srv, http.MethodDelete, "/role/"+created.ID.String(), "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRolePermissionEndpoints(t *testing.T) {
+	srv := newServer(t)
+
+	var deploy, ship permission.Permission
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &deploy)
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.ship"}`, &ship)
+	var developer role.Role
+	do(t, srv, http.MethodPost, "/role", `{"name":"developer"}`, &developer)
+	path := "/role/" + developer.ID.String() + "/permissions"
+
+	want := []uuid.UUID{deploy.ID, ship.ID}
+	sort.Slice(want, func(i, j int) bool { return want[i].String() < want[j].String() })
+	body := fmt.Sprintf(`{"permission_ids":[%q,%q,%q]}`, ship.ID, deploy.ID, ship.ID)
+
+	var got handler.RolePermissionsResponse
+	resp := do(t, srv, http.MethodPut, path, body, &got)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, want, got.PermissionIDs)
+
+	resp = do(t, srv, http.MethodGet, path, "", &got)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, want, got.PermissionIDs)
+
+	// an unknown permission fails the whole replacement, the old grants stay
+	resp = do(t, srv, http.MethodPut, path, fmt.Sprintf(`{"permission_ids":[%q]}`, uuid.New()), nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+	do(t, srv, http.MethodGet, path, "", &got)
+	diff(t, want, got.PermissionIDs)
+
+	resp = do(t, srv, http.MethodPut, path, `{}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = do(t, srv, http.MethodPut, path, `{"permission_ids":[]}`, &got)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, []uuid.UUID{}, got.PermissionIDs)
+
+	resp = do(t, srv, http.MethodGet, "/role/"+uuid.New().String()+"/permissions", "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestSoftDeleteEndpoints(t *testing.T) {
+	srv := newServer(t)
+
+	var deploy permission.Permission
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &deploy)
+	var developer role.Role
+	do(t, srv, http.MethodPost, "/role", `{"name":"developer"}`, &developer)
+	grants := "/role/" + developer.ID.String() + "/permissions"
+	do(t, srv, http.MethodPut, grants, fmt.Sprintf(`{"permission_ids":[%q]}`, deploy.ID), nil)
+
+	// the role goes first, a deleted role no longer holds on to its grants
+	resp := do(t, srv, http.MethodDelete, "/role/"+developer.ID.String(), "", nil)
+	diff(t, http.StatusNoContent, resp.StatusCode)
+	resp = do(t, srv, http.MethodGet, grants, "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = do(t, srv, http.MethodDelete, "/permission/"+deploy.ID.String(), "", nil)
+	diff(t, http.StatusNoContent, resp.StatusCode)
+	resp = do(t, srv, http.MethodGet, "/permission/"+deploy.ID.String(), "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+
+	var got permission.Permission
+	resp = do(t, srv, http.MethodGet, "/permission/"+deploy.ID.String()+"?include_deleted=true", "", &got)
+	diff(t, http.StatusOK, resp.StatusCode)
+	if got.DeletedAt == nil {
+		t.Error("deleted permission has no deleted_at")
+	}
+
+	var list handler.ListpermissionsResponse
+	do(t, srv, http.MethodGet, "/permission", "", &list)
+	diff(t, 0, len(list.Permissions))
+	do(t, srv, http.MethodGet, "/permission?include_deleted=true", "", &list)
+	diff(t, 1, len(list.Permissions))
+	resp = do(t, srv, http.MethodGet, "/permission?include_deleted=maybe", "", nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	// a restored role keeps its grants, but a deleted permission isn't
+	// granted and can't be granted again
+	var restored role.Role
+	resp = do(t, srv, http.MethodPost, "/role/"+developer.ID.String()+":restore", "", &restored)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, developer, restored)
+	var granted handler.RolePermissionsResponse
+	do(t, srv, http.MethodGet, grants, "", &granted)
+	diff(t, []uuid.UUID{}, granted.PermissionIDs)
+	resp = do(t, srv, http.MethodPut, grants, fmt.Sprintf(`{"permission_ids":[%q]}`, deploy.ID), nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	var restoredPermission permission.Permission
+	resp = do(t, srv, http.MethodPost, "/permission/"+deploy.ID.String()+":restore", "", &restoredPermission)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, deploy, restoredPermission)
+	do(t, srv, http.MethodGet, grants, "", &granted)
+	diff(t, []uuid.UUID{deploy.ID}, granted.PermissionIDs)
+
+	resp = do(t, srv, http.MethodPost, "/role/"+uuid.New().String()+":restore", "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRecreateDeletedName(t *testing.T) {
+	srv := newServer(t)
+
+	// a deleted permission or role doesn't hold on to its name, but it
+	// can't be restored while a live one has taken it
+	for _, kind := range []string{"permission", "role"} {
+		t.Run(kind, func(t *testing.T) {
+			var old, recreated struct {
+				ID uuid.UUID `json:"id"`
+			}
+			do(t, srv, http.MethodPost, "/"+kind, `{"name":"games.reused"}`, &old)
+			resp := do(t, srv, http.MethodDelete, "/"+kind+"/"+old.ID.String(), "", nil)
+			diff(t, http.StatusNoContent, resp.StatusCode)
+
+			resp = do(t, srv, http.MethodPost, "/"+kind, `{"name":"games.reused"}`, &recreated)
+			diff(t, http.StatusCreated, resp.StatusCode)
+			resp = do(t, srv, http.MethodPost, "/"+kind, `{"name":"games.reused"}`, nil)
+			diff(t, http.StatusConflict, resp.StatusCode)
+
+			resp = do(t, srv, http.MethodPost, "/"+kind+"/"+old.ID.String()+":restore", "", nil)
+			diff(t, http.StatusConflict, resp.StatusCode)
+
+			do(t, srv, http.MethodDelete, "/"+kind+"/"+recreated.ID.String(), "", nil)
+			resp = do(t, srv, http.MethodPost, "/"+kind+"/"+old.ID.String()+":restore", "", nil)
+			diff(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestDependentsEndpoints(t *testing.T) {
+	for name, newServer := range backends {
+		t.Run(name, func(t *testing.T) {
+			srv := newServer(t)
+
+			var deploy permission.Permission
+			do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &deploy)
+			var developer, admin role.Role
+			do(t, srv, http.MethodPost, "/role", `{"name":"developer"}`, &developer)
+			do(t, srv, http.MethodPost, "/role", `{"name":"admin"}`, &admin)
+			for _, r := range []role.Role{developer, admin} {
+				do(t, srv, http.MethodPut, "/role/"+r.ID.String()+"/permissions", fmt.Sprintf(`{"permission_ids":[%q]}`, deploy.ID), nil)
+			}
+			path := "/permission/" + deploy.ID.String()
+
+			var dependents permission.Dependents
+			resp := do(t, srv, http.MethodGet, path+"/dependents", "", &dependents)
+			diff(t, http.StatusOK, resp.StatusCode)
+			diff(t, permission.Dependents{Roles: []permission.DependentRole{
+				{ID: admin.ID, Name: admin.Name},
+				{ID: developer.ID, Name: developer.Name},
+			}}, dependents)
+
+			var refused web.ErrorResponse
+			resp = do(t, srv, http.MethodDelete, path, "", &refused)
+			diff(t, http.StatusConflict, resp.StatusCode)
+			diff(t, []string{
+				fmt.Sprintf("role admin (%s)", admin.ID),
+				fmt.Sprintf("role developer (%s)", developer.ID),
+			}, refused.Details)
+			resp = do(t, srv, http.MethodGet, path, "", nil)
+			diff(t, http.StatusOK, resp.StatusCode)
+
+			resp = do(t, srv, http.MethodDelete, path+"?cascade=nope", "", nil)
+			diff(t, http.StatusBadRequest, resp.StatusCode)
+
+			resp = do(t, srv, http.MethodDelete, path+"?cascade=true", "", nil)
+			diff(t, http.StatusNoContent, resp.StatusCode)
+			resp = do(t, srv, http.MethodGet, path+"/dependents", "", nil)
+			diff(t, http.StatusNotFound, resp.StatusCode)
+
+			// the grants are gone for good, restoring doesn't bring them back
+			do(t, srv, http.MethodPost, path+":restore", "", nil)
+			resp = do(t, srv, http.MethodGet, path+"/dependents", "", &dependents)
+			diff(t, http.StatusOK, resp.StatusCode)
+			diff(t, permission.Dependents{Roles: []permission.DependentRole{}}, dependents)
+		})
+	}
+}
+
+func TestGrantRefusals(t *testing.T) {
+	for name, newServer := range backends {
+		t.Run(name, func(t *testing.T) {
+			srv := newServer(t)
+
+			var deploy, read permission.Permission
+			do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &deploy)
+			do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.read"}`, &read)
+			var developer role.Role
+			do(t, srv, http.MethodPost, "/role", `{"name":"developer"}`, &developer)
+			path := "/role/" + developer.ID.String()
+			grant := func(ids ...uuid.UUID) *http.Response {
+				body, err := json.Marshal(role.IncomingRolePermissions{PermissionIDs: ids})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return do(t, srv, http.MethodPut, path+"/permissions", string(body), nil)
+			}
+			granted := func() []uuid.UUID {
+				var got handler.RolePermissionsResponse
+				resp := do(t, srv, http.MethodGet, path+"/permissions", "", &got)
+				diff(t, http.StatusOK, resp.StatusCode)
+				return got.PermissionIDs
+			}
+
+			diff(t, http.StatusBadRequest, grant(uuid.New()).StatusCode)
+			do(t, srv, http.MethodDelete, "/permission/"+read.ID.String(), "", nil)
+			diff(t, http.StatusBadRequest, grant(read.ID).StatusCode)
+			diff(t, []uuid.UUID{}, granted())
+
+			// a grant of a deleted permission is hidden until it's restored
+			do(t, srv, http.MethodPost, "/permission/"+read.ID.String()+":restore", "", nil)
+			diff(t, http.StatusOK, grant(read.ID).StatusCode)
+			resp := do(t, srv, http.MethodDelete, "/permission/"+read.ID.String(), "", nil)
+			diff(t, http.StatusConflict, resp.StatusCode)
+
+			diff(t, http.StatusOK, grant(deploy.ID).StatusCode)
+			do(t, srv, http.MethodDelete, path, "", nil)
+			resp = do(t, srv, http.MethodDelete, "/permission/"+deploy.ID.String(), "", nil)
+			diff(t, http.StatusNoContent, resp.StatusCode)
+			do(t, srv, http.MethodPost, path+":restore", "", nil)
+			diff(t, []uuid.UUID{}, granted())
+			do(t, srv, http.MethodPost, "/permission/"+deploy.ID.String()+":restore", "", nil)
+			diff(t, []uuid.UUID{deploy.ID}, granted())
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	newStores := map[string]func(*testing.T) (permission.Store, role.Store){
+		"sqlite": func(t *testing.T) (permission.Store, role.Store) {
+			db := newDB(t)
+			return permission.NewSQLiteStore(db), role.NewSQLiteStore(db)
+		},
+		"memory": func(t *testing.T) (permission.Store, role.Store) {
+			permissions := permission.NewMemoryStore()
+			return permissions, role.NewMemoryStore(permissions)
+		},
+	}
+	for name, newStores := range newStores {
+		t.Run(name, func(t *testing.T) {
+			permissionStore, roleStore := newStores(t)
+			permissions, roles := permission.NewAPI(permissionStore), role.NewAPI(roleStore)
+
+			deploy, err := permissions.Createpermission(ctx, permission.Incomingpermission{Name: "games.build.deploy"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			developer, err := roles.CreateRole(ctx, role.IncomingRole{Name: "developer"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := roles.ReplaceRolePermissions(ctx, developer.ID, role.IncomingRolePermissions{PermissionIDs: []uuid.UUID{deploy.ID}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := roles.DeleteRole(ctx, developer.ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := permissions.Deletepermission(ctx, deploy.ID, false); err != nil {
+				t.Fatal(err)
+			}
+
+			purged, err := permissions.Purgepermissions(ctx, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff(t, int64(0), purged)
+
+			// the deleted role's grant would fail the permission's foreign key if it
+			// were left behind
+			purged, err = permissions.Purgepermissions(ctx, -time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff(t, int64(1), purged)
+			_, err = permissions.Getpermission(ctx, deploy.ID, true)
+			diff(t, http.StatusNotFound, bestirerror.StatusCode(err))
+
+			purged, err = roles.PurgeRoles(ctx, -time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff(t, int64(1), purged)
+		})
+	}
+}
+
+func TestAuditEndpoints(t *testing.T) {
+	srv := newServer(t)
+	start := time.Now().UTC()
+
+	var deploy permission.Permission
+	resp := doAs(t, srv, "alice", http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &deploy)
+	requestID := resp.Header.Get("X-Request-ID")
+	if requestID == "" {
+		t.Fatal("no X-Request-ID in the response")
+	}
+	var developer role.Role
+	doAs(t, srv, "alice", http.MethodPost, "/role", `{"name":"developer"}`, &developer)
+	grants := fmt.Sprintf(`{"permission_ids":[%q]}`, deploy.ID)
+	doAs(t, srv, "bob", http.MethodPut, "/role/"+developer.ID.String()+"/permissions", grants, nil)
+	doAs(t, srv, "bob", http.MethodPut, "/permission/"+deploy.ID.String(), `{"name":"games.build.ship"}`, nil)
+	// failed changes leave no record
+	doAs(t, srv, "bob", http.MethodDelete, "/permission/"+deploy.ID.String(), "", nil)
+
+	var got handler.ListAuditRecordsResponse
+	resp = do(t, srv, http.MethodGet, "/audit", "", &got)
+	diff(t, http.StatusOK, resp.StatusCode)
+	var summary []string
+	for _, rec := range got.Records {
+		summary = append(summary, rec.Actor+" "+rec.Action+" "+rec.TargetType)
+	}
+	diff(t, []string{
+		"bob update permission",
+		"bob replace_permissions role",
+		"alice create role",
+		"alice create permission",
+	}, summary)
+
+	do(t, srv, http.MethodGet, "/audit?target_type=permission&target_id="+deploy.ID.String(), "", &got)
+	diff(t, 2, len(got.Records))
+	update := got.Records[0]
+	diff(t, `{"id":"`+deploy.ID.String()+`","name":"games.build.deploy"}`, update.Before.String())
+	diff(t, `{"id":"`+deploy.ID.String()+`","name":"games.build.ship"}`, update.After.String())
+	created := got.Records[1]
+	diff(t, requestID, created.RequestID)
+	diff(t, "null", created.Before.String())
+
+	do(t, srv, http.MethodGet, "/audit?actor=bob&target_id="+developer.ID.String(), "", &got)
+	diff(t, 1, len(got.Records))
+	diff(t, `{"permission_ids":["`+deploy.ID.String()+`"]}`, got.Records[0].After.String())
+
+	do(t, srv, http.MethodGet, "/audit?limit=1", "", &got)
+	diff(t, 1, len(got.Records))
+	do(t, srv, http.MethodGet, "/audit?since="+start.Add(time.Hour).Format(time.RFC3339), "", &got)
+	diff(t, 0, len(got.Records))
+	do(t, srv, http.MethodGet, "/audit?since="+start.Add(-time.Second).Format(time.RFC3339)+"&until="+start.Add(time.Hour).Format(time.RFC3339), "", &got)
+	diff(t, 4, len(got.Records))
+
+	for _, query := range []string{"since=yesterday", "target_id=nope", "limit=0"} {
+		resp = do(t, srv, http.MethodGet, "/audit?"+query, "", nil)
+		diff(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestAuditUntrustedActor(t *testing.T) {
+	db := newDB(t)
+	srv := httptest.NewServer(handler.API(handler.Deps{
+		DB:               db,
+		Permissions:      permission.NewSQLiteStore(db),
+		Roles:            role.NewSQLiteStore(db),
+		Audit:            audit.NewSQLiteStore(db),
+		MigrationVersion: migrate.DesiredVersion,
+	}))
+	t.Cleanup(srv.Close)
+
+	// without a gateway vouching for X-Actor it's only a claim
+	doAs(t, srv, "alice", http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, nil)
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.ship"}`, nil)
+
+	var got handler.ListAuditRecordsResponse
+	do(t, srv, http.MethodGet, "/audit", "", &got)
+	var actors []string
+	for _, rec := range got.Records {
+		actors = append(actors, rec.Actor)
+	}
+	diff(t, []string{audit.UnknownActor, "claimed:alice"}, actors)
+
+	// the prefix counts against the column too
+	resp := doAs(t, srv, strings.Repeat("a", 250), http.MethodPost, "/permission", `{"name":"games.build.test"}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAuditOversizedHeaders(t *testing.T) {
+	srv := newServer(t)
+
+	resp := doAs(t, srv, strings.Repeat("a", 256), http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+	doAs(t, srv, strings.Repeat("a", 255), http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, nil)
+
+	// an id too long to record is replaced like a missing one
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/permission", strings.NewReader(`{"name":"games.build.ship"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", strings.Repeat("r", 256))
+	resp, err = srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	diff(t, http.StatusCreated, resp.StatusCode)
+	requestID := resp.Header.Get("X-Request-ID")
+	if _, err := uuid.Parse(requestID); err != nil {
+		t.Errorf("request id wasn't replaced: %q", requestID)
+	}
+
+	var got handler.ListAuditRecordsResponse
+	do(t, srv, http.MethodGet, "/audit", "", &got)
+	diff(t, 2, len(got.Records))
+	diff(t, requestID, got.Records[0].RequestID)
+}
+
+func TestReadiness(t *testing.T) {
+	srv := newServer(t)
+
+	resp := do(t, srv, http.MethodGet, "/readyz", "", nil)
+	diff(t, http.StatusOK, resp.StatusCode)
+}
+
+// do sends body to path and decodes the response into out when out isn't nil.
+func do(t *testing.T, srv *httptest.Server, method, path, body string, out interface{}) *http.Response {
+	t.Helper()
+	return doAs(t, srv, "", method, path, body, out)
+}
+
+// doAs is do on behalf of actor.
+func doAs(t *testing.T, srv *httptest.Server, actor, method, path, body string, out interface{}) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if actor != "" {
+		req.Header.Set("X-Actor", actor)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp
+}
+
+func diff(t *testing.T, want, got interface{}) {
+	t.Helper()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
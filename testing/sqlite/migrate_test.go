@@ -0,0 +1,125 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+)
+
+func TestMigrateDryRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permission.db")
+	cfg := migrate.Config{Driver: migrate.DriverSQLite, Name: path, DryRun: true, LoggerOverride: migrate.TestingLogger{T: t}}
+	ctx := context.Background()
+
+	// a dry run against a database that was never migrated mustn't fail or
+	// leave a version table behind
+	if err := migrate.Migrate(ctx, cfg, migrate.SourceFor(cfg.Driver), migrate.DesiredVersion); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := migrate.CurrentVersion(ctx, db); err == nil {
+		t.Fatal("dry run created the version table")
+	}
+
+	cfg.DryRun = false
+	if err := migrate.Migrate(ctx, cfg, migrate.SourceFor(cfg.Driver), migrate.DesiredVersion); err != nil {
+		t.Fatal(err)
+	}
+	got, err := migrate.CurrentVersion(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, migrate.DesiredVersion, got)
+
+	current, statuses, err := migrate.Status(ctx, cfg, migrate.SourceFor(cfg.Driver))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, migrate.DesiredVersion, current)
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("migration %d not applied", s.Version)
+		}
+	}
+}
+
+func TestMigrateKeepsGrants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permission.db")
+	cfg := migrate.Config{Driver: migrate.DriverSQLite, Name: path, LoggerOverride: migrate.TestingLogger{T: t}}
+	ctx := context.Background()
+
+	// 20261019090700 rebuilds the role table, which mustn't take the grants
+	// with it
+	if err := migrate.Migrate(ctx, cfg, migrate.SourceFor(cfg.Driver), 20261019090600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`INSERT INTO permission (id, name) VALUES ('p1', 'games.build.deploy')`,
+		`INSERT INTO role (id, name) VALUES ('r1', 'developer')`,
+		`INSERT INTO role_permission (role_id, permission_id) VALUES ('r1', 'p1')`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrate.Migrate(ctx, cfg, migrate.SourceFor(cfg.Driver), migrate.DesiredVersion); err != nil {
+		t.Fatal(err)
+	}
+	var granted int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM role_permission WHERE role_id = 'r1'`).Scan(&granted); err != nil {
+		t.Fatal(err)
+	}
+	diff(t, 1, granted)
+}
+
+func TestMigrateNeverDown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permission.db")
+	cfg := migrate.Config{Driver: migrate.DriverSQLite, Name: path, LoggerOverride: migrate.TestingLogger{T: t}}
+	ctx := context.Background()
+	src := migrate.SourceFor(cfg.Driver)
+
+	if err := migrate.Migrate(ctx, cfg, src, migrate.DesiredVersion); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// an older build starting against the database leaves it alone
+	if err := migrate.Migrate(ctx, cfg, src, 20261019090400); err != nil {
+		t.Fatal(err)
+	}
+	got, err := migrate.CurrentVersion(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, migrate.DesiredVersion, got)
+	if _, err := db.ExecContext(ctx, `SELECT COUNT(*) FROM outbox`); err != nil {
+		t.Fatalf("outbox table is gone: %v", err)
+	}
+
+	// rolling back takes an explicit MigrateDown
+	if err := migrate.MigrateDown(ctx, cfg, src, 20261019090400); err != nil {
+		t.Fatal(err)
+	}
+	got, err = migrate.CurrentVersion(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, int64(20261019090400), got)
+}
@@ -0,0 +1,180 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+// failingPublisher fails once it has published n events.
+type failingPublisher struct {
+	n      int
+	events []outbox.Event
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, event outbox.Event) error {
+	if len(p.events) == p.n {
+		return errors.New("sink down")
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	permissions := permission.NewAPI(permission.NewSQLiteStore(db))
+	roles := role.NewAPI(role.NewSQLiteStore(db))
+	events := outbox.NewSQLiteStore(db)
+
+	deploy, err := permissions.Createpermission(ctx, permission.Incomingpermission{Name: "games.build.deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	developer, err := roles.CreateRole(ctx, role.IncomingRole{Name: "developer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := roles.ReplaceRolePermissions(ctx, developer.ID, role.IncomingRolePermissions{PermissionIDs: []uuid.UUID{deploy.ID}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := permissions.Deletepermission(ctx, deploy.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	// a change that rolls back leaves no event
+	if _, err := roles.CreateRole(ctx, role.IncomingRole{Name: "developer"}); err == nil {
+		t.Fatal("duplicate name was created")
+	}
+
+	// the sink fails partway, the rest stay pending in order
+	failing := &failingPublisher{n: 2}
+	relay := outbox.NewRelay(events, failing)
+	published, err := relay.PublishPending(ctx)
+	if err == nil {
+		t.Fatal("publish error was swallowed")
+	}
+	diff(t, 2, published)
+
+	var buf bytes.Buffer
+	relay.Publisher = outbox.NewWriterPublisher(&buf)
+	published, err = relay.PublishPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, 3, published)
+
+	var got []string
+	for _, event := range failing.events {
+		got = append(got, event.Type+" "+event.AggregateID.String())
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var event outbox.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, event.Type+" "+event.AggregateID.String())
+	}
+	diff(t, []string{
+		outbox.PermissionCreated + " " + deploy.ID.String(),
+		outbox.RoleCreated + " " + developer.ID.String(),
+		outbox.RolePermissionsReplaced + " " + developer.ID.String(),
+		outbox.PermissionDeleted + " " + deploy.ID.String(),
+		outbox.RolePermissionRevoked + " " + developer.ID.String(),
+	}, got)
+
+	var created permission.Permission
+	if err := json.Unmarshal(failing.events[0].Payload, &created); err != nil {
+		t.Fatal(err)
+	}
+	diff(t, deploy, created)
+
+	published, err = relay.PublishPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, 0, published)
+}
+
+func TestRelayLease(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	permissions := permission.NewAPI(permission.NewSQLiteStore(db))
+	events := outbox.NewSQLiteStore(db)
+
+	create := func(name string) {
+		t.Helper()
+		if _, err := permissions.Createpermission(ctx, permission.Incomingpermission{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	publish := func(relay *outbox.Relay) int {
+		t.Helper()
+		published, err := relay.PublishPending(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return published
+	}
+
+	// every instance runs a relay, only the one holding the lease publishes,
+	// n -1 never fails
+	first, second := &failingPublisher{n: -1}, &failingPublisher{n: -1}
+	a, b := outbox.NewRelay(events, first), outbox.NewRelay(events, second)
+	a.LeaseDuration = 50 * time.Millisecond
+	create("games.build.deploy")
+	diff(t, 1, publish(a))
+	create("games.build.view")
+	diff(t, 0, publish(b))
+	diff(t, 1, publish(a))
+
+	// once a stops renewing, b takes over
+	time.Sleep(a.LeaseDuration)
+	create("games.build.ship")
+	diff(t, 1, publish(b))
+	diff(t, 0, publish(a))
+	diff(t, 2, len(first.events))
+	diff(t, 1, len(second.events))
+
+	// published events are purged, pending ones kept
+	create("games.build.cancel")
+	purged, err := events.PurgePublished(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, int64(3), purged)
+	diff(t, 1, publish(b))
+	diff(t, outbox.PermissionCreated, second.events[1].Type)
+}
+
+func TestRelayWithoutPublisher(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	permissions := permission.NewAPI(permission.NewSQLiteStore(db))
+	events := outbox.NewSQLiteStore(db)
+
+	if _, err := permissions.Createpermission(ctx, permission.Incomingpermission{Name: "games.build.deploy"}); err != nil {
+		t.Fatal(err)
+	}
+	// with nothing to publish to, sequenced events are done with and don't
+	// pile up in the outbox
+	published, err := outbox.NewRelay(events, nil).PublishPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, 1, published)
+	purged, err := events.PurgePublished(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, int64(1), purged)
+}
@@ -0,0 +1,261 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/bestirerror"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/foundation/web"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/handler"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/simulate"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/snapshot"
+)
+
+// doYAML sends body as YAML and returns the raw response body.
+func doYAML(t *testing.T, srv *httptest.Server, method, path, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+	req.Header.Set("Accept", "application/yaml")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestSnapshotEndpoints(t *testing.T) {
+	db := newDB(t)
+	srv := httptest.NewServer(handler.API(handler.Deps{
+		DB:               db,
+		Permissions:      permission.NewSQLiteStore(db),
+		Roles:            role.NewSQLiteStore(db),
+		Snapshots:        snapshot.NewSQLiteStore(db),
+		MigrationVersion: migrate.DesiredVersion,
+	}))
+	t.Cleanup(srv.Close)
+
+	var deploy, ship, retired permission.Permission
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &deploy)
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.ship"}`, &ship)
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.retired"}`, &retired)
+	do(t, srv, http.MethodDelete, "/permission/"+retired.ID.String(), "", nil)
+	var builder role.Role
+	do(t, srv, http.MethodPost, "/role", `{"name":"builder"}`, &builder)
+	do(t, srv, http.MethodPut, "/role/"+builder.ID.String()+"/permissions",
+		`{"permission_ids":["`+ship.ID.String()+`","`+deploy.ID.String()+`"]}`, nil)
+
+	// deleted permissions are left out, names are sorted
+	var exported snapshot.Snapshot
+	resp := do(t, srv, http.MethodGet, "/export", "", &exported)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, snapshot.Snapshot{
+		Version:     snapshot.Version,
+		Permissions: []snapshot.Permission{{Name: "games.build.deploy"}, {Name: "games.build.ship"}},
+		Roles:       []snapshot.Role{{Name: "builder", Permissions: []string{"games.build.deploy", "games.build.ship"}}},
+	}, exported)
+
+	resp, body := doYAML(t, srv, http.MethodGet, "/export", "")
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, "application/yaml", resp.Header.Get("Content-Type"))
+	diff(t, `version: 1
+permissions:
+  - name: games.build.deploy
+  - name: games.build.ship
+roles:
+  - name: builder
+    permissions:
+      - games.build.deploy
+      - games.build.ship
+`, body)
+
+	// merge restores games.retired, creates what's new and leaves games.build.ship
+	var summary snapshot.Summary
+	resp = do(t, srv, http.MethodPost, "/import", `{"version":1,
+		"permissions":[{"name":"games.build.deploy"},{"name":"games.retired"},{"name":"games.review"}],
+		"roles":[{"name":"builder","permissions":["games.build.deploy"]},{"name":"reviewer","permissions":["games.review","games.retired"]}]}`, &summary)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, snapshot.Summary{
+		PermissionsCreated:  1,
+		PermissionsRestored: 1,
+		RolesCreated:        1,
+		RolesRegranted:      2,
+	}, summary)
+
+	resp = do(t, srv, http.MethodGet, "/export", "", &exported)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, snapshot.Snapshot{
+		Version: snapshot.Version,
+		Permissions: []snapshot.Permission{
+			{Name: "games.build.deploy"}, {Name: "games.build.ship"}, {Name: "games.retired"}, {Name: "games.review"},
+		},
+		Roles: []snapshot.Role{
+			{Name: "builder", Permissions: []string{"games.build.deploy"}},
+			{Name: "reviewer", Permissions: []string{"games.retired", "games.review"}},
+		},
+	}, exported)
+
+	// importing what was exported changes nothing
+	resp, _ = doYAML(t, srv, http.MethodPost, "/import?mode=replace", `version: 1
+permissions:
+  - name: games.build.deploy
+  - name: games.build.ship
+  - name: games.retired
+  - name: games.review
+roles:
+  - name: builder
+    permissions: [games.build.deploy]
+  - name: reviewer
+    permissions: [games.retired, games.review]
+`)
+	diff(t, http.StatusOK, resp.StatusCode)
+
+	// replace deletes everything missing from the snapshot
+	resp = do(t, srv, http.MethodPost, "/import?mode=replace", `{"version":1,
+		"permissions":[{"name":"games.build.deploy"}],
+		"roles":[{"name":"builder","permissions":["games.build.deploy"]}]}`, &summary)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, snapshot.Summary{PermissionsDeleted: 3, RolesDeleted: 1}, summary)
+
+	var roles handler.ListRolesResponse
+	resp = do(t, srv, http.MethodGet, "/role", "", &roles)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, []role.Role{builder}, roles.Roles)
+	resp = do(t, srv, http.MethodGet, "/permission/"+ship.ID.String(), "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+
+	// nothing is applied from an invalid snapshot, every problem is listed
+	var errResp web.ErrorResponse
+	resp = do(t, srv, http.MethodPost, "/import", `{"version":2,
+		"permissions":[{"name":"games.new"},{"name":"games.new"}],
+		"roles":[{"name":"builder","permissions":["games.build.ship"]}]}`, &errResp)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+	diff(t, []string{
+		"permission games.new is listed twice",
+		"role builder grants permission games.build.ship, which isn't in the snapshot",
+		"version must be 1",
+	}, errResp.Details)
+
+	// simulating a change flips the checks it affects and changes nothing
+	var report simulate.Report
+	resp = do(t, srv, http.MethodPost, "/simulate", `{"mode":"replace",
+		"changes":{"version":1,"permissions":[{"name":"games.build.deploy"}],"roles":[]},
+		"checks":[{"role":"builder","permission":"games.build.deploy"}],
+		"decisions":[{"subject":"role:builder","action":"games.build.deploy","allowed":true},{"subject":"alice","action":"games.build.deploy"}]}`, &report)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, 2, report.Checked)
+	diff(t, 1, report.Skipped)
+	diff(t, 1, len(report.Flips))
+	diff(t, 2, report.Flips[0].Count)
+	diff(t, false, report.Flips[0].After.Allowed)
+	resp = do(t, srv, http.MethodPost, "/simulate", `{"mode":"overwrite","changes":{"version":1}}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = do(t, srv, http.MethodPost, "/import?mode=overwrite", `{"version":1}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = doYAML(t, srv, http.MethodPost, "/import", "version: 1\npermisions: []\n")
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+	// bindings, tuples and tenants aren't supported, dropping them quietly
+	// would lose policy copied from somewhere that has them
+	for _, section := range []string{`"bindings":[]`, `"tuples":[]`, `"tenant":"acme"`} {
+		resp = do(t, srv, http.MethodPost, "/import", `{"version":1,`+section+`}`, nil)
+		diff(t, http.StatusBadRequest, resp.StatusCode)
+	}
+
+	resp = do(t, srv, http.MethodGet, "/export", "", &exported)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, snapshot.Snapshot{
+		Version:     snapshot.Version,
+		Permissions: []snapshot.Permission{{Name: "games.build.deploy"}},
+		Roles:       []snapshot.Role{{Name: "builder", Permissions: []string{"games.build.deploy"}}},
+	}, exported)
+}
+
+func TestSnapshotPlan(t *testing.T) {
+	db := newDB(t)
+	api := snapshot.NewAPI(snapshot.NewSQLiteStore(db))
+	ctx := context.Background()
+
+	defined := snapshot.Snapshot{
+		Version:     snapshot.Version,
+		Permissions: []snapshot.Permission{{Name: "games.build.deploy"}, {Name: "games.build.ship"}},
+		Roles:       []snapshot.Role{{Name: "builder", Permissions: []string{"games.build.ship"}}},
+	}
+	if _, err := api.Import(ctx, defined, snapshot.ModeMerge); err != nil {
+		t.Fatal(err)
+	}
+	// unmanaged, only pruning touches it
+	if err := permission.NewSQLiteStore(db).Createpermission(ctx, permission.Permission{ID: uuid.New(), Name: "games.manual"}); err != nil {
+		t.Fatal(err)
+	}
+
+	defined.Roles[0].Permissions = []string{"games.build.deploy"}
+	changes, summary, err := api.Plan(ctx, defined, snapshot.ModeMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, []snapshot.Change{{
+		Action:  snapshot.ActionRegrant,
+		Type:    "role",
+		Name:    "builder",
+		Granted: []string{"games.build.deploy"},
+		Revoked: []string{"games.build.ship"},
+	}}, changes)
+	diff(t, snapshot.Summary{RolesRegranted: 1}, summary)
+
+	changes, _, err = api.Plan(ctx, defined, snapshot.ModeReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, snapshot.Change{Action: snapshot.ActionDelete, Type: "permission", Name: "games.manual"}, changes[len(changes)-1])
+
+	// planning changed nothing
+	exported, err := api.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, []snapshot.Role{{Name: "builder", Permissions: []string{"games.build.ship"}}}, exported.Roles)
+
+	// a role created after the plan was approved would be pruned unseen
+	if err := role.NewSQLiteStore(db).CreateRole(ctx, role.Role{ID: uuid.New(), Name: "manual"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = api.Apply(ctx, defined, snapshot.ModeReplace, changes)
+	if !errors.Is(err, snapshot.ErrStalePlan) {
+		t.Fatalf("applying a stale plan: %v", err)
+	}
+	diff(t, http.StatusConflict, bestirerror.StatusCode(err))
+	exported, err = api.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, 2, len(exported.Roles))
+
+	changes, _, err = api.Plan(ctx, defined, snapshot.ModeReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err = api.Apply(ctx, defined, snapshot.ModeReplace, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, snapshot.Summary{PermissionsDeleted: 1, RolesDeleted: 1, RolesRegranted: 1}, summary)
+}
@@ -0,0 +1,190 @@
+package sqlite_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/handler"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+)
+
+// sseMessage is one Server-Sent Event, comments are skipped.
+type sseMessage struct {
+	ID, Event, Data string
+}
+
+// readMessage reads the next message off a /watch stream.
+func readMessage(t *testing.T, r *bufio.Reader) sseMessage {
+	t.Helper()
+	var msg sseMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if msg != (sseMessage{}) {
+				return msg
+			}
+		case strings.HasPrefix(line, "id: "):
+			msg.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			msg.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			msg.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	events := outbox.NewSQLiteStore(db)
+	app := handler.API(handler.Deps{
+		DB:               db,
+		Permissions:      permission.NewSQLiteStore(db),
+		Roles:            role.NewSQLiteStore(db),
+		Events:           events,
+		WatchInterval:    10 * time.Millisecond,
+		MigrationVersion: migrate.DesiredVersion,
+	})
+	srv := httptest.NewServer(app)
+	t.Cleanup(srv.Close)
+
+	// watchers only see events once the relay has sequenced them
+	relay := outbox.NewRelay(events, nil)
+	sequence := func() {
+		t.Helper()
+		if _, err := relay.PublishPending(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var deploy permission.Permission
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, &deploy)
+	sequence()
+
+	watch := func(lastEventID, query string) (*http.Response, *bufio.Reader) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/watch"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	t.Run("FromLatest", func(t *testing.T) {
+		resp, stream := watch("", "")
+		diff(t, http.StatusOK, resp.StatusCode)
+		diff(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		diff(t, sseMessage{ID: "1", Event: "ready", Data: `{"position":1}`}, readMessage(t, stream))
+
+		var developer role.Role
+		do(t, srv, http.MethodPost, "/role", `{"name":"developer"}`, &developer)
+		sequence()
+		msg := readMessage(t, stream)
+		diff(t, "2", msg.ID)
+		diff(t, outbox.RoleCreated, msg.Event)
+		if !strings.Contains(msg.Data, developer.ID.String()) {
+			t.Errorf("event doesn't name the role: %s", msg.Data)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		// Last-Event-ID wins over after, it's what a reconnect sends
+		_, stream := watch("0", "?after=1")
+		diff(t, sseMessage{ID: "0", Event: "ready", Data: `{"position":0}`}, readMessage(t, stream))
+		diff(t, outbox.PermissionCreated, readMessage(t, stream).Event)
+		diff(t, outbox.RoleCreated, readMessage(t, stream).Event)
+
+		_, stream = watch("", "?after=1")
+		readMessage(t, stream)
+		diff(t, "2", readMessage(t, stream).ID)
+	})
+
+	t.Run("BadCursor", func(t *testing.T) {
+		resp, _ := watch("", "?after=nope")
+		diff(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("OutOfOrderCommit", func(t *testing.T) {
+		// seq is handed out as events are written, the transaction that got
+		// 4 can commit after the one that got 5
+		write := func(seq int64) {
+			t.Helper()
+			if _, err := db.ExecContext(ctx, `INSERT INTO outbox
+				(seq, id, type, aggregate_type, aggregate_id, payload, request_id, created_at)
+				VALUES (?, ?, ?, 'role', ?, '{}', '', ?)`,
+				seq, uuid.New().String(), outbox.RoleUpdated, uuid.New().String(), time.Now().UTC()); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, stream := watch("", "")
+		diff(t, "2", readMessage(t, stream).ID)
+		write(5)
+		sequence()
+		diff(t, "3", readMessage(t, stream).ID)
+		write(4)
+		sequence()
+		diff(t, "4", readMessage(t, stream).ID)
+	})
+
+	t.Run("Purged", func(t *testing.T) {
+		if _, err := events.PurgePublished(ctx, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		_, stream := watch("2", "")
+		diff(t, sseMessage{ID: "4", Event: "reset", Data: `{"position":4}`}, readMessage(t, stream))
+		_, stream = watch("4", "")
+		diff(t, sseMessage{ID: "4", Event: "ready", Data: `{"position":4}`}, readMessage(t, stream))
+
+		// 5 is the oldest event left, a cursor at 4 hasn't missed anything
+		do(t, srv, http.MethodPost, "/role", `{"name":"tester"}`, nil)
+		sequence()
+		_, stream = watch("3", "")
+		diff(t, sseMessage{ID: "5", Event: "reset", Data: `{"position":5}`}, readMessage(t, stream))
+		_, stream = watch("4", "")
+		diff(t, sseMessage{ID: "4", Event: "ready", Data: `{"position":4}`}, readMessage(t, stream))
+		diff(t, "5", readMessage(t, stream).ID)
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		_, stream := watch("", "")
+		readMessage(t, stream)
+		app.Drain()
+		done := make(chan error, 1)
+		go func() {
+			_, err := stream.ReadString('\n')
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("stream kept going after drain")
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("stream wasn't closed by drain")
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package sqlite_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	migrate "github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/db"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/handler"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/outbox"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/permission"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/role"
+	"github.com/Max-Gabriel-Susman/bestir-permissionmaking-service/internal/webhook"
+)
+
+// receiver is a webhook endpoint that keeps the events whose signature
+// checks out and answers them with status.
+type receiver struct {
+	mu     sync.Mutex
+	secret string
+	status int
+	events []outbox.Event
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	if err := webhook.Verify(rc.secret, r.Header.Get(webhook.SignatureHeader), body, time.Now(), webhook.DefaultTolerance); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var event outbox.Event
+	json.Unmarshal(body, &event) //nolint:errcheck
+	rc.events = append(rc.events, event)
+	w.WriteHeader(rc.status)
+}
+
+func (rc *receiver) types() []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	types := []string{}
+	for _, event := range rc.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	webhooks := webhook.NewSQLiteStore(db)
+	srv := httptest.NewServer(handler.API(handler.Deps{
+		DB:               db,
+		Permissions:      permission.NewSQLiteStore(db),
+		Roles:            role.NewSQLiteStore(db),
+		Webhooks:         webhooks,
+		MigrationVersion: migrate.DesiredVersion,
+	}))
+	t.Cleanup(srv.Close)
+
+	ok := &receiver{status: http.StatusNoContent}
+	okSrv := httptest.NewServer(ok)
+	t.Cleanup(okSrv.Close)
+	down := &receiver{status: http.StatusServiceUnavailable}
+	downSrv := httptest.NewServer(down)
+	t.Cleanup(downSrv.Close)
+
+	var roleHook, downHook webhook.Subscription
+	resp := do(t, srv, http.MethodPost, "/webhook", `{"url":"`+okSrv.URL+`","event_types":["RoleCreated","RoleDeleted"]}`, &roleHook)
+	diff(t, http.StatusCreated, resp.StatusCode)
+	if roleHook.Secret == "" || !roleHook.Active {
+		t.Fatalf("created webhook %+v has no secret or isn't active", roleHook)
+	}
+	ok.secret = roleHook.Secret
+	resp = do(t, srv, http.MethodPost, "/webhook", `{"url":"`+downSrv.URL+`","secret":"whsec_down"}`, &downHook)
+	diff(t, http.StatusCreated, resp.StatusCode)
+	diff(t, "whsec_down", downHook.Secret)
+	down.secret = downHook.Secret
+
+	resp = do(t, srv, http.MethodPost, "/webhook", `{"url":"ftp://example.com"}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+	resp = do(t, srv, http.MethodPost, "/webhook", `{"url":"`+okSrv.URL+`","event_types":["RoleBound"]}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+	resp = do(t, srv, http.MethodPost, "/webhook", `{"url":"`+okSrv.URL+`","tenant":"acme"}`, nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	var list handler.ListWebhooksResponse
+	do(t, srv, http.MethodGet, "/webhook", "", &list)
+	diff(t, 2, len(list.Webhooks))
+	for _, sub := range list.Webhooks {
+		diff(t, "", sub.Secret)
+	}
+
+	var developer role.Role
+	do(t, srv, http.MethodPost, "/role", `{"name":"developer"}`, &developer)
+	do(t, srv, http.MethodPost, "/permission", `{"name":"games.build.deploy"}`, nil)
+
+	now := time.Now()
+	dispatcher := webhook.NewDispatcher(webhooks)
+	dispatcher.MaxAttempts = 2
+	dispatcher.Now = func() time.Time { return now }
+	relay := outbox.NewRelay(outbox.NewSQLiteStore(db), outbox.Publishers{dispatcher})
+	if _, err := relay.PublishPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// relaying again doesn't queue the events twice
+	events, err := outbox.NewSQLiteStore(db).ListSince(ctx, 0, outbox.DefaultBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		if err := dispatcher.Publish(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attempted, err := dispatcher.DeliverDue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the role event to both, the permission event only to the catch-all
+	diff(t, 3, attempted)
+	diff(t, []string{outbox.RoleCreated}, ok.types())
+	diff(t, []string{outbox.RoleCreated, outbox.PermissionCreated}, down.types())
+
+	deliveries := func(id uuid.UUID, status string) []webhook.Delivery {
+		t.Helper()
+		var out handler.ListWebhookDeliveriesResponse
+		resp := do(t, srv, http.MethodGet, "/webhook/"+id.String()+"/deliveries?status="+status, "", &out)
+		diff(t, http.StatusOK, resp.StatusCode)
+		return out.Deliveries
+	}
+	succeeded := deliveries(roleHook.ID, webhook.StatusSucceeded)
+	diff(t, 1, len(succeeded))
+	diff(t, http.StatusNoContent, *succeeded[0].ResponseStatus)
+
+	pending := deliveries(downHook.ID, webhook.StatusPending)
+	diff(t, 2, len(pending))
+	for _, d := range pending {
+		diff(t, 1, d.Attempts)
+		diff(t, http.StatusServiceUnavailable, *d.ResponseStatus)
+		diff(t, now.Add(webhook.DefaultBackoffBase).UTC().Truncate(time.Microsecond), d.NextAttemptAt.UTC())
+	}
+
+	// nothing is due until the backoff has passed
+	attempted, err = dispatcher.DeliverDue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, 0, attempted)
+
+	now = now.Add(webhook.DefaultBackoffBase)
+	attempted, err = dispatcher.DeliverDue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff(t, 2, attempted)
+	failed := deliveries(downHook.ID, webhook.StatusFailed)
+	diff(t, 2, len(failed))
+	for _, d := range failed {
+		diff(t, 2, d.Attempts)
+		diff(t, "endpoint responded 503 Service Unavailable", *d.LastError)
+	}
+	diff(t, 0, len(deliveries(downHook.ID, webhook.StatusPending)))
+
+	resp = do(t, srv, http.MethodGet, "/webhook/"+downHook.ID.String()+"/deliveries?status=lost", "", nil)
+	diff(t, http.StatusBadRequest, resp.StatusCode)
+
+	var updated webhook.Subscription
+	resp = do(t, srv, http.MethodPut, "/webhook/"+roleHook.ID.String(), `{"url":"`+okSrv.URL+`","active":false}`, &updated)
+	diff(t, http.StatusOK, resp.StatusCode)
+	diff(t, false, updated.Active)
+	diff(t, webhook.EventTypes{}, updated.EventTypes)
+
+	resp = do(t, srv, http.MethodDelete, "/webhook/"+downHook.ID.String(), "", nil)
+	diff(t, http.StatusNoContent, resp.StatusCode)
+	resp = do(t, srv, http.MethodGet, "/webhook/"+downHook.ID.String()+"/deliveries", "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+	resp = do(t, srv, http.MethodDelete, "/webhook/"+downHook.ID.String(), "", nil)
+	diff(t, http.StatusNotFound, resp.StatusCode)
+}